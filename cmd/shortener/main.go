@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 	"sync"
 
 	"github.com/iurnickita/vigilant-train/internal/shortener/config"
@@ -23,6 +24,14 @@ var (
 )
 
 func main() {
+	// Подкоманда управления схемой базы данных
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		log.Fatal(err)
 	}
@@ -51,7 +60,10 @@ func run() error {
 	}
 
 	// Service
-	shortenerService := service.NewShortener(store)
+	shortenerService, err := service.NewShortener(cfg.Service, store)
+	if err != nil {
+		return err
+	}
 
 	// pprof run
 	if cfg.Pprof.ServerAddr != "" {
@@ -108,5 +120,9 @@ func fillEmpty(s string) string {
 // Сравнение результатов
 // go tool pprof -top -diff_base=profiles/base.pprof profiles/result.pprof
 
+// Миграции схемы базы данных
+// go run . migrate status -d "host=localhost user=bob password=bob dbname=shortener sslmode=disable"
+// go run . migrate down 1 -d ...
+
 // Флаги сборки (флаги линковщика)
 // go run -ldflags "-X main.buildVersion=v1.0.1" main.go
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/migrate"
+)
+
+// migrateUsage - справка по подкоманде migrate
+const migrateUsage = "usage: shortener migrate <up|down [steps]|status> [-d dsn]"
+
+// runMigrate - подкоманда управления схемой базы данных
+//
+// shortener migrate up -d "host=localhost user=bob password=bob dbname=shortener sslmode=disable"
+// shortener migrate down 1 -d ...
+// shortener migrate status -d ...
+// shortener migrate up -d sqlite://shortener.db
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	action := args[0]
+	args = args[1:]
+
+	// Кол-во откатываемых шагов
+	steps := 1
+	if action == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			steps = n
+			args = args[1:]
+		}
+	}
+
+	// Остальные аргументы - обычные флаги сервиса
+	os.Args = append([]string{os.Args[0]}, args...)
+	cfg := config.GetConfig()
+	if cfg.Repository.DBDsn == "" {
+		return errors.New("database dsn is not set")
+	}
+
+	db, dialect, err := repository.OpenDB(cfg.Repository.DBDsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		done, err := migrator.Up(ctx)
+		printMigrations("applied", done)
+		return err
+	case "down":
+		done, err := migrator.Down(ctx, steps)
+		printMigrations("rolled back", done)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			if st.Applied {
+				fmt.Printf("%04d_%s\tapplied at %s\n", st.Version, st.Name, st.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d_%s\tpending\n", st.Version, st.Name)
+			}
+		}
+		return nil
+	}
+	return errors.New(migrateUsage)
+}
+
+// printMigrations выводит список выполненных шагов
+func printMigrations(verb string, migrations []migrate.Migration) {
+	if len(migrations) == 0 {
+		fmt.Println("no migrations " + verb)
+		return
+	}
+	for _, m := range migrations {
+		fmt.Printf("%s %04d_%s\n", verb, m.Version, m.Name)
+	}
+}
@@ -2,23 +2,19 @@
 package rand
 
 import (
-	"math/rand"
-	"time"
+	"math/rand/v2"
 )
 
 // Набор символов по умолчанию
 const charset = "abcdefghijklmnopqrstuvwxyz" +
 	"ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
-// Числовой рандомайзер
-var seededRand *rand.Rand = rand.New(
-	rand.NewSource(time.Now().UnixNano()))
-
-// Получить случайную строку из определенного набора символов
+// Получить случайную строку из определенного набора символов.
+// Безопасно для конкурентного использования (общий генератор math/rand/v2)
 func StringWithCharset(length int, charset string) string {
 	b := make([]byte, length)
 	for i := range b {
-		b[i] = charset[seededRand.Intn(len(charset))]
+		b[i] = charset[rand.IntN(len(charset))]
 	}
 	return string(b)
 }
@@ -6,12 +6,15 @@ import (
 	"encoding/json"
 	"flag"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	grpcServerConfig "github.com/iurnickita/vigilant-train/internal/shortener/grpc_server/server/config"
 	handlersConfig "github.com/iurnickita/vigilant-train/internal/shortener/handlers/config"
 	loggerConfig "github.com/iurnickita/vigilant-train/internal/shortener/logger/config"
 	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	serviceConfig "github.com/iurnickita/vigilant-train/internal/shortener/service/config"
 )
 
 // Config - общая конфигурация
@@ -20,6 +23,7 @@ type Config struct {
 	GRPCServer grpcServerConfig.Config
 	Logger     loggerConfig.Config
 	Repository repositoryConfig.Config
+	Service    serviceConfig.Config
 	Pprof      PprofConfig
 }
 
@@ -38,10 +42,24 @@ func GetConfig() Config {
 	flag.StringVar(&cfg.Handlers.BaseAddr, "b", "localhost:8080", "address of short URL")
 	flag.StringVar(&cfg.Logger.LogLevel, "l", "info", "log level")
 	flag.StringVar(&cfg.Repository.Filename, "f", "", "file path")
+	flag.DurationVar(&cfg.Repository.CompactInterval, "fc", 10*time.Minute, "file storage compaction interval")
 	flag.StringVar(&cfg.Repository.DBDsn, "d", "", "database dsn")
+	flag.StringVar(&cfg.Repository.KVPath, "k", "", "key-value storage path")
+	flag.IntVar(&cfg.Repository.CacheSize, "cs", 0, "read cache size, links (0 - no cache)")
+	flag.DurationVar(&cfg.Repository.CacheTTL, "ct", time.Minute, "read cache entry lifetime")
+	flag.StringVar(&cfg.Service.CodeGenerator, "g", serviceConfig.GeneratorRandom, "short code generator: random, sequence, hash, hashids")
+	flag.IntVar(&cfg.Service.CodeLength, "gl", serviceConfig.DefaultCodeLength, "short code length")
+	flag.StringVar(&cfg.Service.CodeAlphabet, "ga", serviceConfig.DefaultCodeAlphabet, "short code alphabet")
+	flag.StringVar(&cfg.Service.CodeSalt, "gs", "", "short code salt (hash, hashids)")
+	flag.IntVar(&cfg.Service.CodeAttempts, "gr", serviceConfig.DefaultCodeAttempts, "short code attempts on collision")
+	flag.Uint64Var(&cfg.Service.CodeBlockSize, "gb", serviceConfig.DefaultCodeBlockSize, "short code ID block leased from storage (sequence, hashids)")
+	flag.DurationVar(&cfg.Service.TrashGrace, "tg", serviceConfig.DefaultTrashGrace, "deleted link restore period")
+	flag.DurationVar(&cfg.Service.TrashRetention, "tr", serviceConfig.DefaultTrashRetention, "deleted link retention before purge")
+	flag.StringVar(&cfg.Service.Policy.RulesFile, "pf", "", "destination policy rules file")
+	flag.DurationVar(&cfg.Service.Policy.ReloadInterval, "pr", 10*time.Second, "destination policy rules reload check interval")
 	flag.StringVar(&cfg.Pprof.ServerAddr, "p", "", "address of Pprof server") // "localhost:6060" - не заполняю по умолчанию, потому что занятый порт мешает тестам
 	flag.BoolVar(&cfg.Handlers.EnableHTTPS, "s", false, "enable HTTPS on server")
-	flag.StringVar(&cfg.Handlers.TrustedSubnet, "t", "", "trusted subnet")
+	flag.StringVar(&cfg.Handlers.TrustedSubnet, "t", "", "trusted subnet in CIDR notation")
 	cfg.GRPCServer.TrustedSubnet = cfg.Handlers.TrustedSubnet
 
 	flag.StringVar(&cfgFileName, "c", "", "config file")
@@ -60,9 +78,75 @@ func GetConfig() Config {
 	if envspath := os.Getenv("FILE_STORAGE_PATH"); envspath != "" {
 		cfg.Repository.Filename = envspath
 	}
+	if envcompact := os.Getenv("FILE_COMPACT_INTERVAL"); envcompact != "" {
+		if interval, err := time.ParseDuration(envcompact); err == nil {
+			cfg.Repository.CompactInterval = interval
+		}
+	}
+	if envkv := os.Getenv("KV_STORAGE_PATH"); envkv != "" {
+		cfg.Repository.KVPath = envkv
+	}
 	if envdbase := os.Getenv("DATABASE_DSN"); envdbase != "" {
 		cfg.Repository.DBDsn = envdbase
 	}
+	if envcache := os.Getenv("CACHE_SIZE"); envcache != "" {
+		if size, err := strconv.Atoi(envcache); err == nil {
+			cfg.Repository.CacheSize = size
+		}
+	}
+	if envcachettl := os.Getenv("CACHE_TTL"); envcachettl != "" {
+		if ttl, err := time.ParseDuration(envcachettl); err == nil {
+			cfg.Repository.CacheTTL = ttl
+		}
+	}
+	if envgen := os.Getenv("CODE_GENERATOR"); envgen != "" {
+		cfg.Service.CodeGenerator = envgen
+	}
+	if envlen := os.Getenv("CODE_LENGTH"); envlen != "" {
+		if length, err := strconv.Atoi(envlen); err == nil {
+			cfg.Service.CodeLength = length
+		}
+	}
+	if envalphabet := os.Getenv("CODE_ALPHABET"); envalphabet != "" {
+		cfg.Service.CodeAlphabet = envalphabet
+	}
+	if envsalt := os.Getenv("CODE_SALT"); envsalt != "" {
+		cfg.Service.CodeSalt = envsalt
+	}
+	if envattempts := os.Getenv("CODE_ATTEMPTS"); envattempts != "" {
+		if attempts, err := strconv.Atoi(envattempts); err == nil {
+			cfg.Service.CodeAttempts = attempts
+		}
+	}
+	if envblock := os.Getenv("CODE_BLOCK_SIZE"); envblock != "" {
+		if size, err := strconv.ParseUint(envblock, 10, 64); err == nil {
+			cfg.Service.CodeBlockSize = size
+		}
+	}
+	if envclicksalt := os.Getenv("CLICK_SALT"); envclicksalt != "" {
+		cfg.Service.ClickSalt = envclicksalt
+	}
+	if envlinksecret := os.Getenv("LINK_SECRET"); envlinksecret != "" {
+		cfg.Handlers.LinkSecret = envlinksecret
+	}
+	if envgrace := os.Getenv("TRASH_GRACE"); envgrace != "" {
+		if grace, err := time.ParseDuration(envgrace); err == nil {
+			cfg.Service.TrashGrace = grace
+		}
+	}
+	if envretention := os.Getenv("TRASH_RETENTION"); envretention != "" {
+		if retention, err := time.ParseDuration(envretention); err == nil {
+			cfg.Service.TrashRetention = retention
+		}
+	}
+	if envpolicy := os.Getenv("POLICY_FILE"); envpolicy != "" {
+		cfg.Service.Policy.RulesFile = envpolicy
+	}
+	if envreload := os.Getenv("POLICY_RELOAD_INTERVAL"); envreload != "" {
+		if interval, err := time.ParseDuration(envreload); err == nil {
+			cfg.Service.Policy.ReloadInterval = interval
+		}
+	}
 	if _, envset := os.LookupEnv("ENABLE_HTTPS"); envset {
 		cfg.Handlers.EnableHTTPS = true
 	}
@@ -83,8 +167,12 @@ func GetConfig() Config {
 	/* 	if cfg.Repository.DBDsn == "" {
 		cfg.Repository.DBDsn = "host=localhost user=bob password=bob dbname=shortener sslmode=disable"
 	} */
-	if cfg.Repository.DBDsn != "" {
+	if strings.HasPrefix(cfg.Repository.DBDsn, repositoryConfig.SQLiteScheme) {
+		cfg.Repository.StoreType = repositoryConfig.StoreTypeSQLite
+	} else if cfg.Repository.DBDsn != "" {
 		cfg.Repository.StoreType = repositoryConfig.StoreTypeDB
+	} else if cfg.Repository.KVPath != "" {
+		cfg.Repository.StoreType = repositoryConfig.StoreTypeKV
 	} else if cfg.Repository.Filename != "" {
 		cfg.Repository.StoreType = repositoryConfig.StoreTypeFile
 	} else {
@@ -106,6 +194,11 @@ type ConfigJSON struct {
 	BaseURL         string `json:"base_url"`
 	FileStoragePath string `json:"file_storage_path"`
 	DatabaseDSN     string `json:"database_dsn"`
+	KVStoragePath   string `json:"kv_storage_path"`
+	CacheSize       int    `json:"cache_size"`
+	CodeGenerator   string `json:"code_generator"`
+	CodeSalt        string `json:"code_salt"`
+	PolicyFile      string `json:"policy_file"`
 	EnableHTTPS     bool   `json:"enable_https"`
 	TrustedSubnet   string `json:"trusted_subnet"`
 }
@@ -145,6 +238,21 @@ func getConfigFile(cfg *Config, file string) {
 	if cfg.Repository.DBDsn == "" {
 		cfg.Repository.DBDsn = cfgJSON.DatabaseDSN
 	}
+	if cfg.Repository.KVPath == "" {
+		cfg.Repository.KVPath = cfgJSON.KVStoragePath
+	}
+	if cfg.Repository.CacheSize == 0 {
+		cfg.Repository.CacheSize = cfgJSON.CacheSize
+	}
+	if cfg.Service.CodeGenerator == serviceConfig.GeneratorRandom && cfgJSON.CodeGenerator != "" {
+		cfg.Service.CodeGenerator = cfgJSON.CodeGenerator
+	}
+	if cfg.Service.CodeSalt == "" {
+		cfg.Service.CodeSalt = cfgJSON.CodeSalt
+	}
+	if cfg.Service.Policy.RulesFile == "" {
+		cfg.Service.Policy.RulesFile = cfgJSON.PolicyFile
+	}
 	if cfg.Handlers.TrustedSubnet == "" {
 		cfg.Handlers.TrustedSubnet = cfgJSON.TrustedSubnet
 		cfg.GRPCServer.TrustedSubnet = cfgJSON.TrustedSubnet
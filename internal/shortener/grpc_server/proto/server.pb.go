@@ -155,7 +155,8 @@ func (x *RegisterResponse) GetToken() string {
 
 type GetShortenerRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"` // короткая ссылка
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`         // короткая ссылка
+	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"` // пароль защищенной ссылки
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -197,6 +198,13 @@ func (x *GetShortenerRequest) GetCode() string {
 	return ""
 }
 
+func (x *GetShortenerRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
 type GetShortenerResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"` // исходный URL
@@ -251,7 +259,12 @@ func (x *GetShortenerResponse) GetError() string {
 
 type SetShortenerRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"` // исходный URL
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`                                  // исходный URL
+	TtlSeconds    int64                  `protobuf:"varint,2,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"` // срок действия относительно момента создания (0 - не задан)
+	ExpiresAt     int64                  `protobuf:"varint,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`    // срок действия, unix-время в секундах (0 - не задан)
+	Alias         string                 `protobuf:"bytes,4,opt,name=alias,proto3" json:"alias,omitempty"`                              // пользовательский код ссылки (пусто - генерируется)
+	Password      string                 `protobuf:"bytes,5,opt,name=password,proto3" json:"password,omitempty"`                        // пароль для перехода по ссылке (пусто - без пароля)
+	MaxClicks     int32                  `protobuf:"varint,6,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`    // предельное кол-во переходов по ссылке (0 - без ограничения)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -293,10 +306,46 @@ func (x *SetShortenerRequest) GetUrl() string {
 	return ""
 }
 
+func (x *SetShortenerRequest) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *SetShortenerRequest) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+func (x *SetShortenerRequest) GetAlias() string {
+	if x != nil {
+		return x.Alias
+	}
+	return ""
+}
+
+func (x *SetShortenerRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *SetShortenerRequest) GetMaxClicks() int32 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
 type SetShortenerResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"` // короткая ссылка
 	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	ExpiresAt     int64                  `protobuf:"varint,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"` // срок действия, unix-время в секундах (0 - бессрочная)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -345,6 +394,13 @@ func (x *SetShortenerResponse) GetError() string {
 	return ""
 }
 
+func (x *SetShortenerResponse) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
 type PingResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Error         string                 `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
@@ -488,6 +544,7 @@ func (x *DeleteShortenerBatchRequest) GetCode() []string {
 type DeleteShortenerBatchResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Error         string                 `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	JobId         string                 `protobuf:"bytes,2,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"` // задание удаления (см. GetDeleteJob)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -529,28 +586,34 @@ func (x *DeleteShortenerBatchResponse) GetError() string {
 	return ""
 }
 
-type GetStatsResponse struct {
+func (x *DeleteShortenerBatchResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type GetDeleteJobRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Urls          int32                  `protobuf:"varint,1,opt,name=urls,proto3" json:"urls,omitempty"`
-	Users         int32                  `protobuf:"varint,2,opt,name=users,proto3" json:"users,omitempty"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"` // задание удаления
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetStatsResponse) Reset() {
-	*x = GetStatsResponse{}
+func (x *GetDeleteJobRequest) Reset() {
+	*x = GetDeleteJobRequest{}
 	mi := &file_proto_server_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetStatsResponse) String() string {
+func (x *GetDeleteJobRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetStatsResponse) ProtoMessage() {}
+func (*GetDeleteJobRequest) ProtoMessage() {}
 
-func (x *GetStatsResponse) ProtoReflect() protoreflect.Message {
+func (x *GetDeleteJobRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_server_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -562,66 +625,1180 @@ func (x *GetStatsResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetStatsResponse.ProtoReflect.Descriptor instead.
-func (*GetStatsResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use GetDeleteJobRequest.ProtoReflect.Descriptor instead.
+func (*GetDeleteJobRequest) Descriptor() ([]byte, []int) {
 	return file_proto_server_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *GetStatsResponse) GetUrls() int32 {
+func (x *GetDeleteJobRequest) GetId() string {
 	if x != nil {
-		return x.Urls
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteJobCode struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`     // короткая ссылка
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // deleted, already_deleted, not_found (пусто - задание не выполнено)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteJobCode) Reset() {
+	*x = DeleteJobCode{}
+	mi := &file_proto_server_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteJobCode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteJobCode) ProtoMessage() {}
+
+func (x *DeleteJobCode) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteJobCode.ProtoReflect.Descriptor instead.
+func (*DeleteJobCode) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DeleteJobCode) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *DeleteJobCode) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type GetDeleteJobResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // pending, done, failed
+	Codes         []*DeleteJobCode       `protobuf:"bytes,3,rep,name=codes,proto3" json:"codes,omitempty"`
+	Attempts      int32                  `protobuf:"varint,4,opt,name=attempts,proto3" json:"attempts,omitempty"`                    // кол-во неудачных попыток
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`                           // ошибка последней неудачной попытки
+	CreatedAt     int64                  `protobuf:"varint,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"` // unix-время в секундах
+	UpdatedAt     int64                  `protobuf:"varint,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"` // unix-время в секундах
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDeleteJobResponse) Reset() {
+	*x = GetDeleteJobResponse{}
+	mi := &file_proto_server_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDeleteJobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDeleteJobResponse) ProtoMessage() {}
+
+func (x *GetDeleteJobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDeleteJobResponse.ProtoReflect.Descriptor instead.
+func (*GetDeleteJobResponse) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetDeleteJobResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetDeleteJobResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *GetDeleteJobResponse) GetCodes() []*DeleteJobCode {
+	if x != nil {
+		return x.Codes
+	}
+	return nil
+}
+
+func (x *GetDeleteJobResponse) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
 	}
 	return 0
 }
 
-func (x *GetStatsResponse) GetUsers() int32 {
+func (x *GetDeleteJobResponse) GetError() string {
 	if x != nil {
-		return x.Users
+		return x.Error
+	}
+	return ""
+}
+
+func (x *GetDeleteJobResponse) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
 	}
 	return 0
 }
 
-var File_proto_server_proto protoreflect.FileDescriptor
+func (x *GetDeleteJobResponse) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
 
-const file_proto_server_proto_rawDesc = "" +
-	"\n" +
-	"\x12proto/server.proto\x12\vgrpc_server\"\a\n" +
-	"\x05Empty\"/\n" +
-	"\aCodeURL\x12\x12\n" +
-	"\x04code\x18\x01 \x01(\tR\x04code\x12\x10\n" +
-	"\x03url\x18\x02 \x01(\tR\x03url\"(\n" +
-	"\x10RegisterResponse\x12\x14\n" +
-	"\x05token\x18\x01 \x01(\tR\x05token\")\n" +
-	"\x13GetShortenerRequest\x12\x12\n" +
-	"\x04code\x18\x01 \x01(\tR\x04code\">\n" +
-	"\x14GetShortenerResponse\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error\"'\n" +
-	"\x13SetShortenerRequest\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url\"@\n" +
-	"\x14SetShortenerResponse\x12\x12\n" +
-	"\x04code\x18\x01 \x01(\tR\x04code\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error\"$\n" +
-	"\fPingResponse\x12\x14\n" +
-	"\x05error\x18\x01 \x01(\tR\x05error\"[\n" +
-	"\x13GetUserURLsResponse\x12.\n" +
-	"\acodeurl\x18\x01 \x03(\v2\x14.grpc_server.CodeURLR\acodeurl\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error\"1\n" +
-	"\x1bDeleteShortenerBatchRequest\x12\x12\n" +
-	"\x04code\x18\x01 \x03(\tR\x04code\"4\n" +
-	"\x1cDeleteShortenerBatchResponse\x12\x14\n" +
-	"\x05error\x18\x01 \x01(\tR\x05error\"<\n" +
-	"\x10GetStatsResponse\x12\x12\n" +
-	"\x04urls\x18\x01 \x01(\x05R\x04urls\x12\x14\n" +
-	"\x05users\x18\x02 \x01(\x05R\x05users2\x9c\x04\n" +
+type GetClickStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`                                         // короткая ссылка
+	From          int64                  `protobuf:"varint,2,opt,name=from,proto3" json:"from,omitempty"`                                        // начало периода, unix-время в секундах (0 - сутки назад)
+	BucketSeconds int64                  `protobuf:"varint,3,opt,name=bucket_seconds,json=bucketSeconds,proto3" json:"bucket_seconds,omitempty"` // длина интервала в секундах (0 - час)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetClickStatsRequest) Reset() {
+	*x = GetClickStatsRequest{}
+	mi := &file_proto_server_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetClickStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetClickStatsRequest) ProtoMessage() {}
+
+func (x *GetClickStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetClickStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetClickStatsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetClickStatsRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *GetClickStatsRequest) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *GetClickStatsRequest) GetBucketSeconds() int64 {
+	if x != nil {
+		return x.BucketSeconds
+	}
+	return 0
+}
+
+type ClickBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         int64                  `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"` // начало интервала, unix-время в секундах
+	Count         int64                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"` // кол-во переходов (созданных ссылок в статистике за период)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClickBucket) Reset() {
+	*x = ClickBucket{}
+	mi := &file_proto_server_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClickBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClickBucket) ProtoMessage() {}
+
+func (x *ClickBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClickBucket.ProtoReflect.Descriptor instead.
+func (*ClickBucket) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ClickBucket) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *ClickBucket) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type GetClickStatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         int64                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"` // кол-во переходов за все время
+	Buckets       []*ClickBucket         `protobuf:"bytes,2,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetClickStatsResponse) Reset() {
+	*x = GetClickStatsResponse{}
+	mi := &file_proto_server_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetClickStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetClickStatsResponse) ProtoMessage() {}
+
+func (x *GetClickStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetClickStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetClickStatsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *GetClickStatsResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *GetClickStatsResponse) GetBuckets() []*ClickBucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+type GetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          int64                  `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"` // начало периода, unix-время в секундах (0 - неделя назад)
+	To            int64                  `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`     // конец периода, unix-время в секундах (0 - текущий момент)
+	Top           int32                  `protobuf:"varint,3,opt,name=top,proto3" json:"top,omitempty"`   // кол-во ссылок с наибольшим кол-вом переходов (0 - по умолчанию)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_proto_server_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *GetStatsRequest) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *GetStatsRequest) GetTo() int64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *GetStatsRequest) GetTop() int32 {
+	if x != nil {
+		return x.Top
+	}
+	return 0
+}
+
+type LinkClicks struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LinkClicks) Reset() {
+	*x = LinkClicks{}
+	mi := &file_proto_server_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LinkClicks) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkClicks) ProtoMessage() {}
+
+func (x *LinkClicks) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkClicks.ProtoReflect.Descriptor instead.
+func (*LinkClicks) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *LinkClicks) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *LinkClicks) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+type RedirectStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         int64                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	NotFound      int64                  `protobuf:"varint,2,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	Gone          int64                  `protobuf:"varint,3,opt,name=gone,proto3" json:"gone,omitempty"`
+	Blocked       int64                  `protobuf:"varint,4,opt,name=blocked,proto3" json:"blocked,omitempty"`
+	Failed        int64                  `protobuf:"varint,5,opt,name=failed,proto3" json:"failed,omitempty"`
+	ErrorRate     float64                `protobuf:"fixed64,6,opt,name=error_rate,json=errorRate,proto3" json:"error_rate,omitempty"`
+	Since         int64                  `protobuf:"varint,7,opt,name=since,proto3" json:"since,omitempty"` // начало учета (unix-время): счетчики экземпляра сервиса с момента запуска
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RedirectStats) Reset() {
+	*x = RedirectStats{}
+	mi := &file_proto_server_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RedirectStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RedirectStats) ProtoMessage() {}
+
+func (x *RedirectStats) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RedirectStats.ProtoReflect.Descriptor instead.
+func (*RedirectStats) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *RedirectStats) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *RedirectStats) GetNotFound() int64 {
+	if x != nil {
+		return x.NotFound
+	}
+	return 0
+}
+
+func (x *RedirectStats) GetGone() int64 {
+	if x != nil {
+		return x.Gone
+	}
+	return 0
+}
+
+func (x *RedirectStats) GetBlocked() int64 {
+	if x != nil {
+		return x.Blocked
+	}
+	return 0
+}
+
+func (x *RedirectStats) GetFailed() int64 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *RedirectStats) GetErrorRate() float64 {
+	if x != nil {
+		return x.ErrorRate
+	}
+	return 0
+}
+
+func (x *RedirectStats) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+type GetStatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          int32                  `protobuf:"varint,1,opt,name=urls,proto3" json:"urls,omitempty"`
+	Users         int32                  `protobuf:"varint,2,opt,name=users,proto3" json:"users,omitempty"`
+	From          int64                  `protobuf:"varint,3,opt,name=from,proto3" json:"from,omitempty"` // начало периода, выровненное по суткам UTC
+	To            int64                  `protobuf:"varint,4,opt,name=to,proto3" json:"to,omitempty"`     // конец периода, выровненный по суткам UTC
+	DeletedUrls   int32                  `protobuf:"varint,5,opt,name=deleted_urls,json=deletedUrls,proto3" json:"deleted_urls,omitempty"`
+	ActiveUsers   int32                  `protobuf:"varint,6,opt,name=active_users,json=activeUsers,proto3" json:"active_users,omitempty"` // пользователи, создавшие ссылки за период
+	Created       []*ClickBucket         `protobuf:"bytes,7,rep,name=created,proto3" json:"created,omitempty"`                             // созданные ссылки по суткам
+	TopLinks      []*LinkClicks          `protobuf:"bytes,8,rep,name=top_links,json=topLinks,proto3" json:"top_links,omitempty"`
+	Redirects     *RedirectStats         `protobuf:"bytes,9,opt,name=redirects,proto3" json:"redirects,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsResponse) Reset() {
+	*x = GetStatsResponse{}
+	mi := &file_proto_server_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsResponse) ProtoMessage() {}
+
+func (x *GetStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetStatsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *GetStatsResponse) GetUrls() int32 {
+	if x != nil {
+		return x.Urls
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetUsers() int32 {
+	if x != nil {
+		return x.Users
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetTo() int64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetDeletedUrls() int32 {
+	if x != nil {
+		return x.DeletedUrls
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetActiveUsers() int32 {
+	if x != nil {
+		return x.ActiveUsers
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetCreated() []*ClickBucket {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetTopLinks() []*LinkClicks {
+	if x != nil {
+		return x.TopLinks
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetRedirects() *RedirectStats {
+	if x != nil {
+		return x.Redirects
+	}
+	return nil
+}
+
+type UpdateShortenerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`          // короткая ссылка
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`            // новый адрес назначения
+	Revision      int32                  `protobuf:"varint,3,opt,name=revision,proto3" json:"revision,omitempty"` // номер изменения из истории для отката (0 - изменение на url)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateShortenerRequest) Reset() {
+	*x = UpdateShortenerRequest{}
+	mi := &file_proto_server_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateShortenerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateShortenerRequest) ProtoMessage() {}
+
+func (x *UpdateShortenerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateShortenerRequest.ProtoReflect.Descriptor instead.
+func (*UpdateShortenerRequest) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *UpdateShortenerRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *UpdateShortenerRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *UpdateShortenerRequest) GetRevision() int32 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+type UpdateShortenerResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"` // при AlreadyExists - ссылка, уже сокращающая адрес
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateShortenerResponse) Reset() {
+	*x = UpdateShortenerResponse{}
+	mi := &file_proto_server_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateShortenerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateShortenerResponse) ProtoMessage() {}
+
+func (x *UpdateShortenerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateShortenerResponse.ProtoReflect.Descriptor instead.
+func (*UpdateShortenerResponse) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *UpdateShortenerResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *UpdateShortenerResponse) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type GetRevisionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRevisionsRequest) Reset() {
+	*x = GetRevisionsRequest{}
+	mi := &file_proto_server_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRevisionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRevisionsRequest) ProtoMessage() {}
+
+func (x *GetRevisionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRevisionsRequest.ProtoReflect.Descriptor instead.
+func (*GetRevisionsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *GetRevisionsRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type Revision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revision      int32                  `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	CreatedAt     int64                  `protobuf:"varint,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"` // unix-время в секундах
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Revision) Reset() {
+	*x = Revision{}
+	mi := &file_proto_server_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Revision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Revision) ProtoMessage() {}
+
+func (x *Revision) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Revision.ProtoReflect.Descriptor instead.
+func (*Revision) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *Revision) GetRevision() int32 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *Revision) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Revision) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+type GetRevisionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revisions     []*Revision            `protobuf:"bytes,1,rep,name=revisions,proto3" json:"revisions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRevisionsResponse) Reset() {
+	*x = GetRevisionsResponse{}
+	mi := &file_proto_server_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRevisionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRevisionsResponse) ProtoMessage() {}
+
+func (x *GetRevisionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRevisionsResponse.ProtoReflect.Descriptor instead.
+func (*GetRevisionsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *GetRevisionsResponse) GetRevisions() []*Revision {
+	if x != nil {
+		return x.Revisions
+	}
+	return nil
+}
+
+type DeletedURL struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`                             // короткая ссылка
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`                               // исходный URL
+	DeletedAt     int64                  `protobuf:"varint,3,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"` // время удаления, unix-время в секундах (0 - неизвестно)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletedURL) Reset() {
+	*x = DeletedURL{}
+	mi := &file_proto_server_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletedURL) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletedURL) ProtoMessage() {}
+
+func (x *DeletedURL) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletedURL.ProtoReflect.Descriptor instead.
+func (*DeletedURL) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *DeletedURL) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *DeletedURL) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *DeletedURL) GetDeletedAt() int64 {
+	if x != nil {
+		return x.DeletedAt
+	}
+	return 0
+}
+
+type GetTrashResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Urls          []*DeletedURL          `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTrashResponse) Reset() {
+	*x = GetTrashResponse{}
+	mi := &file_proto_server_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTrashResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTrashResponse) ProtoMessage() {}
+
+func (x *GetTrashResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTrashResponse.ProtoReflect.Descriptor instead.
+func (*GetTrashResponse) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *GetTrashResponse) GetUrls() []*DeletedURL {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+type RestoreShortenerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"` // короткая ссылка
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestoreShortenerRequest) Reset() {
+	*x = RestoreShortenerRequest{}
+	mi := &file_proto_server_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreShortenerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreShortenerRequest) ProtoMessage() {}
+
+func (x *RestoreShortenerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_server_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreShortenerRequest.ProtoReflect.Descriptor instead.
+func (*RestoreShortenerRequest) Descriptor() ([]byte, []int) {
+	return file_proto_server_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *RestoreShortenerRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+var File_proto_server_proto protoreflect.FileDescriptor
+
+const file_proto_server_proto_rawDesc = "" +
+	"\n" +
+	"\x12proto/server.proto\x12\vgrpc_server\"\a\n" +
+	"\x05Empty\"/\n" +
+	"\aCodeURL\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\"(\n" +
+	"\x10RegisterResponse\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\"E\n" +
+	"\x13GetShortenerRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\">\n" +
+	"\x14GetShortenerResponse\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\xb8\x01\n" +
+	"\x13SetShortenerRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x1f\n" +
+	"\vttl_seconds\x18\x02 \x01(\x03R\n" +
+	"ttlSeconds\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\x03R\texpiresAt\x12\x14\n" +
+	"\x05alias\x18\x04 \x01(\tR\x05alias\x12\x1a\n" +
+	"\bpassword\x18\x05 \x01(\tR\bpassword\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\x06 \x01(\x05R\tmaxClicks\"_\n" +
+	"\x14SetShortenerResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\x03R\texpiresAt\"$\n" +
+	"\fPingResponse\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\"[\n" +
+	"\x13GetUserURLsResponse\x12.\n" +
+	"\acodeurl\x18\x01 \x03(\v2\x14.grpc_server.CodeURLR\acodeurl\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"1\n" +
+	"\x1bDeleteShortenerBatchRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x03(\tR\x04code\"K\n" +
+	"\x1cDeleteShortenerBatchResponse\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\x12\x15\n" +
+	"\x06job_id\x18\x02 \x01(\tR\x05jobId\"%\n" +
+	"\x13GetDeleteJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\";\n" +
+	"\rDeleteJobCode\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\"\xe0\x01\n" +
+	"\x14GetDeleteJobResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x120\n" +
+	"\x05codes\x18\x03 \x03(\v2\x1a.grpc_server.DeleteJobCodeR\x05codes\x12\x1a\n" +
+	"\battempts\x18\x04 \x01(\x05R\battempts\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\x03R\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\a \x01(\x03R\tupdatedAt\"e\n" +
+	"\x14GetClickStatsRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\x03R\x04from\x12%\n" +
+	"\x0ebucket_seconds\x18\x03 \x01(\x03R\rbucketSeconds\"9\n" +
+	"\vClickBucket\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x03R\x05start\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\"a\n" +
+	"\x15GetClickStatsResponse\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x03R\x05total\x122\n" +
+	"\abuckets\x18\x02 \x03(\v2\x18.grpc_server.ClickBucketR\abuckets\"G\n" +
+	"\x0fGetStatsRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\x03R\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\x03R\x02to\x12\x10\n" +
+	"\x03top\x18\x03 \x01(\x05R\x03top\"8\n" +
+	"\n" +
+	"LinkClicks\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\"\xbd\x01\n" +
+	"\rRedirectStats\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x03R\x05total\x12\x1b\n" +
+	"\tnot_found\x18\x02 \x01(\x03R\bnotFound\x12\x12\n" +
+	"\x04gone\x18\x03 \x01(\x03R\x04gone\x12\x18\n" +
+	"\ablocked\x18\x04 \x01(\x03R\ablocked\x12\x16\n" +
+	"\x06failed\x18\x05 \x01(\x03R\x06failed\x12\x1d\n" +
+	"\n" +
+	"error_rate\x18\x06 \x01(\x01R\terrorRate\x12\x14\n" +
+	"\x05since\x18\a \x01(\x03R\x05since\"\xca\x02\n" +
+	"\x10GetStatsResponse\x12\x12\n" +
+	"\x04urls\x18\x01 \x01(\x05R\x04urls\x12\x14\n" +
+	"\x05users\x18\x02 \x01(\x05R\x05users\x12\x12\n" +
+	"\x04from\x18\x03 \x01(\x03R\x04from\x12\x0e\n" +
+	"\x02to\x18\x04 \x01(\x03R\x02to\x12!\n" +
+	"\fdeleted_urls\x18\x05 \x01(\x05R\vdeletedUrls\x12!\n" +
+	"\factive_users\x18\x06 \x01(\x05R\vactiveUsers\x122\n" +
+	"\acreated\x18\a \x03(\v2\x18.grpc_server.ClickBucketR\acreated\x124\n" +
+	"\ttop_links\x18\b \x03(\v2\x17.grpc_server.LinkClicksR\btopLinks\x128\n" +
+	"\tredirects\x18\t \x01(\v2\x1a.grpc_server.RedirectStatsR\tredirects\"Z\n" +
+	"\x16UpdateShortenerRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x1a\n" +
+	"\brevision\x18\x03 \x01(\x05R\brevision\"?\n" +
+	"\x17UpdateShortenerResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\")\n" +
+	"\x13GetRevisionsRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\"W\n" +
+	"\bRevision\x12\x1a\n" +
+	"\brevision\x18\x01 \x01(\x05R\brevision\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x03 \x01(\x03R\tcreatedAt\"K\n" +
+	"\x14GetRevisionsResponse\x123\n" +
+	"\trevisions\x18\x01 \x03(\v2\x15.grpc_server.RevisionR\trevisions\"Q\n" +
+	"\n" +
+	"DeletedURL\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x1d\n" +
+	"\n" +
+	"deleted_at\x18\x03 \x01(\x03R\tdeletedAt\"?\n" +
+	"\x10GetTrashResponse\x12+\n" +
+	"\x04urls\x18\x01 \x03(\v2\x17.grpc_server.DeletedURLR\x04urls\"-\n" +
+	"\x17RestoreShortenerRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code2\x95\b\n" +
 	"\tShortener\x12=\n" +
 	"\bRegister\x12\x12.grpc_server.Empty\x1a\x1d.grpc_server.RegisterResponse\x12S\n" +
 	"\fGetShortener\x12 .grpc_server.GetShortenerRequest\x1a!.grpc_server.GetShortenerResponse\x12S\n" +
 	"\fSetShortener\x12 .grpc_server.SetShortenerRequest\x1a!.grpc_server.SetShortenerResponse\x125\n" +
 	"\x04Ping\x12\x12.grpc_server.Empty\x1a\x19.grpc_server.PingResponse\x12C\n" +
 	"\vGetUserURLs\x12\x12.grpc_server.Empty\x1a .grpc_server.GetUserURLsResponse\x12k\n" +
-	"\x14DeleteShortenerBatch\x12(.grpc_server.DeleteShortenerBatchRequest\x1a).grpc_server.DeleteShortenerBatchResponse\x12=\n" +
-	"\bGetStats\x12\x12.grpc_server.Empty\x1a\x1d.grpc_server.GetStatsResponseB&Z$internal/shortener/grpc_server/protob\x06proto3"
+	"\x14DeleteShortenerBatch\x12(.grpc_server.DeleteShortenerBatchRequest\x1a).grpc_server.DeleteShortenerBatchResponse\x12G\n" +
+	"\bGetStats\x12\x1c.grpc_server.GetStatsRequest\x1a\x1d.grpc_server.GetStatsResponse\x12V\n" +
+	"\rGetClickStats\x12!.grpc_server.GetClickStatsRequest\x1a\".grpc_server.GetClickStatsResponse\x12\\\n" +
+	"\x0fUpdateShortener\x12#.grpc_server.UpdateShortenerRequest\x1a$.grpc_server.UpdateShortenerResponse\x12S\n" +
+	"\fGetRevisions\x12 .grpc_server.GetRevisionsRequest\x1a!.grpc_server.GetRevisionsResponse\x12=\n" +
+	"\bGetTrash\x12\x12.grpc_server.Empty\x1a\x1d.grpc_server.GetTrashResponse\x12N\n" +
+	"\x10RestoreShortener\x12$.grpc_server.RestoreShortenerRequest\x1a\x14.grpc_server.CodeURL\x12S\n" +
+	"\fGetDeleteJob\x12 .grpc_server.GetDeleteJobRequest\x1a!.grpc_server.GetDeleteJobResponseB&Z$internal/shortener/grpc_server/protob\x06proto3"
 
 var (
 	file_proto_server_proto_rawDescOnce sync.Once
@@ -635,7 +1812,7 @@ func file_proto_server_proto_rawDescGZIP() []byte {
 	return file_proto_server_proto_rawDescData
 }
 
-var file_proto_server_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_proto_server_proto_msgTypes = make([]protoimpl.MessageInfo, 29)
 var file_proto_server_proto_goTypes = []any{
 	(*Empty)(nil),                        // 0: grpc_server.Empty
 	(*CodeURL)(nil),                      // 1: grpc_server.CodeURL
@@ -648,29 +1825,65 @@ var file_proto_server_proto_goTypes = []any{
 	(*GetUserURLsResponse)(nil),          // 8: grpc_server.GetUserURLsResponse
 	(*DeleteShortenerBatchRequest)(nil),  // 9: grpc_server.DeleteShortenerBatchRequest
 	(*DeleteShortenerBatchResponse)(nil), // 10: grpc_server.DeleteShortenerBatchResponse
-	(*GetStatsResponse)(nil),             // 11: grpc_server.GetStatsResponse
+	(*GetDeleteJobRequest)(nil),          // 11: grpc_server.GetDeleteJobRequest
+	(*DeleteJobCode)(nil),                // 12: grpc_server.DeleteJobCode
+	(*GetDeleteJobResponse)(nil),         // 13: grpc_server.GetDeleteJobResponse
+	(*GetClickStatsRequest)(nil),         // 14: grpc_server.GetClickStatsRequest
+	(*ClickBucket)(nil),                  // 15: grpc_server.ClickBucket
+	(*GetClickStatsResponse)(nil),        // 16: grpc_server.GetClickStatsResponse
+	(*GetStatsRequest)(nil),              // 17: grpc_server.GetStatsRequest
+	(*LinkClicks)(nil),                   // 18: grpc_server.LinkClicks
+	(*RedirectStats)(nil),                // 19: grpc_server.RedirectStats
+	(*GetStatsResponse)(nil),             // 20: grpc_server.GetStatsResponse
+	(*UpdateShortenerRequest)(nil),       // 21: grpc_server.UpdateShortenerRequest
+	(*UpdateShortenerResponse)(nil),      // 22: grpc_server.UpdateShortenerResponse
+	(*GetRevisionsRequest)(nil),          // 23: grpc_server.GetRevisionsRequest
+	(*Revision)(nil),                     // 24: grpc_server.Revision
+	(*GetRevisionsResponse)(nil),         // 25: grpc_server.GetRevisionsResponse
+	(*DeletedURL)(nil),                   // 26: grpc_server.DeletedURL
+	(*GetTrashResponse)(nil),             // 27: grpc_server.GetTrashResponse
+	(*RestoreShortenerRequest)(nil),      // 28: grpc_server.RestoreShortenerRequest
 }
 var file_proto_server_proto_depIdxs = []int32{
 	1,  // 0: grpc_server.GetUserURLsResponse.codeurl:type_name -> grpc_server.CodeURL
-	0,  // 1: grpc_server.Shortener.Register:input_type -> grpc_server.Empty
-	3,  // 2: grpc_server.Shortener.GetShortener:input_type -> grpc_server.GetShortenerRequest
-	5,  // 3: grpc_server.Shortener.SetShortener:input_type -> grpc_server.SetShortenerRequest
-	0,  // 4: grpc_server.Shortener.Ping:input_type -> grpc_server.Empty
-	0,  // 5: grpc_server.Shortener.GetUserURLs:input_type -> grpc_server.Empty
-	9,  // 6: grpc_server.Shortener.DeleteShortenerBatch:input_type -> grpc_server.DeleteShortenerBatchRequest
-	0,  // 7: grpc_server.Shortener.GetStats:input_type -> grpc_server.Empty
-	2,  // 8: grpc_server.Shortener.Register:output_type -> grpc_server.RegisterResponse
-	4,  // 9: grpc_server.Shortener.GetShortener:output_type -> grpc_server.GetShortenerResponse
-	6,  // 10: grpc_server.Shortener.SetShortener:output_type -> grpc_server.SetShortenerResponse
-	7,  // 11: grpc_server.Shortener.Ping:output_type -> grpc_server.PingResponse
-	8,  // 12: grpc_server.Shortener.GetUserURLs:output_type -> grpc_server.GetUserURLsResponse
-	10, // 13: grpc_server.Shortener.DeleteShortenerBatch:output_type -> grpc_server.DeleteShortenerBatchResponse
-	11, // 14: grpc_server.Shortener.GetStats:output_type -> grpc_server.GetStatsResponse
-	8,  // [8:15] is the sub-list for method output_type
-	1,  // [1:8] is the sub-list for method input_type
-	1,  // [1:1] is the sub-list for extension type_name
-	1,  // [1:1] is the sub-list for extension extendee
-	0,  // [0:1] is the sub-list for field type_name
+	12, // 1: grpc_server.GetDeleteJobResponse.codes:type_name -> grpc_server.DeleteJobCode
+	15, // 2: grpc_server.GetClickStatsResponse.buckets:type_name -> grpc_server.ClickBucket
+	15, // 3: grpc_server.GetStatsResponse.created:type_name -> grpc_server.ClickBucket
+	18, // 4: grpc_server.GetStatsResponse.top_links:type_name -> grpc_server.LinkClicks
+	19, // 5: grpc_server.GetStatsResponse.redirects:type_name -> grpc_server.RedirectStats
+	24, // 6: grpc_server.GetRevisionsResponse.revisions:type_name -> grpc_server.Revision
+	26, // 7: grpc_server.GetTrashResponse.urls:type_name -> grpc_server.DeletedURL
+	0,  // 8: grpc_server.Shortener.Register:input_type -> grpc_server.Empty
+	3,  // 9: grpc_server.Shortener.GetShortener:input_type -> grpc_server.GetShortenerRequest
+	5,  // 10: grpc_server.Shortener.SetShortener:input_type -> grpc_server.SetShortenerRequest
+	0,  // 11: grpc_server.Shortener.Ping:input_type -> grpc_server.Empty
+	0,  // 12: grpc_server.Shortener.GetUserURLs:input_type -> grpc_server.Empty
+	9,  // 13: grpc_server.Shortener.DeleteShortenerBatch:input_type -> grpc_server.DeleteShortenerBatchRequest
+	17, // 14: grpc_server.Shortener.GetStats:input_type -> grpc_server.GetStatsRequest
+	14, // 15: grpc_server.Shortener.GetClickStats:input_type -> grpc_server.GetClickStatsRequest
+	21, // 16: grpc_server.Shortener.UpdateShortener:input_type -> grpc_server.UpdateShortenerRequest
+	23, // 17: grpc_server.Shortener.GetRevisions:input_type -> grpc_server.GetRevisionsRequest
+	0,  // 18: grpc_server.Shortener.GetTrash:input_type -> grpc_server.Empty
+	28, // 19: grpc_server.Shortener.RestoreShortener:input_type -> grpc_server.RestoreShortenerRequest
+	11, // 20: grpc_server.Shortener.GetDeleteJob:input_type -> grpc_server.GetDeleteJobRequest
+	2,  // 21: grpc_server.Shortener.Register:output_type -> grpc_server.RegisterResponse
+	4,  // 22: grpc_server.Shortener.GetShortener:output_type -> grpc_server.GetShortenerResponse
+	6,  // 23: grpc_server.Shortener.SetShortener:output_type -> grpc_server.SetShortenerResponse
+	7,  // 24: grpc_server.Shortener.Ping:output_type -> grpc_server.PingResponse
+	8,  // 25: grpc_server.Shortener.GetUserURLs:output_type -> grpc_server.GetUserURLsResponse
+	10, // 26: grpc_server.Shortener.DeleteShortenerBatch:output_type -> grpc_server.DeleteShortenerBatchResponse
+	20, // 27: grpc_server.Shortener.GetStats:output_type -> grpc_server.GetStatsResponse
+	16, // 28: grpc_server.Shortener.GetClickStats:output_type -> grpc_server.GetClickStatsResponse
+	22, // 29: grpc_server.Shortener.UpdateShortener:output_type -> grpc_server.UpdateShortenerResponse
+	25, // 30: grpc_server.Shortener.GetRevisions:output_type -> grpc_server.GetRevisionsResponse
+	27, // 31: grpc_server.Shortener.GetTrash:output_type -> grpc_server.GetTrashResponse
+	1,  // 32: grpc_server.Shortener.RestoreShortener:output_type -> grpc_server.CodeURL
+	13, // 33: grpc_server.Shortener.GetDeleteJob:output_type -> grpc_server.GetDeleteJobResponse
+	21, // [21:34] is the sub-list for method output_type
+	8,  // [8:21] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_proto_server_proto_init() }
@@ -684,7 +1897,7 @@ func file_proto_server_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_server_proto_rawDesc), len(file_proto_server_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   12,
+			NumMessages:   29,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -26,6 +26,12 @@ const (
 	Shortener_GetUserURLs_FullMethodName          = "/grpc_server.Shortener/GetUserURLs"
 	Shortener_DeleteShortenerBatch_FullMethodName = "/grpc_server.Shortener/DeleteShortenerBatch"
 	Shortener_GetStats_FullMethodName             = "/grpc_server.Shortener/GetStats"
+	Shortener_GetClickStats_FullMethodName        = "/grpc_server.Shortener/GetClickStats"
+	Shortener_UpdateShortener_FullMethodName      = "/grpc_server.Shortener/UpdateShortener"
+	Shortener_GetRevisions_FullMethodName         = "/grpc_server.Shortener/GetRevisions"
+	Shortener_GetTrash_FullMethodName             = "/grpc_server.Shortener/GetTrash"
+	Shortener_RestoreShortener_FullMethodName     = "/grpc_server.Shortener/RestoreShortener"
+	Shortener_GetDeleteJob_FullMethodName         = "/grpc_server.Shortener/GetDeleteJob"
 )
 
 // ShortenerClient is the client API for Shortener service.
@@ -38,7 +44,13 @@ type ShortenerClient interface {
 	Ping(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*PingResponse, error)
 	GetUserURLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetUserURLsResponse, error)
 	DeleteShortenerBatch(ctx context.Context, in *DeleteShortenerBatchRequest, opts ...grpc.CallOption) (*DeleteShortenerBatchResponse, error)
-	GetStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetStatsResponse, error)
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
+	GetClickStats(ctx context.Context, in *GetClickStatsRequest, opts ...grpc.CallOption) (*GetClickStatsResponse, error)
+	UpdateShortener(ctx context.Context, in *UpdateShortenerRequest, opts ...grpc.CallOption) (*UpdateShortenerResponse, error)
+	GetRevisions(ctx context.Context, in *GetRevisionsRequest, opts ...grpc.CallOption) (*GetRevisionsResponse, error)
+	GetTrash(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetTrashResponse, error)
+	RestoreShortener(ctx context.Context, in *RestoreShortenerRequest, opts ...grpc.CallOption) (*CodeURL, error)
+	GetDeleteJob(ctx context.Context, in *GetDeleteJobRequest, opts ...grpc.CallOption) (*GetDeleteJobResponse, error)
 }
 
 type shortenerClient struct {
@@ -109,7 +121,7 @@ func (c *shortenerClient) DeleteShortenerBatch(ctx context.Context, in *DeleteSh
 	return out, nil
 }
 
-func (c *shortenerClient) GetStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+func (c *shortenerClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetStatsResponse)
 	err := c.cc.Invoke(ctx, Shortener_GetStats_FullMethodName, in, out, cOpts...)
@@ -119,6 +131,66 @@ func (c *shortenerClient) GetStats(ctx context.Context, in *Empty, opts ...grpc.
 	return out, nil
 }
 
+func (c *shortenerClient) GetClickStats(ctx context.Context, in *GetClickStatsRequest, opts ...grpc.CallOption) (*GetClickStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetClickStatsResponse)
+	err := c.cc.Invoke(ctx, Shortener_GetClickStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) UpdateShortener(ctx context.Context, in *UpdateShortenerRequest, opts ...grpc.CallOption) (*UpdateShortenerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateShortenerResponse)
+	err := c.cc.Invoke(ctx, Shortener_UpdateShortener_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetRevisions(ctx context.Context, in *GetRevisionsRequest, opts ...grpc.CallOption) (*GetRevisionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRevisionsResponse)
+	err := c.cc.Invoke(ctx, Shortener_GetRevisions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetTrash(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetTrashResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetTrashResponse)
+	err := c.cc.Invoke(ctx, Shortener_GetTrash_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) RestoreShortener(ctx context.Context, in *RestoreShortenerRequest, opts ...grpc.CallOption) (*CodeURL, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CodeURL)
+	err := c.cc.Invoke(ctx, Shortener_RestoreShortener_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetDeleteJob(ctx context.Context, in *GetDeleteJobRequest, opts ...grpc.CallOption) (*GetDeleteJobResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetDeleteJobResponse)
+	err := c.cc.Invoke(ctx, Shortener_GetDeleteJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerServer is the server API for Shortener service.
 // All implementations must embed UnimplementedShortenerServer
 // for forward compatibility.
@@ -129,7 +201,13 @@ type ShortenerServer interface {
 	Ping(context.Context, *Empty) (*PingResponse, error)
 	GetUserURLs(context.Context, *Empty) (*GetUserURLsResponse, error)
 	DeleteShortenerBatch(context.Context, *DeleteShortenerBatchRequest) (*DeleteShortenerBatchResponse, error)
-	GetStats(context.Context, *Empty) (*GetStatsResponse, error)
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
+	GetClickStats(context.Context, *GetClickStatsRequest) (*GetClickStatsResponse, error)
+	UpdateShortener(context.Context, *UpdateShortenerRequest) (*UpdateShortenerResponse, error)
+	GetRevisions(context.Context, *GetRevisionsRequest) (*GetRevisionsResponse, error)
+	GetTrash(context.Context, *Empty) (*GetTrashResponse, error)
+	RestoreShortener(context.Context, *RestoreShortenerRequest) (*CodeURL, error)
+	GetDeleteJob(context.Context, *GetDeleteJobRequest) (*GetDeleteJobResponse, error)
 	mustEmbedUnimplementedShortenerServer()
 }
 
@@ -158,9 +236,27 @@ func (UnimplementedShortenerServer) GetUserURLs(context.Context, *Empty) (*GetUs
 func (UnimplementedShortenerServer) DeleteShortenerBatch(context.Context, *DeleteShortenerBatchRequest) (*DeleteShortenerBatchResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteShortenerBatch not implemented")
 }
-func (UnimplementedShortenerServer) GetStats(context.Context, *Empty) (*GetStatsResponse, error) {
+func (UnimplementedShortenerServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
 }
+func (UnimplementedShortenerServer) GetClickStats(context.Context, *GetClickStatsRequest) (*GetClickStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClickStats not implemented")
+}
+func (UnimplementedShortenerServer) UpdateShortener(context.Context, *UpdateShortenerRequest) (*UpdateShortenerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateShortener not implemented")
+}
+func (UnimplementedShortenerServer) GetRevisions(context.Context, *GetRevisionsRequest) (*GetRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRevisions not implemented")
+}
+func (UnimplementedShortenerServer) GetTrash(context.Context, *Empty) (*GetTrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTrash not implemented")
+}
+func (UnimplementedShortenerServer) RestoreShortener(context.Context, *RestoreShortenerRequest) (*CodeURL, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreShortener not implemented")
+}
+func (UnimplementedShortenerServer) GetDeleteJob(context.Context, *GetDeleteJobRequest) (*GetDeleteJobResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDeleteJob not implemented")
+}
 func (UnimplementedShortenerServer) mustEmbedUnimplementedShortenerServer() {}
 func (UnimplementedShortenerServer) testEmbeddedByValue()                   {}
 
@@ -291,7 +387,7 @@ func _Shortener_DeleteShortenerBatch_Handler(srv interface{}, ctx context.Contex
 }
 
 func _Shortener_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Empty)
+	in := new(GetStatsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -303,7 +399,115 @@ func _Shortener_GetStats_Handler(srv interface{}, ctx context.Context, dec func(
 		FullMethod: Shortener_GetStats_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ShortenerServer).GetStats(ctx, req.(*Empty))
+		return srv.(ShortenerServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetClickStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClickStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetClickStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetClickStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetClickStats(ctx, req.(*GetClickStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_UpdateShortener_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateShortenerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).UpdateShortener(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_UpdateShortener_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).UpdateShortener(ctx, req.(*UpdateShortenerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetRevisions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetRevisions(ctx, req.(*GetRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetTrash(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_RestoreShortener_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreShortenerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).RestoreShortener(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_RestoreShortener_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).RestoreShortener(ctx, req.(*RestoreShortenerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetDeleteJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDeleteJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetDeleteJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetDeleteJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetDeleteJob(ctx, req.(*GetDeleteJobRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -343,6 +547,30 @@ var Shortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetStats",
 			Handler:    _Shortener_GetStats_Handler,
 		},
+		{
+			MethodName: "GetClickStats",
+			Handler:    _Shortener_GetClickStats_Handler,
+		},
+		{
+			MethodName: "UpdateShortener",
+			Handler:    _Shortener_UpdateShortener_Handler,
+		},
+		{
+			MethodName: "GetRevisions",
+			Handler:    _Shortener_GetRevisions_Handler,
+		},
+		{
+			MethodName: "GetTrash",
+			Handler:    _Shortener_GetTrash_Handler,
+		},
+		{
+			MethodName: "RestoreShortener",
+			Handler:    _Shortener_RestoreShortener_Handler,
+		},
+		{
+			MethodName: "GetDeleteJob",
+			Handler:    _Shortener_GetDeleteJob_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/server.proto",
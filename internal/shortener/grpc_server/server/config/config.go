@@ -2,5 +2,6 @@ package config
 
 // Конфигурация grpc_server
 type Config struct {
+	// TrustedSubnet - доверенная подсеть в нотации CIDR, например 192.168.1.0/24
 	TrustedSubnet string
 }
@@ -5,17 +5,21 @@ import (
 	"context"
 	"errors"
 	"net"
-	"sync"
+	"net/netip"
+	"strings"
+	"time"
 
 	"github.com/iurnickita/vigilant-train/internal/shortener/auth"
 	pb "github.com/iurnickita/vigilant-train/internal/shortener/grpc_server/proto"
 	"github.com/iurnickita/vigilant-train/internal/shortener/grpc_server/server/config"
 	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/policy"
 	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
 	"github.com/iurnickita/vigilant-train/internal/shortener/service"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
@@ -29,15 +33,18 @@ type Server struct {
 	config    config.Config
 	shortener service.Service
 	zaplog    *zap.Logger
-	wg        sync.WaitGroup
+	// trusted - доверенная подсеть; пустая или некорректная ничего не разрешает
+	trusted netip.Prefix
 }
 
 // NewServer создает новый grpc сервер
 func NewServer(config config.Config, shortener service.Service, zaplog *zap.Logger) *Server {
+	trusted, _ := netip.ParsePrefix(config.TrustedSubnet)
 	return &Server{
 		config:    config,
 		shortener: shortener,
 		zaplog:    zaplog,
+		trusted:   trusted.Masked(),
 	}
 }
 
@@ -52,39 +59,222 @@ func (s *Server) Register(ctx context.Context, in *pb.Empty) (*pb.RegisterRespon
 
 // GetShortener перенаправляет по короткой ссылке
 func (s *Server) GetShortener(ctx context.Context, in *pb.GetShortenerRequest) (*pb.GetShortenerResponse, error) {
+	// Клиент: для учета перехода и ограничения попыток ввода пароля
+	var ip, userAgent string
+	if p, ok := peer.FromContext(ctx); ok {
+		ip, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		userAgent = strings.Join(md.Get("user-agent"), " ")
+	}
 
-	resp, err := s.shortener.GetShortener(in.Code)
+	// Пароль проверяется, если передан: защищенная ссылка без пароля не выдается
+	var resp model.Shortener
+	var err error
+	if in.Password != "" {
+		resp, err = s.shortener.UnlockShortener(in.Code, in.Password, ip)
+	} else {
+		resp, err = s.shortener.GetShortener(in.Code)
+	}
 	if err != nil {
 		if errors.Is(err, repository.ErrGetShortenerGone) {
 			return nil, status.Errorf(codes.NotFound, err.Error())
+		} else if errors.Is(err, policy.ErrBlocked) || errors.Is(err, policy.ErrForbidden) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		} else if errors.Is(err, service.ErrPasswordRequired) || errors.Is(err, service.ErrWrongPassword) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		} else if errors.Is(err, service.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
 		} else {
 			return nil, status.Errorf(codes.Internal, err.Error())
 		}
 	}
 
+	// Учет перехода: несуществующий код не учитывается
+	if resp.Data.URL != "" {
+		s.shortener.RecordClick(in.Code, "", userAgent, ip)
+	}
+
 	var response pb.GetShortenerResponse
 	response.Url = resp.Data.URL
 	return &response, nil
 }
 
+// GetClickStats возвращает статистику переходов по ссылке пользователя
+func (s *Server) GetClickStats(ctx context.Context, in *pb.GetClickStatsRequest) (*pb.GetClickStatsResponse, error) {
+	// Код пользователя
+	userCode := ctx.Value(auth.UserCodeKeyGRPC).(string)
+
+	// Период по умолчанию - сутки по часам
+	from := time.Now().Add(-24 * time.Hour)
+	if in.From != 0 {
+		from = time.Unix(in.From, 0)
+	}
+	bucket := time.Hour
+	if in.BucketSeconds != 0 {
+		bucket = time.Duration(in.BucketSeconds) * time.Second
+	}
+
+	stats, err := s.shortener.GetClickStats(ctx, userCode, in.Code, from, bucket)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidStatsRange):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, service.ErrNotOwner), errors.Is(err, repository.ErrGetShortenerNotFound),
+			errors.Is(err, repository.ErrGetShortenerGone):
+			return nil, status.Error(codes.NotFound, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	response := pb.GetClickStatsResponse{Total: int64(stats.Total)}
+	for _, bucket := range stats.Buckets {
+		response.Buckets = append(response.Buckets, &pb.ClickBucket{Start: bucket.Start.Unix(), Count: int64(bucket.Count)})
+	}
+	return &response, nil
+}
+
 // SetShortener создает короткую ссылку
 func (s *Server) SetShortener(ctx context.Context, in *pb.SetShortenerRequest) (*pb.SetShortenerResponse, error) {
 	// Код пользователя
 	userCode := ctx.Value(auth.UserCodeKeyGRPC).(string)
 
+	// Срок действия
+	var at time.Time
+	if in.ExpiresAt != 0 {
+		at = time.Unix(in.ExpiresAt, 0)
+	}
+	expiresAt, err := service.ExpiresAt(time.Now(), time.Duration(in.TtlSeconds)*time.Second, at)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	passwordHash, err := service.HashPassword(in.Password)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	// Получение полной URL
 	resp, err := s.shortener.SetShortener(model.Shortener{
-		Data: model.ShortenerData{URL: in.Url, User: userCode},
+		Key:  model.ShortenerKey{Code: in.Alias},
+		Data: model.ShortenerData{URL: in.Url, User: userCode, ExpiresAt: expiresAt, PasswordHash: passwordHash, MaxClicks: int(in.MaxClicks)},
 	})
 	if err != nil {
 		if resp.Key.Code != "" {
 			return &pb.SetShortenerResponse{Code: resp.Key.Code}, status.Error(codes.AlreadyExists, err.Error())
+		} else if errors.Is(err, service.ErrAliasTaken) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		} else if errors.Is(err, policy.ErrBlocked) || errors.Is(err, policy.ErrForbidden) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		} else if errors.Is(err, service.ErrInvalidURL) || errors.Is(err, service.ErrInvalidExpiry) || errors.Is(err, service.ErrInvalidAlias) ||
+			errors.Is(err, service.ErrInvalidMaxClicks) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
 		} else {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
 	}
 
-	return &pb.SetShortenerResponse{Code: resp.Key.Code}, nil
+	response := pb.SetShortenerResponse{Code: resp.Key.Code}
+	if !resp.Data.ExpiresAt.IsZero() {
+		response.ExpiresAt = resp.Data.ExpiresAt.Unix()
+	}
+	return &response, nil
+}
+
+// updateCode - код ответа для ошибки изменения ссылки
+func updateCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, service.ErrInvalidURL):
+		return codes.InvalidArgument
+	case errors.Is(err, policy.ErrBlocked), errors.Is(err, policy.ErrForbidden):
+		return codes.PermissionDenied
+	case errors.Is(err, service.ErrNotOwner), errors.Is(err, service.ErrRevisionNotFound),
+		errors.Is(err, repository.ErrGetShortenerNotFound), errors.Is(err, repository.ErrDeleteJobNotFound):
+		return codes.NotFound
+	case errors.Is(err, repository.ErrGetShortenerGone), errors.Is(err, repository.ErrShortenerNotDeleted):
+		return codes.FailedPrecondition
+	case errors.Is(err, repository.ErrSetShortenerAlreadyExists):
+		return codes.AlreadyExists
+	default:
+		return codes.Internal
+	}
+}
+
+// Обработчик UpdateShortener изменение адреса назначения или откат к адресу из истории
+func (s *Server) UpdateShortener(ctx context.Context, in *pb.UpdateShortenerRequest) (*pb.UpdateShortenerResponse, error) {
+	// Код пользователя
+	userCode := ctx.Value(auth.UserCodeKeyGRPC).(string)
+
+	var resp model.Shortener
+	var err error
+	if in.Revision != 0 {
+		resp, err = s.shortener.RollbackShortener(ctx, userCode, in.Code, int(in.Revision))
+	} else {
+		resp, err = s.shortener.UpdateShortener(ctx, userCode, in.Code, in.Url)
+	}
+	if err != nil {
+		code := updateCode(err)
+		if code == codes.AlreadyExists && resp.Key.Code != "" {
+			return &pb.UpdateShortenerResponse{Code: resp.Key.Code, Url: resp.Data.URL}, status.Error(code, err.Error())
+		}
+		return nil, status.Error(code, err.Error())
+	}
+
+	return &pb.UpdateShortenerResponse{Code: resp.Key.Code, Url: resp.Data.URL}, nil
+}
+
+// Обработчик GetRevisions история адресов ссылки пользователя
+func (s *Server) GetRevisions(ctx context.Context, in *pb.GetRevisionsRequest) (*pb.GetRevisionsResponse, error) {
+	// Код пользователя
+	userCode := ctx.Value(auth.UserCodeKeyGRPC).(string)
+
+	revs, err := s.shortener.GetRevisions(ctx, userCode, in.Code)
+	if err != nil {
+		return nil, status.Error(updateCode(err), err.Error())
+	}
+
+	var response pb.GetRevisionsResponse
+	for _, rev := range revs {
+		response.Revisions = append(response.Revisions, &pb.Revision{
+			Revision:  int32(rev.Revision),
+			Url:       rev.URL,
+			CreatedAt: rev.CreatedAt.Unix(),
+		})
+	}
+	return &response, nil
+}
+
+// Обработчик GetTrash удаленные ссылки пользователя, которые еще можно восстановить
+func (s *Server) GetTrash(ctx context.Context, in *pb.Empty) (*pb.GetTrashResponse, error) {
+	// Код пользователя
+	userCode := ctx.Value(auth.UserCodeKeyGRPC).(string)
+
+	batch, err := s.shortener.GetTrash(ctx, userCode)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	var response pb.GetTrashResponse
+	for _, row := range batch {
+		deleted := pb.DeletedURL{Code: row.Key.Code, Url: row.Data.URL}
+		if !row.Data.DeletedAt.IsZero() {
+			deleted.DeletedAt = row.Data.DeletedAt.Unix()
+		}
+		response.Urls = append(response.Urls, &deleted)
+	}
+	return &response, nil
+}
+
+// Обработчик RestoreShortener восстановление удаленной ссылки пользователя
+func (s *Server) RestoreShortener(ctx context.Context, in *pb.RestoreShortenerRequest) (*pb.CodeURL, error) {
+	// Код пользователя
+	userCode := ctx.Value(auth.UserCodeKeyGRPC).(string)
+
+	resp, err := s.shortener.RestoreShortener(ctx, userCode, in.Code)
+	if err != nil {
+		return nil, status.Error(updateCode(err), err.Error())
+	}
+	return &pb.CodeURL{Code: resp.Key.Code, Url: resp.Data.URL}, nil
 }
 
 // Обработчик DeleteShortenerBatch удаление набора ссылок
@@ -92,47 +282,97 @@ func (s *Server) DeleteShortenerBatch(ctx context.Context, in *pb.DeleteShortene
 	// Код пользователя
 	userCode := ctx.Value(auth.UserCodeKeyGRPC).(string)
 
-	// Конвертация
-	arr := make([]model.Shortener, 0, len(in.Code))
-	for _, code := range in.Code {
-		arr = append(arr, model.Shortener{Key: model.ShortenerKey{Code: code}, Data: model.ShortenerData{User: userCode}})
+	// Вызов метода сервиса: задание удаления сохраняется до ответа
+	job, err := s.shortener.DeleteShortenerBatch(ctx, userCode, in.Code)
+	if err != nil {
+		return nil, status.Error(updateCode(err), err.Error())
 	}
 
-	// Вызов метода сервиса
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		s.shortener.DeleteShortenerBatch(arr)
-	}()
+	return &pb.DeleteShortenerBatchResponse{JobId: job.ID}, nil
+}
+
+// Обработчик GetDeleteJob состояние задания удаления пользователя с результатом по каждому коду
+func (s *Server) GetDeleteJob(ctx context.Context, in *pb.GetDeleteJobRequest) (*pb.GetDeleteJobResponse, error) {
+	// Код пользователя
+	userCode := ctx.Value(auth.UserCodeKeyGRPC).(string)
+
+	job, err := s.shortener.GetDeleteJob(ctx, userCode, in.Id)
+	if err != nil {
+		return nil, status.Error(updateCode(err), err.Error())
+	}
 
-	return &pb.DeleteShortenerBatchResponse{}, nil
+	response := pb.GetDeleteJobResponse{
+		Id:        job.ID,
+		Status:    string(job.Status),
+		Attempts:  int32(job.Attempts),
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt.Unix(),
+		UpdatedAt: job.UpdatedAt.Unix(),
+	}
+	for _, code := range job.Codes {
+		response.Codes = append(response.Codes, &pb.DeleteJobCode{Code: code.Code, Status: string(code.Status)})
+	}
+	return &response, nil
 }
 
 // GetStats возвращает статистические данные
-func (s *Server) GetStats(ctx context.Context, in *pb.Empty) (*pb.GetStatsResponse, error) {
+func (s *Server) GetStats(ctx context.Context, in *pb.GetStatsRequest) (*pb.GetStatsResponse, error) {
 	//Доверенная подсеть
-	if s.config.TrustedSubnet == "" {
+	if !s.trusted.IsValid() {
 		return nil, status.Error(codes.NotFound, "")
 	}
 	p, ok := peer.FromContext(ctx)
 	if !ok {
 		return nil, status.Error(codes.Internal, "Get peer error")
 	}
-	ipStr := p.Addr.String()
-	if !(len(ipStr) > len(s.config.TrustedSubnet)) {
+	addrPort, err := netip.ParseAddrPort(p.Addr.String())
+	if err != nil || !s.trusted.Contains(addrPort.Addr().Unmap()) {
 		return nil, status.Error(codes.Unauthenticated, "")
 	}
-	if s.config.TrustedSubnet != ipStr[:len(s.config.TrustedSubnet)] {
-		return nil, status.Error(codes.Unauthenticated, "")
+
+	// Период: по умолчанию неделя до текущего момента
+	to := time.Now()
+	if in.To != 0 {
+		to = time.Unix(in.To, 0)
+	}
+	from := to.Add(-service.DefaultStatsPeriod)
+	if in.From != 0 {
+		from = time.Unix(in.From, 0)
 	}
 
 	//Получение статистических данных
-	stats, err := s.shortener.GetStats(ctx)
+	stats, err := s.shortener.GetStatsReport(ctx, from, to, int(in.Top))
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidStatsRange) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	return &pb.GetStatsResponse{Urls: int32(stats.URLs), Users: int32(stats.Users)}, nil
+	response := pb.GetStatsResponse{
+		Urls:        int32(stats.URLs),
+		Users:       int32(stats.Users),
+		From:        stats.From.Unix(),
+		To:          stats.To.Unix(),
+		DeletedUrls: int32(stats.DeletedURLs),
+		ActiveUsers: int32(stats.ActiveUsers),
+		Redirects: &pb.RedirectStats{
+			Total:     int64(stats.Redirects.Total),
+			NotFound:  int64(stats.Redirects.NotFound),
+			Gone:      int64(stats.Redirects.Gone),
+			Blocked:   int64(stats.Redirects.Blocked),
+			Failed:    int64(stats.Redirects.Failed),
+			ErrorRate: stats.Redirects.ErrorRate,
+			Since:     stats.Redirects.Since.Unix(),
+		},
+	}
+	for _, day := range stats.Created {
+		response.Created = append(response.Created, &pb.ClickBucket{Start: day.Start.Unix(), Count: int64(day.Count)})
+	}
+	for _, link := range stats.TopLinks {
+		response.TopLinks = append(response.TopLinks, &pb.LinkClicks{Code: link.Code, Clicks: int64(link.Clicks)})
+	}
+	return &response, nil
 }
 
 // Serve - запуск сервера
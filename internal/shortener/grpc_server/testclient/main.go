@@ -54,11 +54,17 @@ func testUsers(c pb.ShortenerClient) {
 	log.Printf("Получена URL: %s", getResp.Url)
 
 	log.Print("Попытка удалить")
-	_, err = c.DeleteShortenerBatch(ctx, &pb.DeleteShortenerBatchRequest{Code: []string{setResp.Code}})
+	delResp, err := c.DeleteShortenerBatch(ctx, &pb.DeleteShortenerBatchRequest{Code: []string{setResp.Code}})
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Print("Успех")
+	log.Printf("Успех, задание удаления: %s", delResp.JobId)
+
+	jobResp, err := c.GetDeleteJob(ctx, &pb.GetDeleteJobRequest{Id: delResp.JobId})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Состояние задания удаления: %s", jobResp.Status)
 
 	log.Printf("Поптыка перехода по короткой ссылке: %s", setResp.Code)
 	getResp, err = c.GetShortener(ctx, &pb.GetShortenerRequest{Code: setResp.Code})
@@ -5,5 +5,9 @@ type Config struct {
 	ServerAddr    string
 	BaseAddr      string
 	EnableHTTPS   bool
+	// TrustedSubnet - доверенная подсеть в нотации CIDR, например 192.168.1.0/24
 	TrustedSubnet string
+	// LinkSecret - ключ подписи токенов доступа к защищенным паролем ссылкам.
+	// Пусто - случайный ключ при запуске: доступ не сохраняется между перезапусками и экземплярами
+	LinkSecret string
 }
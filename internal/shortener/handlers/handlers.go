@@ -7,12 +7,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
+	"net"
 	"net/http"
+	"net/netip"
 	"os/signal"
+	"strconv"
 	"strings"
-	"sync"
 	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
@@ -22,12 +26,21 @@ import (
 	"github.com/iurnickita/vigilant-train/internal/shortener/handlers/config"
 	"github.com/iurnickita/vigilant-train/internal/shortener/logger"
 	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/policy"
 	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
 	"github.com/iurnickita/vigilant-train/internal/shortener/service"
+	"github.com/iurnickita/vigilant-train/internal/shortener/token"
 )
 
 // Serve - запуск сервера
 func Serve(cfg config.Config, shortener service.Service, zaplog *zap.Logger) error {
+	// Ключ токенов доступа к ссылкам: случайный, если не задан
+	if cfg.LinkSecret == "" {
+		var err error
+		if cfg.LinkSecret, err = token.NewLinkSecret(); err != nil {
+			return err
+		}
+	}
 	h := newHandlers(cfg, shortener, zaplog)
 	router, _ := h.newRouter()
 
@@ -48,8 +61,6 @@ func Serve(cfg config.Config, shortener service.Service, zaplog *zap.Logger) err
 			zaplog.Info("HTTP server Shutdown error",
 				zap.String("error", err.Error()))
 		}
-		// Ожидание завершения работы handler
-		h.wait()
 		// Остановка сервиса
 		shortener.Shutdown()
 		// сообщаем основному потоку,
@@ -77,14 +88,17 @@ type handlers struct {
 	config    config.Config
 	shortener service.Service
 	zaplog    *zap.Logger
-	wg        sync.WaitGroup
+	// trusted - доверенная подсеть; пустая или некорректная ничего не разрешает
+	trusted netip.Prefix
 }
 
 func newHandlers(config config.Config, shortener service.Service, zaplog *zap.Logger) *handlers {
+	trusted, _ := netip.ParsePrefix(config.TrustedSubnet)
 	return &handlers{
 		config:    config,
 		shortener: shortener,
 		zaplog:    zaplog,
+		trusted:   trusted.Masked(),
 	}
 }
 
@@ -93,38 +107,203 @@ func newHandlers(config config.Config, shortener service.Service, zaplog *zap.Lo
 func (h *handlers) newRouter() (*http.ServeMux, *chi.Mux) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /{code}", logger.RequestLogMdlw(gzip.GzipMiddleware(h.GetShortener), h.zaplog))
+	mux.HandleFunc("POST /{code}", logger.RequestLogMdlw(gzip.GzipMiddleware(h.UnlockShortener), h.zaplog))
 	mux.HandleFunc("POST /", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.SetShortener)), h.zaplog))
 	mux.HandleFunc("POST /api/shorten", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.SetShortenerJSON)), h.zaplog))
 	mux.HandleFunc("POST /api/shorten/batch", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.SetShortenerJSONBatch)), h.zaplog))
 	mux.HandleFunc("GET /ping", logger.RequestLogMdlw(h.Ping, h.zaplog))
 	mux.HandleFunc("GET /api/user/urls", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.GetUserURLs)), h.zaplog))
 	mux.HandleFunc("DELETE /api/user/urls", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.DeleteShortenerBatch)), h.zaplog))
+	mux.HandleFunc("GET /api/user/jobs/{id}", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.GetDeleteJob)), h.zaplog))
+	mux.HandleFunc("GET /api/internal/stats", logger.RequestLogMdlw(gzip.GzipMiddleware(h.GetStats), h.zaplog))
+	mux.HandleFunc("PATCH /api/user/urls/{code}", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.UpdateShortener)), h.zaplog))
+	mux.HandleFunc("GET /api/user/urls/{code}/revisions", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.GetRevisions)), h.zaplog))
+	mux.HandleFunc("POST /api/user/urls/{code}/rollback", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.RollbackShortener)), h.zaplog))
+	mux.HandleFunc("GET /api/user/urls/{code}/stats", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.GetClickStats)), h.zaplog))
+	mux.HandleFunc("GET /api/user/trash", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.GetTrash)), h.zaplog))
+	mux.HandleFunc("POST /api/user/urls/{code}/restore", logger.RequestLogMdlw(gzip.GzipMiddleware(auth.AuthMiddleware(h.RestoreShortener)), h.zaplog))
 
 	chi := chi.NewRouter() // dummy
 
 	return mux, chi
 }
 
-// Обработчик GetShortener перенаправляет по короткой ссылке
+// Обработчик GetShortener перенаправляет по короткой ссылке.
+// Для защищенной паролем ссылки без действующего токена доступа отвечает формой ввода пароля
 func (h *handlers) GetShortener(w http.ResponseWriter, r *http.Request) {
 	code := r.PathValue("code")
 
-	resp, err := h.shortener.GetShortener(code)
+	// Доступ к защищенной ссылке проверяется до чтения: переход учитывается в статистике один раз
+	var resp model.Shortener
+	var err error
+	if cookie, cookieErr := r.Cookie(linkAccessCookie); cookieErr == nil && token.CheckLinkAccess(h.config.LinkSecret, cookie.Value, code) == nil {
+		resp, err = h.shortener.GetUnlockedShortener(code)
+	} else {
+		resp, err = h.shortener.GetShortener(code)
+	}
+	if errors.Is(err, service.ErrPasswordRequired) {
+		writePasswordForm(w, http.StatusUnauthorized, code, "")
+		return
+	}
 	if err != nil {
-		if errors.Is(err, repository.ErrGetShortenerGone) {
-			http.Error(w, err.Error(), http.StatusGone)
-		} else {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		}
+		redirectError(w, err)
 		return
 	}
 
+	// Несуществующий код не учитывается в статистике переходов
+	if resp.Data.URL != "" {
+		h.shortener.RecordClick(code, r.Referer(), r.UserAgent(), h.clientIP(r))
+	}
 	http.Redirect(w, r, resp.Data.URL, http.StatusTemporaryRedirect)
 }
 
-// Обработчик SetShortener создает короткую ссылку
+// Обработчик UnlockShortener проверяет пароль ссылки из формы (поле password) и перенаправляет по ссылке.
+// Для защищенной ссылки выдается cookie с токеном доступа, действующим linkAccessTTL
+func (h *handlers) UnlockShortener(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.shortener.UnlockShortener(code, r.PostForm.Get("password"), h.clientIP(r))
+	switch {
+	case errors.Is(err, service.ErrWrongPassword):
+		writePasswordForm(w, http.StatusUnauthorized, code, "Неверный пароль")
+		return
+	case errors.Is(err, service.ErrTooManyAttempts):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case err != nil:
+		redirectError(w, err)
+		return
+	case resp.Data.URL == "":
+		http.NotFound(w, r)
+		return
+	}
+
+	if resp.Data.Protected() {
+		accessToken, err := token.BuildLinkAccessString(h.config.LinkSecret, code, linkAccessTTL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     linkAccessCookie,
+			Value:    accessToken,
+			Path:     "/" + code,
+			MaxAge:   int(linkAccessTTL.Seconds()),
+			Secure:   h.config.EnableHTTPS,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	h.shortener.RecordClick(code, r.Referer(), r.UserAgent(), h.clientIP(r))
+	http.Redirect(w, r, resp.Data.URL, http.StatusSeeOther)
+}
+
+// redirectError отвечает ошибкой перехода по ссылке
+func redirectError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrGetShortenerGone) {
+		http.Error(w, err.Error(), http.StatusGone)
+	} else if status, ok := policyStatus(err); ok {
+		http.Error(w, err.Error(), status)
+	} else {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// Доступ к защищенной паролем ссылке
+const (
+	// linkAccessCookie - cookie с токеном доступа, выдается на путь ссылки
+	linkAccessCookie = "link_access"
+	// linkAccessTTL - срок действия токена доступа
+	linkAccessTTL = 30 * time.Minute
+)
+
+// passwordForm - форма ввода пароля ссылки
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Ссылка защищена паролем</title></head>
+<body>
+<form method="post" action="/{{.Code}}">
+{{if .Message}}<p>{{.Message}}</p>
+{{end}}<input type="password" name="password" placeholder="Пароль" autofocus required>
+<button type="submit">Перейти</button>
+</form>
+</body>
+</html>
+`))
+
+// writePasswordForm отвечает формой ввода пароля ссылки code с сообщением message
+func writePasswordForm(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	passwordForm.Execute(w, struct{ Code, Message string }{code, message})
+}
+
+// clientIP - адрес клиента: из X-Real-IP, если соединение пришло от прокси из доверенной подсети,
+// иначе адрес соединения (заголовок мог задать сам клиент)
+func (h *handlers) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := r.Header.Get("X-Real-IP"); ip != "" && h.isTrusted(host) {
+		return ip
+	}
+	return host
+}
+
+// isTrusted проверяет, что адрес входит в доверенную подсеть
+func (h *handlers) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	return err == nil && h.trusted.IsValid() && h.trusted.Contains(addr.Unmap())
+}
+
+// policyStatus - код ответа для адреса, запрещенного политикой:
+// 451 - запрещен правилами, 403 - внутренний или не разрешенный адрес
+func policyStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, policy.ErrBlocked):
+		return http.StatusUnavailableForLegalReasons, true
+	case errors.Is(err, policy.ErrForbidden):
+		return http.StatusForbidden, true
+	}
+	return 0, false
+}
+
+// Обработчик SetShortener создает короткую ссылку.
+// URL передается в теле запроса как есть либо полем url формы (application/x-www-form-urlencoded).
+// Необязательные поля формы: password - пароль для перехода по ссылке, max_clicks - предельное кол-во переходов
 func (h *handlers) SetShortener(w http.ResponseWriter, r *http.Request) {
-	url, err := io.ReadAll(r.Body)
+	var url, password string
+	var maxClicks int
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		url = r.PostForm.Get("url")
+		password = r.PostForm.Get("password")
+		if value := r.PostForm.Get("max_clicks"); value != "" {
+			var err error
+			if maxClicks, err = strconv.Atoi(value); err != nil {
+				http.Error(w, fmt.Sprintf("%v: %v", service.ErrInvalidMaxClicks, err), http.StatusBadRequest)
+				return
+			}
+		}
+	} else {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		url = string(body)
+	}
+
+	passwordHash, err := service.HashPassword(password)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -133,13 +312,16 @@ func (h *handlers) SetShortener(w http.ResponseWriter, r *http.Request) {
 	userCode := r.Header.Get(auth.UserCodeKey)
 
 	resp, err := h.shortener.SetShortener(model.Shortener{
-		Data: model.ShortenerData{URL: string(url), User: userCode},
+		Data: model.ShortenerData{URL: url, User: userCode, PasswordHash: passwordHash, MaxClicks: maxClicks},
 	})
 	if err != nil {
 		if resp.Key.Code != "" {
 			w.WriteHeader(http.StatusConflict)
 			io.WriteString(w, fmt.Sprintf("http://%s/%s", h.config.BaseAddr, resp.Key.Code))
 			return
+		} else if status, ok := policyStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
 		} else {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -150,14 +332,40 @@ func (h *handlers) SetShortener(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, fmt.Sprintf("http://%s/%s", h.config.BaseAddr, resp.Key.Code))
 }
 
-// Обработчик SetShortenerJSON: JSON запроса с исходным URL
+// Обработчик SetShortenerJSON: JSON запроса с исходным URL.
+// Срок действия ссылки задается либо TTL в секундах, либо моментом ExpiresAt.
+// Alias - пользовательский код ссылки, Password - пароль для перехода по ссылке,
+// MaxClicks - предельное кол-во переходов, после которых ссылка недоступна (необязательные)
 type RawURLJSON struct {
-	URL string `json:"url"`
+	URL       string     `json:"url"`
+	Alias     string     `json:"alias,omitempty"`
+	TTL       int64      `json:"ttl,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Password  string     `json:"password,omitempty"`
+	MaxClicks int        `json:"max_clicks,omitempty"`
 }
 
 // Обработчик SetShortenerJSON: JSON ответа с короткой ссылкой
 type ShortURLJSON struct {
-	Result string `json:"result"`
+	Result    string     `json:"result"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// expiresAt - срок действия ссылки из запроса
+func expiresAt(ttl int64, at *time.Time) (time.Time, error) {
+	var atTime time.Time
+	if at != nil {
+		atTime = *at
+	}
+	return service.ExpiresAt(time.Now(), time.Duration(ttl)*time.Second, atTime)
+}
+
+// optionalTime - срок действия для ответа (nil для бессрочной ссылки)
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
 }
 
 // Обработчик SetShortenerJSON создает короткую ссылку
@@ -176,16 +384,34 @@ func (h *handlers) SetShortenerJSON(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expires, err := expiresAt(rawURL.TTL, rawURL.ExpiresAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	passwordHash, err := service.HashPassword(rawURL.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	userCode := r.Header.Get(auth.UserCodeKey)
 
 	resp, err := h.shortener.SetShortener(model.Shortener{
-		Data: model.ShortenerData{URL: rawURL.URL, User: userCode},
+		Key:  model.ShortenerKey{Code: rawURL.Alias},
+		Data: model.ShortenerData{URL: rawURL.URL, User: userCode, ExpiresAt: expires, PasswordHash: passwordHash, MaxClicks: rawURL.MaxClicks},
 	})
 
 	httpStatus := http.StatusCreated
 	if err != nil {
 		if resp.Key.Code != "" {
 			httpStatus = http.StatusConflict
+		} else if errors.Is(err, service.ErrAliasTaken) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		} else if status, ok := policyStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
 		} else {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -194,6 +420,7 @@ func (h *handlers) SetShortenerJSON(w http.ResponseWriter, r *http.Request) {
 
 	var shortURL ShortURLJSON
 	shortURL.Result = fmt.Sprintf("http://%s/%s", h.config.BaseAddr, resp.Key.Code)
+	shortURL.ExpiresAt = optionalTime(resp.Data.ExpiresAt)
 	respJSON, err := json.Marshal(shortURL)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -207,8 +434,13 @@ func (h *handlers) SetShortenerJSON(w http.ResponseWriter, r *http.Request) {
 
 // Обработчик SetShortenerJSONBatch: JSON запроса с исходным URL
 type SetShortenerJSONBatchRRow struct {
-	ID     string `json:"correlation_id"`
-	RawURL string `json:"original_url"`
+	ID        string     `json:"correlation_id"`
+	RawURL    string     `json:"original_url"`
+	Alias     string     `json:"alias,omitempty"`
+	TTL       int64      `json:"ttl,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Password  string     `json:"password,omitempty"`
+	MaxClicks int        `json:"max_clicks,omitempty"`
 }
 
 // Обработчик SetShortenerJSONBatch: JSON запроса с исходным URL (набор)
@@ -216,14 +448,28 @@ type SetShortenerJSONBatchR []SetShortenerJSONBatchRRow
 
 // Обработчик SetShortenerJSONBatch: JSON ответа с коротким URL
 type SetShortenerJSONBatchWRow struct {
-	ID       string `json:"correlation_id"`
-	ShortURL string `json:"short_url"`
+	ID        string     `json:"correlation_id"`
+	ShortURL  string     `json:"short_url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
 }
 
 // Обработчик SetShortenerJSONBatch: JSON ответа с коротким URL (набор)
 type SetShortenerJSONBatchW []SetShortenerJSONBatchWRow
 
-// Обработчик SetShortenerJSONBatch создает короткую ссылку для набора URL
+// Обработчик SetShortenerJSONBatch: статусы позиций ответа
+const (
+	batchStatusCreated       = "created"
+	batchStatusAlreadyExists = "already_exists"
+	batchStatusInvalid       = "invalid"
+	batchStatusAliasTaken    = "alias_taken"
+)
+
+// Обработчик SetShortenerJSONBatch создает короткую ссылку для набора URL.
+// Ответ содержит результат по каждой позиции запроса. Код ответа:
+// 201 - все ссылки созданы, 409 - все URL уже сокращены или коды заняты, 400 - все позиции некорректны,
+// 207 - результаты позиций различаются
 func (h *handlers) SetShortenerJSONBatch(w http.ResponseWriter, r *http.Request) {
 	var buf bytes.Buffer
 	_, err := buf.ReadFrom(r.Body)
@@ -241,36 +487,62 @@ func (h *handlers) SetShortenerJSONBatch(w http.ResponseWriter, r *http.Request)
 
 	userCode := r.Header.Get(auth.UserCodeKey)
 
-	var requestService []model.Shortener
+	requestService := make([]model.ShortenerBatchRow, 0, len(request))
 	for _, row := range request {
-		requestService = append(requestService, model.Shortener{Data: model.ShortenerData{URL: row.RawURL, User: userCode}})
+		// Некорректный срок действия или пароль - позиция отклоняется сервисом
+		expires, err := expiresAt(row.TTL, row.ExpiresAt)
+		var passwordHash string
+		if err == nil {
+			passwordHash, err = service.HashPassword(row.Password)
+		}
+		requestService = append(requestService, model.ShortenerBatchRow{
+			ID: row.ID,
+			Shortener: model.Shortener{
+				Key:  model.ShortenerKey{Code: row.Alias},
+				Data: model.ShortenerData{URL: row.RawURL, User: userCode, ExpiresAt: expires, PasswordHash: passwordHash, MaxClicks: row.MaxClicks},
+			},
+			Err: err,
+		})
 	}
 
 	responseService, err := h.shortener.SetShortenerBatch(requestService)
-
-	httpStatus := http.StatusCreated
 	if err != nil {
-		if len(responseService) > 0 {
-			httpStatus = http.StatusConflict
-		} else {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	var response SetShortenerJSONBatchW
+	// Результаты возвращаются в порядке запроса
+	var httpStatus int
+	response := make(SetShortenerJSONBatchW, 0, len(responseService))
 	for _, respRow := range responseService {
-		var id string
-		id = ""
-		for _, reqRow := range request { // вместо этого можно было пропустить ID сквозь SetShortenerBatch, но я спешу
-			if reqRow.RawURL == respRow.Data.URL {
-				id = reqRow.ID
-				break
-			}
+		row := SetShortenerJSONBatchWRow{ID: respRow.ID}
+		rowStatus := http.StatusCreated
+		switch respRow.Status {
+		case model.ShortenerStatusExists:
+			row.Status = batchStatusAlreadyExists
+			rowStatus = http.StatusConflict
+		case model.ShortenerStatusInvalid:
+			row.Status = batchStatusInvalid
+			rowStatus = http.StatusBadRequest
+		case model.ShortenerStatusCodeConflict:
+			row.Status = batchStatusAliasTaken
+			rowStatus = http.StatusConflict
+		default:
+			row.Status = batchStatusCreated
 		}
-		if id != "" {
-			shortURL := fmt.Sprintf("http://%s/%s", h.config.BaseAddr, respRow.Key.Code)
-			response = append(response, SetShortenerJSONBatchWRow{ID: id, ShortURL: shortURL})
+		if respRow.Err != nil {
+			row.Error = respRow.Err.Error()
+		} else {
+			row.ShortURL = fmt.Sprintf("http://%s/%s", h.config.BaseAddr, respRow.Shortener.Key.Code)
+			row.ExpiresAt = optionalTime(respRow.Shortener.Data.ExpiresAt)
+		}
+		response = append(response, row)
+
+		// Единый код ответа, если все позиции обработаны одинаково
+		if httpStatus == 0 {
+			httpStatus = rowStatus
+		} else if httpStatus != rowStatus {
+			httpStatus = http.StatusMultiStatus
 		}
 	}
 
@@ -297,8 +569,10 @@ func (h *handlers) Ping(w http.ResponseWriter, r *http.Request) {
 
 // Обработчик GetUserURLs: JSON ответа с коротким URL
 type GetUserURLsJSON struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Protected   bool       `json:"protected,omitempty"`
 }
 
 // Обработчик GetUserURLs возвращает все ссылки, добавленные пользователем
@@ -314,7 +588,12 @@ func (h *handlers) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 	var response []GetUserURLsJSON
 	for _, row := range batch {
 		shortURL := fmt.Sprintf("http://%s/%s", h.config.BaseAddr, row.Key.Code)
-		response = append(response, GetUserURLsJSON{ShortURL: shortURL, OriginalURL: row.Data.URL})
+		response = append(response, GetUserURLsJSON{
+			ShortURL:    shortURL,
+			OriginalURL: row.Data.URL,
+			ExpiresAt:   optionalTime(row.Data.ExpiresAt),
+			Protected:   row.Data.Protected(),
+		})
 	}
 
 	if len(response) > 0 {
@@ -349,54 +628,91 @@ func (h *handlers) DeleteShortenerBatch(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Конвертация
-	s := make([]model.Shortener, 0, len(codeArr))
-	for _, code := range codeArr {
-		s = append(s, model.Shortener{Key: model.ShortenerKey{Code: code}, Data: model.ShortenerData{User: userCode}})
+	// Вызов метода сервиса: задание удаления сохраняется до ответа
+	job, err := h.shortener.DeleteShortenerBatch(r.Context(), userCode, codeArr)
+	if err != nil {
+		http.Error(w, err.Error(), updateStatus(err))
+		return
 	}
 
-	// Вызов метода сервиса
-	h.wg.Add(1)
-	go func() {
-		defer h.wg.Done()
-		h.shortener.DeleteShortenerBatch(s)
-	}()
-
+	responseJSON, err := json.Marshal(DeleteJobIDJSON{JobID: job.ID})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
+	w.Write(responseJSON)
 }
 
-// GetStats возвращает статистические данные
-func (h *handlers) GetStats(w http.ResponseWriter, r *http.Request) {
-	//Доверенная подсеть
-	if h.config.TrustedSubnet == "" {
-		w.WriteHeader(http.StatusForbidden)
+// Обработчик DeleteShortenerBatch: JSON ответа
+type DeleteJobIDJSON struct {
+	JobID string `json:"job_id"`
+}
+
+// Обработчик GetDeleteJob возвращает состояние задания удаления пользователя с результатом по каждому коду
+func (h *handlers) GetDeleteJob(w http.ResponseWriter, r *http.Request) {
+	userCode := r.Header.Get(auth.UserCodeKey)
+	job, err := h.shortener.GetDeleteJob(r.Context(), userCode, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), updateStatus(err))
 		return
 	}
-	// смотрим заголовок запроса X-Real-IP
-	ipStr := r.Header.Get("X-Real-IP")
-	if ipStr == "" {
-		// если заголовок X-Real-IP пуст, пробуем X-Forwarded-For
-		// этот заголовок содержит адреса отправителя и промежуточных прокси
-		// в виде 203.0.113.195, 70.41.3.18, 150.172.238.178
-		ips := r.Header.Get("X-Forwarded-For")
-		// разделяем цепочку адресов
-		ipStrs := strings.Split(ips, ",")
-		// интересует только первый
-		ipStr = ipStrs[0]
-	}
-	if !(len(ipStr) > len(h.config.TrustedSubnet)) {
-		w.WriteHeader(http.StatusForbidden)
+
+	responseJSON, err := json.Marshal(job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if h.config.TrustedSubnet != ipStr[:len(h.config.TrustedSubnet)] {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJSON)
+}
+
+// GetStats возвращает статистические данные за период (только для доверенной подсети).
+// Параметры запроса: from, to - границы периода (RFC 3339, по умолчанию неделя до текущего момента),
+// top - кол-во ссылок с наибольшим кол-вом переходов
+func (h *handlers) GetStats(w http.ResponseWriter, r *http.Request) {
+	// Доверенная подсеть: адрес клиента берется из X-Real-IP только от прокси из этой подсети
+	if !h.isTrusted(h.clientIP(r)) {
 		w.WriteHeader(http.StatusForbidden)
 		return
 	}
 
+	// Период
+	query := r.URL.Query()
+	to := time.Now()
+	if toStr := query.Get("to"); toStr != "" {
+		var err error
+		if to, err = time.Parse(time.RFC3339, toStr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	from := to.Add(-service.DefaultStatsPeriod)
+	if fromStr := query.Get("from"); fromStr != "" {
+		var err error
+		if from, err = time.Parse(time.RFC3339, fromStr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var top int
+	if topStr := query.Get("top"); topStr != "" {
+		var err error
+		if top, err = strconv.Atoi(topStr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	//Получение статистических данных
-	stats, err := h.shortener.GetStats(r.Context())
+	stats, err := h.shortener.GetStatsReport(r.Context(), from, to, top)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, service.ErrInvalidStatsRange) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -411,6 +727,210 @@ func (h *handlers) GetStats(w http.ResponseWriter, r *http.Request) {
 	w.Write(responseJSON)
 }
 
-func (h *handlers) wait() {
-	h.wg.Wait()
+// Параметры статистики переходов по умолчанию
+const (
+	defaultClickStatsPeriod = 24 * time.Hour
+	defaultClickStatsBucket = time.Hour
+)
+
+// Обработчик GetClickStats возвращает статистику переходов по ссылке пользователя.
+// Параметры запроса: from - начало периода (RFC 3339, по умолчанию сутки назад),
+// bucket - длина интервала (например, 1h, по умолчанию час)
+func (h *handlers) GetClickStats(w http.ResponseWriter, r *http.Request) {
+	userCode := r.Header.Get(auth.UserCodeKey)
+	code := r.PathValue("code")
+
+	from := time.Now().Add(-defaultClickStatsPeriod)
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		var err error
+		if from, err = time.Parse(time.RFC3339, fromStr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	bucket := defaultClickStatsBucket
+	if bucketStr := r.URL.Query().Get("bucket"); bucketStr != "" {
+		var err error
+		if bucket, err = time.ParseDuration(bucketStr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	stats, err := h.shortener.GetClickStats(r.Context(), userCode, code, from, bucket)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidStatsRange):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrNotOwner), errors.Is(err, repository.ErrGetShortenerNotFound),
+			errors.Is(err, repository.ErrGetShortenerGone):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	responseJSON, err := json.Marshal(stats)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJSON)
+}
+
+// Обработчик UpdateShortener: JSON запроса с новым адресом назначения
+type UpdateURLJSON struct {
+	URL string `json:"url"`
+}
+
+// Обработчик RollbackShortener: JSON запроса с номером изменения из истории
+type RollbackJSON struct {
+	Revision int `json:"revision"`
+}
+
+// updateStatus - код ответа для ошибки изменения ссылки
+func updateStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidURL):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrNotOwner), errors.Is(err, service.ErrRevisionNotFound),
+		errors.Is(err, repository.ErrGetShortenerNotFound), errors.Is(err, repository.ErrDeleteJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrGetShortenerGone):
+		return http.StatusGone
+	case errors.Is(err, repository.ErrSetShortenerAlreadyExists), errors.Is(err, repository.ErrShortenerNotDeleted):
+		return http.StatusConflict
+	}
+	if status, ok := policyStatus(err); ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// writeUpdated отвечает измененной ссылкой. Если адрес уже сокращен другой ссылкой - 409 с этой ссылкой
+func (h *handlers) writeUpdated(w http.ResponseWriter, resp model.Shortener, err error) {
+	httpStatus := http.StatusOK
+	if err != nil {
+		httpStatus = updateStatus(err)
+		if httpStatus != http.StatusConflict || resp.Key.Code == "" {
+			http.Error(w, err.Error(), httpStatus)
+			return
+		}
+	}
+
+	respJSON, err := json.Marshal(GetUserURLsJSON{
+		ShortURL:    fmt.Sprintf("http://%s/%s", h.config.BaseAddr, resp.Key.Code),
+		OriginalURL: resp.Data.URL,
+		ExpiresAt:   optionalTime(resp.Data.ExpiresAt),
+		Protected:   resp.Data.Protected(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	w.Write(respJSON)
+}
+
+// Обработчик UpdateShortener меняет адрес назначения ссылки пользователя
+func (h *handlers) UpdateShortener(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req UpdateURLJSON
+	if err := json.Unmarshal(buf.Bytes(), &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userCode := r.Header.Get(auth.UserCodeKey)
+	resp, err := h.shortener.UpdateShortener(r.Context(), userCode, r.PathValue("code"), req.URL)
+	h.writeUpdated(w, resp, err)
+}
+
+// Обработчик RollbackShortener возвращает ссылке адрес из истории изменений
+func (h *handlers) RollbackShortener(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req RollbackJSON
+	if err := json.Unmarshal(buf.Bytes(), &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userCode := r.Header.Get(auth.UserCodeKey)
+	resp, err := h.shortener.RollbackShortener(r.Context(), userCode, r.PathValue("code"), req.Revision)
+	h.writeUpdated(w, resp, err)
+}
+
+// Обработчик GetRevisions возвращает историю адресов ссылки пользователя
+func (h *handlers) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	userCode := r.Header.Get(auth.UserCodeKey)
+	revs, err := h.shortener.GetRevisions(r.Context(), userCode, r.PathValue("code"))
+	if err != nil {
+		http.Error(w, err.Error(), updateStatus(err))
+		return
+	}
+
+	responseJSON, err := json.Marshal(revs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJSON)
+}
+
+// Обработчик GetTrash: JSON ответа
+type DeletedURLJSON struct {
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Обработчик GetTrash возвращает удаленные ссылки пользователя, которые еще можно восстановить
+func (h *handlers) GetTrash(w http.ResponseWriter, r *http.Request) {
+	userCode := r.Header.Get(auth.UserCodeKey)
+
+	batch, err := h.shortener.GetTrash(r.Context(), userCode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(batch) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	response := make([]DeletedURLJSON, 0, len(batch))
+	for _, row := range batch {
+		response = append(response, DeletedURLJSON{
+			ShortURL:    fmt.Sprintf("http://%s/%s", h.config.BaseAddr, row.Key.Code),
+			OriginalURL: row.Data.URL,
+			DeletedAt:   optionalTime(row.Data.DeletedAt),
+		})
+	}
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJSON)
+}
+
+// Обработчик RestoreShortener восстанавливает удаленную ссылку пользователя.
+// Срок восстановления истек - 410, ссылка не удалена - 409
+func (h *handlers) RestoreShortener(w http.ResponseWriter, r *http.Request) {
+	userCode := r.Header.Get(auth.UserCodeKey)
+	resp, err := h.shortener.RestoreShortener(r.Context(), userCode, r.PathValue("code"))
+	h.writeUpdated(w, resp, err)
 }
@@ -1,16 +1,23 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/iurnickita/vigilant-train/internal/shortener/auth"
 	handlersConfig "github.com/iurnickita/vigilant-train/internal/shortener/handlers/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
 	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
 	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
 	"github.com/iurnickita/vigilant-train/internal/shortener/service"
+	serviceConfig "github.com/iurnickita/vigilant-train/internal/shortener/service/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/token"
 	"go.uber.org/zap"
 
 	"github.com/stretchr/testify/require"
@@ -31,7 +38,8 @@ func TestHandlers(t *testing.T) {
 	}
 
 	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
-	shortenerService := service.NewShortener(store)
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
 	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
 	h := newHandlers(cfg, shortenerService, zap.NewNop())
 
@@ -87,7 +95,8 @@ func BenchmarkHandlers(b *testing.B) {
 	}
 
 	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
-	shortenerService := service.NewShortener(store)
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	b.Cleanup(shortenerService.Shutdown)
 	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
 	h := newHandlers(cfg, shortenerService, zap.NewNop())
 
@@ -118,3 +127,615 @@ func BenchmarkHandlers(b *testing.B) {
 		}
 	}
 }
+
+func TestHandlers_SetShortenerJSONBatch(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	send := func(body string) (int, SetShortenerJSONBatchW) {
+		r := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.SetShortenerJSONBatch(w, r)
+
+		result := w.Result()
+		defer result.Body.Close()
+		var response SetShortenerJSONBatchW
+		require.NoError(t, json.NewDecoder(result.Body).Decode(&response))
+		return result.StatusCode, response
+	}
+
+	// все позиции созданы
+	status, response := send(`[{"correlation_id":"1","original_url":"https://ya.ru/"}]`)
+	require.Equal(t, http.StatusCreated, status)
+	require.Len(t, response, 1)
+	require.Equal(t, "1", response[0].ID)
+	require.Equal(t, batchStatusCreated, response[0].Status)
+	existing := response[0].ShortURL
+
+	// частичный успех: конфликт одной позиции не отменяет остальные
+	status, response = send(`[` +
+		`{"correlation_id":"a","original_url":"https://practicum.yandex.ru/"},` +
+		`{"correlation_id":"b","original_url":"https://ya.ru/"},` +
+		`{"correlation_id":"c","original_url":""}]`)
+	require.Equal(t, http.StatusMultiStatus, status)
+	require.Len(t, response, 3)
+	require.Equal(t, "a", response[0].ID)
+	require.Equal(t, batchStatusCreated, response[0].Status)
+	require.NotEmpty(t, response[0].ShortURL)
+	require.Equal(t, "b", response[1].ID)
+	require.Equal(t, batchStatusAlreadyExists, response[1].Status)
+	require.Equal(t, existing, response[1].ShortURL)
+	require.Equal(t, "c", response[2].ID)
+	require.Equal(t, batchStatusInvalid, response[2].Status)
+	require.NotEmpty(t, response[2].Error)
+
+	// все позиции уже существуют
+	status, _ = send(`[{"correlation_id":"1","original_url":"https://ya.ru/"}]`)
+	require.Equal(t, http.StatusConflict, status)
+}
+
+func TestHandlers_Expiry(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	send := func(body string) (int, ShortURLJSON) {
+		r := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.SetShortenerJSON(w, r)
+
+		result := w.Result()
+		defer result.Body.Close()
+		var response ShortURLJSON
+		json.NewDecoder(result.Body).Decode(&response)
+		return result.StatusCode, response
+	}
+
+	// срок действия задается TTL
+	status, response := send(`{"url":"https://ya.ru/","ttl":3600}`)
+	require.Equal(t, http.StatusCreated, status)
+	require.NotNil(t, response.ExpiresAt)
+	require.WithinDuration(t, time.Now().Add(time.Hour), *response.ExpiresAt, time.Minute)
+
+	// некорректный срок действия
+	status, _ = send(`{"url":"https://practicum.yandex.ru/","ttl":60,"expires_at":"2030-01-01T00:00:00Z"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+	status, _ = send(`{"url":"https://practicum.yandex.ru/","expires_at":"2000-01-01T00:00:00Z"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	// истекшая ссылка - 410
+	_, err := store.SetShortener(context.Background(), model.Shortener{
+		Key:  model.ShortenerKey{Code: "expired"},
+		Data: model.ShortenerData{URL: "https://example.com/", ExpiresAt: time.Now().Add(-time.Second)},
+	})
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodGet, "/expired", nil)
+	r.SetPathValue("code", "expired")
+	w := httptest.NewRecorder()
+	h.GetShortener(w, r)
+	result := w.Result()
+	result.Body.Close()
+	require.Equal(t, http.StatusGone, result.StatusCode)
+}
+
+func TestHandlers_Alias(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	send := func(body string) (int, string) {
+		r := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.SetShortenerJSON(w, r)
+
+		result := w.Result()
+		defer result.Body.Close()
+		var response ShortURLJSON
+		json.NewDecoder(result.Body).Decode(&response)
+		return result.StatusCode, response.Result
+	}
+
+	status, result := send(`{"url":"https://ya.ru/","alias":"yandex"}`)
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, "http://localhost:8080/yandex", result)
+
+	// код занят другой ссылкой
+	status, _ = send(`{"url":"https://practicum.yandex.ru/","alias":"yandex"}`)
+	require.Equal(t, http.StatusConflict, status)
+
+	// зарезервированный код
+	status, _ = send(`{"url":"https://practicum.yandex.ru/","alias":"api"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestHandlers_SetShortenerNormalize(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	send := func(body, contentType string) (int, string) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		h.SetShortener(w, r)
+
+		result := w.Result()
+		defer result.Body.Close()
+		resultBody, _ := io.ReadAll(result.Body)
+		return result.StatusCode, string(resultBody)
+	}
+
+	// форма, как отправляет cmd/client
+	status, created := send("url=https%3A%2F%2FYa.ru%3A443", "application/x-www-form-urlencoded")
+	require.Equal(t, http.StatusCreated, status)
+
+	// тот же URL в другой записи - повтор
+	status, existing := send("https://ya.ru/", "text/plain")
+	require.Equal(t, http.StatusConflict, status)
+	require.Equal(t, created, existing)
+
+	status, _ = send("javascript:alert(1)", "text/plain")
+	require.Equal(t, http.StatusBadRequest, status)
+	status, _ = send("", "text/plain")
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestHandlers_Policy(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	// внутренний адрес не сокращается
+	r := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"http://169.254.169.254/"}`))
+	w := httptest.NewRecorder()
+	h.SetShortenerJSON(w, r)
+	result := w.Result()
+	result.Body.Close()
+	require.Equal(t, http.StatusForbidden, result.StatusCode)
+
+	// ссылка на внутренний адрес, созданная в обход сервиса, не открывается
+	_, err := store.SetShortener(context.Background(), model.Shortener{
+		Key:  model.ShortenerKey{Code: "internal"},
+		Data: model.ShortenerData{URL: "http://127.0.0.1/admin"},
+	})
+	require.NoError(t, err)
+	r = httptest.NewRequest(http.MethodGet, "/internal", nil)
+	r.SetPathValue("code", "internal")
+	w = httptest.NewRecorder()
+	h.GetShortener(w, r)
+	result = w.Result()
+	result.Body.Close()
+	require.Equal(t, http.StatusForbidden, result.StatusCode)
+}
+
+func TestHandlers_ClickStats(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+
+	// переход по ссылке
+	r := httptest.NewRequest(http.MethodGet, "/"+code, nil)
+	r.SetPathValue("code", code)
+	r.Header.Set("Referer", "https://example.com/")
+	w := httptest.NewRecorder()
+	h.GetShortener(w, r)
+	result := w.Result()
+	result.Body.Close()
+	require.Equal(t, http.StatusTemporaryRedirect, result.StatusCode)
+
+	stats := func(user, query string) (int, model.ClickStats) {
+		r := httptest.NewRequest(http.MethodGet, "/api/user/urls/"+code+"/stats"+query, nil)
+		r.SetPathValue("code", code)
+		r.Header.Set(auth.UserCodeKey, user)
+		w := httptest.NewRecorder()
+		h.GetClickStats(w, r)
+
+		result := w.Result()
+		defer result.Body.Close()
+		var response model.ClickStats
+		json.NewDecoder(result.Body).Decode(&response)
+		return result.StatusCode, response
+	}
+
+	// переходы записываются асинхронно
+	require.Eventually(t, func() bool {
+		status, response := stats("user1", "")
+		return status == http.StatusOK && response.Total == 1
+	}, 3*time.Second, 50*time.Millisecond)
+
+	status, response := stats("user1", "?bucket=30m&from="+time.Now().Add(-2*time.Hour).Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, status)
+	require.GreaterOrEqual(t, len(response.Buckets), 4)
+
+	status, _ = stats("user2", "")
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = stats("user1", "?bucket=1s")
+	require.Equal(t, http.StatusBadRequest, status)
+	status, _ = stats("user1", "?from=yesterday")
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestHandlers_InternalStats(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080", TrustedSubnet: "192.168.1.0/24"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+	router, _ := h.newRouter()
+
+	_, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+
+	stats := func(ip, query string) (int, model.StatsReport) {
+		r := httptest.NewRequest(http.MethodGet, "/api/internal/stats"+query, nil)
+		r.RemoteAddr = "192.168.1.2:4000"
+		r.Header.Set("X-Real-IP", ip)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		result := w.Result()
+		defer result.Body.Close()
+		var response model.StatsReport
+		json.NewDecoder(result.Body).Decode(&response)
+		return result.StatusCode, response
+	}
+
+	status, response := stats("192.168.1.10", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, 1, response.URLs)
+	require.Equal(t, 1, response.ActiveUsers)
+	require.Len(t, response.Created, 8)
+
+	from := time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	status, response = stats("192.168.1.10", "?top=5&from="+from)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, response.Created, 3)
+
+	status, _ = stats("10.0.0.1", "")
+	require.Equal(t, http.StatusForbidden, status)
+
+	// Заголовок от клиента не из доверенной подсети не учитывается
+	r := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+	r.RemoteAddr = "203.0.113.5:4000"
+	r.Header.Set("X-Real-IP", "192.168.1.10")
+	r.Header.Set("X-Forwarded-For", "192.168.1.10")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	status, _ = stats("192.168.1.10", "?to=yesterday")
+	require.Equal(t, http.StatusBadRequest, status)
+	status, _ = stats("192.168.1.10", "?from=2020-01-01T00:00:00Z")
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestHandlers_Update(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+	other, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://example.com/", User: "user1"}})
+	require.NoError(t, err)
+
+	request := func(handler http.HandlerFunc, method, path, user, body string) (int, string) {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.SetPathValue("code", code)
+		r.Header.Set(auth.UserCodeKey, user)
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		result := w.Result()
+		defer result.Body.Close()
+		respBody, _ := io.ReadAll(result.Body)
+		return result.StatusCode, string(respBody)
+	}
+
+	status, body := request(h.UpdateShortener, http.MethodPatch, "/api/user/urls/"+code, "user1", `{"url":"https://go.dev"}`)
+	require.Equal(t, http.StatusOK, status)
+	var updated GetUserURLsJSON
+	require.NoError(t, json.Unmarshal([]byte(body), &updated))
+	require.Equal(t, "https://go.dev/", updated.OriginalURL)
+	require.Equal(t, "http://localhost:8080/"+code, updated.ShortURL)
+
+	status, body = request(h.GetRevisions, http.MethodGet, "/api/user/urls/"+code+"/revisions", "user1", "")
+	require.Equal(t, http.StatusOK, status)
+	var revs []model.Revision
+	require.NoError(t, json.Unmarshal([]byte(body), &revs))
+	require.Len(t, revs, 2)
+	require.Equal(t, "https://ya.ru/", revs[0].URL)
+
+	status, body = request(h.RollbackShortener, http.MethodPost, "/api/user/urls/"+code+"/rollback", "user1", `{"revision":1}`)
+	require.Equal(t, http.StatusOK, status)
+	require.NoError(t, json.Unmarshal([]byte(body), &updated))
+	require.Equal(t, "https://ya.ru/", updated.OriginalURL)
+
+	// адрес уже сокращен другой ссылкой
+	status, body = request(h.UpdateShortener, http.MethodPatch, "/api/user/urls/"+code, "user1", `{"url":"https://example.com/"}`)
+	require.Equal(t, http.StatusConflict, status)
+	require.NoError(t, json.Unmarshal([]byte(body), &updated))
+	require.Equal(t, "http://localhost:8080/"+other.Key.Code, updated.ShortURL)
+
+	status, _ = request(h.UpdateShortener, http.MethodPatch, "/api/user/urls/"+code, "user2", `{"url":"https://go.dev"}`)
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = request(h.UpdateShortener, http.MethodPatch, "/api/user/urls/"+code, "user1", `{"url":"not a url"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+	status, _ = request(h.RollbackShortener, http.MethodPost, "/api/user/urls/"+code+"/rollback", "user1", `{"revision":10}`)
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+func TestHandlers_Trash(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+
+	request := func(handler http.HandlerFunc, method, path, user string) (int, string) {
+		r := httptest.NewRequest(method, path, nil)
+		r.SetPathValue("code", code)
+		r.Header.Set(auth.UserCodeKey, user)
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		result := w.Result()
+		defer result.Body.Close()
+		respBody, _ := io.ReadAll(result.Body)
+		return result.StatusCode, string(respBody)
+	}
+
+	status, _ := request(h.GetTrash, http.MethodGet, "/api/user/trash", "user1")
+	require.Equal(t, http.StatusNoContent, status)
+	status, _ = request(h.RestoreShortener, http.MethodPost, "/api/user/urls/"+code+"/restore", "user1")
+	require.Equal(t, http.StatusConflict, status)
+
+	_, err = store.DeleteShortenerBatch(context.Background(), []model.Shortener{{Key: resp.Key, Data: model.ShortenerData{User: "user1"}}})
+	require.NoError(t, err)
+	status, body := request(h.GetTrash, http.MethodGet, "/api/user/trash", "user1")
+	require.Equal(t, http.StatusOK, status)
+	var trash []DeletedURLJSON
+	require.NoError(t, json.Unmarshal([]byte(body), &trash))
+	require.Len(t, trash, 1)
+	require.Equal(t, "http://localhost:8080/"+code, trash[0].ShortURL)
+	require.NotNil(t, trash[0].DeletedAt)
+
+	status, _ = request(h.RestoreShortener, http.MethodPost, "/api/user/urls/"+code+"/restore", "user2")
+	require.Equal(t, http.StatusNotFound, status)
+	status, body = request(h.RestoreShortener, http.MethodPost, "/api/user/urls/"+code+"/restore", "user1")
+	require.Equal(t, http.StatusOK, status)
+	var restored GetUserURLsJSON
+	require.NoError(t, json.Unmarshal([]byte(body), &restored))
+	require.Equal(t, "https://ya.ru/", restored.OriginalURL)
+}
+
+func TestHandlers_DeleteJobs(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	t.Cleanup(shortenerService.Shutdown)
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+
+	request := func(handler http.HandlerFunc, method, path, id, user, body string) (int, string) {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.SetPathValue("id", id)
+		r.Header.Set(auth.UserCodeKey, user)
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		result := w.Result()
+		defer result.Body.Close()
+		respBody, _ := io.ReadAll(result.Body)
+		return result.StatusCode, string(respBody)
+	}
+
+	status, body := request(h.DeleteShortenerBatch, http.MethodDelete, "/api/user/urls", "", "user1", `["`+code+`","unknown"]`)
+	require.Equal(t, http.StatusAccepted, status)
+	var created DeleteJobIDJSON
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	require.NotEmpty(t, created.JobID)
+
+	status, body = request(h.GetDeleteJob, http.MethodGet, "/api/user/jobs/"+created.JobID, created.JobID, "user1", "")
+	require.Equal(t, http.StatusOK, status)
+	var job model.DeleteJob
+	require.NoError(t, json.Unmarshal([]byte(body), &job))
+	require.Equal(t, model.DeleteJobPending, job.Status)
+	status, _ = request(h.GetDeleteJob, http.MethodGet, "/api/user/jobs/"+created.JobID, created.JobID, "user2", "")
+	require.Equal(t, http.StatusNotFound, status)
+
+	// Задание выполняется не позже завершения работы сервиса
+	shortenerService.Shutdown()
+	status, body = request(h.GetDeleteJob, http.MethodGet, "/api/user/jobs/"+created.JobID, created.JobID, "user1", "")
+	require.Equal(t, http.StatusOK, status)
+	require.NoError(t, json.Unmarshal([]byte(body), &job))
+	require.Equal(t, model.DeleteJobDone, job.Status)
+	require.Equal(t, []model.DeleteJobCode{
+		{Code: code, Status: model.DeleteStatusDeleted},
+		{Code: "unknown", Status: model.DeleteStatusNotFound},
+	}, job.Codes)
+
+	status, _ = request(h.DeleteShortenerBatch, http.MethodDelete, "/api/user/urls", "", "user1", `not json`)
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestHandlers_Password(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	defer shortenerService.Shutdown()
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080", LinkSecret: "linksecret"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	r := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://ya.ru/","alias":"secret","password":"pass"}`))
+	w := httptest.NewRecorder()
+	h.SetShortenerJSON(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	get := func(cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/secret", nil)
+		r.SetPathValue("code", "secret")
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		h.GetShortener(w, r)
+		return w
+	}
+	unlock := func(password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/secret", strings.NewReader("password="+password))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetPathValue("code", "secret")
+		w := httptest.NewRecorder()
+		h.UnlockShortener(w, r)
+		return w
+	}
+
+	// без пароля - форма ввода пароля
+	w = get()
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Contains(t, w.Body.String(), `action="/secret"`)
+
+	// неверный пароль - форма повторно
+	w = unlock("wrong")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Contains(t, w.Body.String(), "Неверный пароль")
+
+	// верный пароль - переход и cookie доступа
+	w = unlock("pass")
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	require.Equal(t, "https://ya.ru/", w.Header().Get("Location"))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, "/secret", cookies[0].Path)
+
+	// повторный переход с cookie - без пароля, учитывается в статистике один раз
+	redirects := func() int {
+		report, err := shortenerService.GetStatsReport(context.Background(), time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 0)
+		require.NoError(t, err)
+		return report.Redirects.Total
+	}
+	total := redirects()
+	w = get(cookies[0])
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	require.Equal(t, "https://ya.ru/", w.Header().Get("Location"))
+	require.Equal(t, total+1, redirects())
+
+	// cookie другой ссылки и токен, подписанный другим ключом, не действуют
+	other, err := token.BuildLinkAccessString(cfg.LinkSecret, "other", time.Minute)
+	require.NoError(t, err)
+	w = get(&http.Cookie{Name: linkAccessCookie, Value: other})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	forged, err := token.BuildLinkAccessString("supersecretkey", "secret", time.Minute)
+	require.NoError(t, err)
+	w = get(&http.Cookie{Name: linkAccessCookie, Value: forged})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// слишком много неудачных попыток
+	for i := 0; i < 5; i++ {
+		unlock("wrong")
+	}
+	w = unlock("pass")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestHandlers_MaxClicks(t *testing.T) {
+	store, _ := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	shortenerService, _ := service.NewShortener(serviceConfig.Config{}, store)
+	defer shortenerService.Shutdown()
+	cfg := handlersConfig.Config{BaseAddr: "localhost:8080"}
+	h := newHandlers(cfg, shortenerService, zap.NewNop())
+
+	send := func(body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.SetShortenerJSON(w, r)
+		return w.Code
+	}
+	get := func(code string) int {
+		r := httptest.NewRequest(http.MethodGet, "/"+code, nil)
+		r.SetPathValue("code", code)
+		w := httptest.NewRecorder()
+		h.GetShortener(w, r)
+		return w.Code
+	}
+
+	// некорректное ограничение
+	require.Equal(t, http.StatusBadRequest, send(`{"url":"https://ya.ru/","max_clicks":-1}`))
+
+	// одноразовая ссылка
+	require.Equal(t, http.StatusCreated, send(`{"url":"https://ya.ru/","alias":"once","max_clicks":1}`))
+	require.Equal(t, http.StatusTemporaryRedirect, get("once"))
+	require.Equal(t, http.StatusGone, get("once"))
+
+	// ограничение из формы
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("url=https://practicum.yandex.ru/&max_clicks=2"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.SetShortener(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+	code := w.Body.String()[strings.LastIndexByte(w.Body.String(), '/')+1:]
+	require.Equal(t, http.StatusTemporaryRedirect, get(code))
+	require.Equal(t, http.StatusTemporaryRedirect, get(code))
+	require.Equal(t, http.StatusGone, get(code))
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("url=https://example.com/&max_clicks=many"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	h.SetShortener(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlers_ClientIP(t *testing.T) {
+	tests := []struct {
+		name          string
+		trustedSubnet string
+		remoteAddr    string
+		realIP        string
+		want          string
+	}{
+		{name: "proxy in trusted subnet", trustedSubnet: "192.168.1.0/24", remoteAddr: "192.168.1.10:4000", realIP: "203.0.113.7", want: "203.0.113.7"},
+		{name: "client sets header", trustedSubnet: "192.168.1.0/24", remoteAddr: "203.0.113.5:4000", realIP: "198.51.100.1", want: "203.0.113.5"},
+		{name: "no trusted subnet", remoteAddr: "192.168.1.10:4000", realIP: "203.0.113.7", want: "192.168.1.10"},
+		{name: "prefix is not a subnet", trustedSubnet: "192.168.1.0/24", remoteAddr: "192.168.10.1:4000", realIP: "203.0.113.7", want: "192.168.10.1"},
+		{name: "invalid trusted subnet", trustedSubnet: "192.168.1.", remoteAddr: "192.168.1.10:4000", realIP: "203.0.113.7", want: "192.168.1.10"},
+		{name: "no header", trustedSubnet: "192.168.1.0/24", remoteAddr: "192.168.1.10:4000", want: "192.168.1.10"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newHandlers(handlersConfig.Config{TrustedSubnet: test.trustedSubnet}, nil, zap.NewNop())
+			r := httptest.NewRequest(http.MethodGet, "/code01", nil)
+			r.RemoteAddr = test.remoteAddr
+			if test.realIP != "" {
+				r.Header.Set("X-Real-IP", test.realIP)
+			}
+			require.Equal(t, test.want, h.clientIP(r))
+		})
+	}
+}
@@ -1,6 +1,8 @@
 // Пакет model. Модели данных
 package model
 
+import "time"
+
 // Shortener - модель сокращенной ссылки
 type Shortener struct {
 	Key  ShortenerKey
@@ -14,8 +16,118 @@ type ShortenerKey struct {
 
 // ShortenerData - модель сокращенной ссылки. Данные
 type ShortenerData struct {
-	URL  string
-	User string
+	URL     string
+	User    string
+	DelFlag bool
+	// ExpiresAt - срок действия ссылки (нулевое значение - бессрочная)
+	ExpiresAt time.Time
+	// CreatedAt - время создания ссылки (нулевое значение - неизвестно, ссылка создана до учета времени)
+	CreatedAt time.Time
+	// DeletedAt - время удаления ссылки (нулевое значение - не удалена или удалена до учета времени)
+	DeletedAt time.Time
+	// PasswordHash - хэш пароля для перехода по ссылке (пусто - переход без пароля)
+	PasswordHash string
+	// MaxClicks - предельное кол-во переходов по ссылке (0 - без ограничения)
+	MaxClicks int
+	// ClicksLeft - остаток переходов по ссылке с ограничением
+	ClicksLeft int
+}
+
+// Expired - срок действия ссылки истек к моменту now
+func (data ShortenerData) Expired(now time.Time) bool {
+	return !data.ExpiresAt.IsZero() && !now.Before(data.ExpiresAt)
+}
+
+// Exhausted - переходы по ссылке с ограничением исчерпаны
+func (data ShortenerData) Exhausted() bool {
+	return data.MaxClicks > 0 && data.ClicksLeft <= 0
+}
+
+// Protected - переход по ссылке требует пароля
+func (data ShortenerData) Protected() bool {
+	return data.PasswordHash != ""
+}
+
+// Revision - адрес назначения ссылки в истории изменений
+type Revision struct {
+	// Revision - номер изменения, 1 - исходный адрес
+	Revision  int       `json:"revision"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ShortenerStatus - результат создания ссылки в пакете
+type ShortenerStatus int
+
+// Возможные результаты создания ссылки в пакете
+const (
+	// ShortenerStatusCreated - ссылка создана
+	ShortenerStatusCreated ShortenerStatus = iota
+	// ShortenerStatusExists - URL уже сокращен, возвращается существующий код
+	ShortenerStatusExists
+	// ShortenerStatusCodeConflict - код занят другой ссылкой, ссылка не создана
+	ShortenerStatusCodeConflict
+	// ShortenerStatusInvalid - некорректный запрос, ссылка не создана
+	ShortenerStatusInvalid
+)
+
+// ShortenerBatchRow - позиция пакетного создания ссылок
+type ShortenerBatchRow struct {
+	// ID - идентификатор позиции клиента (correlation_id), передается без изменений
+	ID        string
+	Shortener Shortener
+	Status    ShortenerStatus
+	// Err - причина, по которой позиция не создана
+	Err error
+}
+
+// DeleteStatus - результат удаления ссылки
+type DeleteStatus string
+
+// Возможные результаты удаления ссылки
+const (
+	// DeleteStatusDeleted - ссылка удалена
+	DeleteStatusDeleted DeleteStatus = "deleted"
+	// DeleteStatusAlreadyDeleted - ссылка была удалена раньше
+	DeleteStatusAlreadyDeleted DeleteStatus = "already_deleted"
+	// DeleteStatusNotFound - ссылки нет или она принадлежит другому пользователю
+	DeleteStatusNotFound DeleteStatus = "not_found"
+)
+
+// DeleteJobStatus - состояние задания удаления ссылок
+type DeleteJobStatus string
+
+// Возможные состояния задания удаления ссылок
+const (
+	// DeleteJobPending - задание ожидает выполнения или повтора
+	DeleteJobPending DeleteJobStatus = "pending"
+	// DeleteJobDone - задание выполнено, результат по каждому коду в Codes
+	DeleteJobDone DeleteJobStatus = "done"
+	// DeleteJobFailed - попытки выполнить задание исчерпаны
+	DeleteJobFailed DeleteJobStatus = "failed"
+)
+
+// DeleteJobCode - код ссылки в задании удаления и результат его удаления
+type DeleteJobCode struct {
+	Code string `json:"code"`
+	// Status - результат удаления (пусто - задание еще не выполнено)
+	Status DeleteStatus `json:"status,omitempty"`
+}
+
+// DeleteJob - задание удаления ссылок пользователя
+type DeleteJob struct {
+	ID     string          `json:"id"`
+	User   string          `json:"-"`
+	Status DeleteJobStatus `json:"status"`
+	Codes  []DeleteJobCode `json:"codes"`
+	// Attempts - кол-во неудачных попыток
+	Attempts int `json:"attempts"`
+	// Error - ошибка последней неудачной попытки
+	Error string `json:"error,omitempty"`
+	// NextAttempt - время следующей попытки выполнения задания
+	NextAttempt time.Time `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // Stats - статистические данные
@@ -23,3 +135,79 @@ type Stats struct {
 	URLs  int `json:"urls"`
 	Users int `json:"users"`
 }
+
+// Click - переход по короткой ссылке
+type Click struct {
+	Code      string
+	Time      time.Time
+	Referrer  string
+	UserAgent string
+	// IPHash - хэш адреса клиента (адрес не хранится)
+	IPHash string
+}
+
+// TimeBucket - кол-во событий за интервал, начинающийся в Start
+type TimeBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// ClickStats - статистика переходов по ссылке
+type ClickStats struct {
+	// Total - кол-во переходов за все время
+	Total int `json:"total"`
+	// Buckets - кол-во переходов по интервалам, в порядке времени
+	Buckets []TimeBucket `json:"buckets"`
+}
+
+// BucketStart - начало интервала длины bucket, в который попадает t.
+// Интервалы отсчитываются от начала unix-времени
+func BucketStart(t time.Time, bucket time.Duration) time.Time {
+	n := t.UnixNano()
+	return time.Unix(0, n-n%int64(bucket)).UTC()
+}
+
+// StatsDay - интервал статистики по суткам (UTC)
+const StatsDay = 24 * time.Hour
+
+// LinkClicks - кол-во переходов по ссылке
+type LinkClicks struct {
+	Code   string `json:"code"`
+	Clicks int    `json:"clicks"`
+}
+
+// RedirectStats - результаты переходов по коротким ссылкам.
+// Счетчики ведутся в памяти экземпляра сервиса с момента Since и не сохраняются
+type RedirectStats struct {
+	// Since - начало учета: запуск экземпляра сервиса
+	Since time.Time `json:"since"`
+	// Total - кол-во запросов перехода
+	Total int `json:"total"`
+	// NotFound - код не найден
+	NotFound int `json:"not_found"`
+	// Gone - ссылка удалена или истек срок действия
+	Gone int `json:"gone"`
+	// Blocked - адрес назначения запрещен политикой
+	Blocked int `json:"blocked"`
+	// Failed - внутренняя ошибка
+	Failed int `json:"failed"`
+	// ErrorRate - доля неуспешных переходов
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// StatsReport - статистика за период [From, To). Границы периода выровнены по суткам UTC
+type StatsReport struct {
+	Stats
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// DeletedURLs - кол-во ссылок, удаленных за период (до окончательного удаления)
+	DeletedURLs int `json:"deleted_urls"`
+	// ActiveUsers - кол-во пользователей, создавших ссылки за период
+	ActiveUsers int `json:"active_users"`
+	// Created - кол-во созданных ссылок по суткам, в порядке времени
+	Created []TimeBucket `json:"created"`
+	// TopLinks - ссылки с наибольшим кол-вом переходов за период
+	TopLinks []LinkClicks `json:"top_links"`
+	// Redirects - результаты переходов за период по данным экземпляра сервиса с момента его запуска
+	Redirects RedirectStats `json:"redirects"`
+}
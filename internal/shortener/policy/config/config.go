@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// Конфигурация policy
+type Config struct {
+	// RulesFile - путь к JSON-файлу правил (пусто - правила по умолчанию)
+	RulesFile string
+	// ReloadInterval - периодичность проверки изменения файла правил (0 - без перезагрузки)
+	ReloadInterval time.Duration
+}
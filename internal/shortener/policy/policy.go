@@ -0,0 +1,382 @@
+// Пакет policy. Политика допустимых адресов назначения
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/idna"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/policy/config"
+)
+
+// Ошибки пакета
+var (
+	// ErrBlocked - адрес запрещен правилами (блок-лист, регулярное выражение)
+	ErrBlocked = errors.New("destination is blocked")
+	// ErrForbidden - адрес недоступен для сокращения (внутренний адрес, нет в разрешенном списке)
+	ErrForbidden = errors.New("destination is forbidden")
+)
+
+const (
+	// resolveTimeout - предельное время разрешения имени хоста
+	resolveTimeout = 2 * time.Second
+	// resolveTTL - время хранения адресов хоста в кэше
+	resolveTTL = time.Minute
+	// resolveCacheSize - предельное число хостов в кэше
+	resolveCacheSize = 4096
+)
+
+// RulesJSON - структура файла правил.
+// Домен в списках соответствует себе и своим поддоменам
+type RulesJSON struct {
+	// Allow - разрешенные домены. Пустой список - разрешены все, кроме запрещенных
+	Allow []string `json:"allow"`
+	// Block - запрещенные домены
+	Block []string `json:"block"`
+	// BlockRegex - регулярные выражения для полного URL
+	BlockRegex []string `json:"block_regex"`
+	// AllowPrivate - разрешить внутренние адреса (loopback, частные сети, localhost)
+	AllowPrivate bool `json:"allow_private"`
+	// ResolveHosts - проверять адреса, в которые разрешается имя хоста
+	ResolveHosts bool `json:"resolve_hosts"`
+}
+
+// rules - подготовленные правила
+type rules struct {
+	allow        []string
+	block        []string
+	blockRegex   []*regexp.Regexp
+	allowPrivate bool
+	resolveHosts bool
+}
+
+// Engine - проверка адресов назначения по правилам из файла.
+// Изменение файла подхватывается без перезапуска
+type Engine struct {
+	cfg   config.Config
+	rules atomic.Pointer[rules]
+	// mux упорядочивает перезагрузку файла
+	mux sync.Mutex
+	// modTime - время изменения загруженного файла
+	modTime time.Time
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	// lookup разрешает имя хоста; адреса хранятся в кэше resolved до истечения resolveTTL,
+	// чтобы проверка при каждом переходе не обращалась к DNS
+	lookup      func(ctx context.Context, host string) ([]netip.Addr, error)
+	resolvedMux sync.Mutex
+	resolved    map[string]resolvedHost
+}
+
+// resolvedHost - адреса хоста в кэше
+type resolvedHost struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// New - конструктор. Ошибка в файле правил при запуске возвращается, при перезагрузке - пишется в журнал
+func New(cfg config.Config) (*Engine, error) {
+	engine := &Engine{
+		cfg:  cfg,
+		done: make(chan struct{}),
+		lookup: func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		},
+		resolved: make(map[string]resolvedHost),
+	}
+	engine.rules.Store(&rules{})
+	if cfg.RulesFile == "" {
+		return engine, nil
+	}
+
+	if err := engine.Reload(); err != nil {
+		return nil, err
+	}
+	if cfg.ReloadInterval > 0 {
+		engine.wg.Add(1)
+		go engine.reloadLoop(cfg.ReloadInterval)
+	}
+	return engine, nil
+}
+
+// Reload перечитывает файл правил
+func (engine *Engine) Reload() error {
+	engine.mux.Lock()
+	defer engine.mux.Unlock()
+
+	info, err := os.Stat(engine.cfg.RulesFile)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(engine.cfg.RulesFile)
+	if err != nil {
+		return err
+	}
+	var rulesJSON RulesJSON
+	if err := json.Unmarshal(data, &rulesJSON); err != nil {
+		return fmt.Errorf("policy file %s: %w", engine.cfg.RulesFile, err)
+	}
+	compiled, err := compile(rulesJSON)
+	if err != nil {
+		return fmt.Errorf("policy file %s: %w", engine.cfg.RulesFile, err)
+	}
+
+	engine.rules.Store(compiled)
+	engine.modTime = info.ModTime()
+	return nil
+}
+
+// reloadLoop перечитывает файл правил при изменении
+func (engine *Engine) reloadLoop(interval time.Duration) {
+	defer engine.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-engine.done:
+			return
+		case <-ticker.C:
+			if !engine.changed() {
+				continue
+			}
+			if err := engine.Reload(); err != nil {
+				log.Printf("policy: reload failed, keeping previous rules: %s", err)
+			}
+		}
+	}
+}
+
+// changed - файл правил изменен после загрузки
+func (engine *Engine) changed() bool {
+	engine.mux.Lock()
+	defer engine.mux.Unlock()
+
+	info, err := os.Stat(engine.cfg.RulesFile)
+	return err == nil && !info.ModTime().Equal(engine.modTime)
+}
+
+// Close останавливает перезагрузку правил
+func (engine *Engine) Close() {
+	close(engine.done)
+	engine.wg.Wait()
+}
+
+// compile проверяет и подготавливает правила
+func compile(rulesJSON RulesJSON) (*rules, error) {
+	compiled := &rules{allowPrivate: rulesJSON.AllowPrivate, resolveHosts: rulesJSON.ResolveHosts}
+	var err error
+	if compiled.allow, err = domains(rulesJSON.Allow); err != nil {
+		return nil, err
+	}
+	if compiled.block, err = domains(rulesJSON.Block); err != nil {
+		return nil, err
+	}
+	for _, expr := range rulesJSON.BlockRegex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		compiled.blockRegex = append(compiled.blockRegex, re)
+	}
+	return compiled, nil
+}
+
+// domains приводит домены списка к виду хоста нормализованного URL
+func domains(list []string) ([]string, error) {
+	resp := make([]string, 0, len(list))
+	for _, raw := range list {
+		domain := strings.TrimPrefix(strings.TrimPrefix(raw, "*"), ".")
+		domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(domain, "."))
+		if err != nil || domain == "" {
+			return nil, fmt.Errorf("invalid domain %q", raw)
+		}
+		resp = append(resp, domain)
+	}
+	return resp, nil
+}
+
+// matchDomain - хост совпадает с доменом списка или является его поддоменом
+func matchDomain(host string, list []string) bool {
+	for _, domain := range list {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check проверяет адрес назначения. Возвращает ErrBlocked или ErrForbidden для недопустимого адреса
+func (engine *Engine) Check(ctx context.Context, rawURL string) error {
+	r := engine.rules.Load()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbidden, err.Error())
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	// Числовой хост в сокращенной, восьмеричной, шестнадцатеричной или десятичной форме
+	// браузер понимает как IPv4-адрес: проверяется адрес в стандартной записи
+	if addr, numeric := ipv4Host(host); numeric {
+		if !addr.IsValid() {
+			return fmt.Errorf("%w: invalid IPv4 host %s", ErrForbidden, host)
+		}
+		host = addr.String()
+	}
+
+	if matchDomain(host, r.block) {
+		return fmt.Errorf("%w: host %s", ErrBlocked, host)
+	}
+	for _, re := range r.blockRegex {
+		if re.MatchString(rawURL) {
+			return fmt.Errorf("%w: matches %s", ErrBlocked, re.String())
+		}
+	}
+	if len(r.allow) > 0 && !matchDomain(host, r.allow) {
+		return fmt.Errorf("%w: host %s is not allowed", ErrForbidden, host)
+	}
+	if r.allowPrivate {
+		return nil
+	}
+
+	if internalHost(host) {
+		return fmt.Errorf("%w: internal host %s", ErrForbidden, host)
+	}
+	if _, err := netip.ParseAddr(host); err != nil && r.resolveHosts {
+		for _, addr := range engine.resolve(ctx, host) {
+			if internalAddr(addr) {
+				return fmt.Errorf("%w: host %s resolves to internal address %s", ErrForbidden, host, addr)
+			}
+		}
+	}
+	return nil
+}
+
+// resolve возвращает адреса хоста из кэша или разрешает имя.
+// Ошибка разрешения имени не запрещает адрес: хост может стать доступен позже
+func (engine *Engine) resolve(ctx context.Context, host string) []netip.Addr {
+	now := time.Now()
+	engine.resolvedMux.Lock()
+	cached, ok := engine.resolved[host]
+	engine.resolvedMux.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.addrs
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, _ := engine.lookup(lookupCtx, host)
+	// Отмена запроса вызывающим не говорит о хосте: такой результат не сохраняется
+	if ctx.Err() != nil {
+		return addrs
+	}
+
+	engine.resolvedMux.Lock()
+	defer engine.resolvedMux.Unlock()
+	if len(engine.resolved) >= resolveCacheSize {
+		for key, entry := range engine.resolved {
+			if !now.Before(entry.expires) {
+				delete(engine.resolved, key)
+			}
+		}
+		if len(engine.resolved) >= resolveCacheSize {
+			clear(engine.resolved)
+		}
+	}
+	engine.resolved[host] = resolvedHost{addrs: addrs, expires: now.Add(resolveTTL)}
+	return addrs
+}
+
+// ipv4Host разбирает хост, оканчивающийся числом, как IPv4-адрес по правилам URL-парсера браузеров:
+// от одной до четырех частей, десятичных, восьмеричных (0177) или шестнадцатеричных (0x7f),
+// последняя часть заполняет оставшиеся байты адреса (127.1, 2130706433).
+// numeric - хост оканчивается числом; некорректный такой хост возвращается с невалидным адресом
+func ipv4Host(host string) (addr netip.Addr, numeric bool) {
+	if strings.Contains(host, ":") {
+		return netip.Addr{}, false
+	}
+	parts := strings.Split(host, ".")
+	if !numericPart(parts[len(parts)-1]) {
+		return netip.Addr{}, false
+	}
+	if len(parts) > 4 {
+		return netip.Addr{}, true
+	}
+
+	var value uint64
+	for i, part := range parts {
+		n, err := parseIPv4Part(part)
+		if err != nil {
+			return netip.Addr{}, true
+		}
+		if i < len(parts)-1 {
+			if n > 0xff {
+				return netip.Addr{}, true
+			}
+			value |= n << (8 * (3 - i))
+			continue
+		}
+		// Последняя часть занимает оставшиеся 5-len(parts) байта
+		if n >= 1<<(8*(5-len(parts))) {
+			return netip.Addr{}, true
+		}
+		value |= n
+	}
+	return netip.AddrFrom4([4]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}), true
+}
+
+// numericPart - часть хоста из десятичных цифр или шестнадцатеричное число с префиксом 0x
+func numericPart(part string) bool {
+	digits := "0123456789"
+	if rest, ok := strings.CutPrefix(part, "0x"); ok {
+		part, digits = rest, "0123456789abcdef"
+		if part == "" {
+			return true
+		}
+	}
+	return part != "" && strings.Trim(part, digits) == ""
+}
+
+// parseIPv4Part разбирает часть IPv4-адреса: 0x - шестнадцатеричная, ведущий 0 - восьмеричная
+func parseIPv4Part(part string) (uint64, error) {
+	switch {
+	case part == "":
+		return 0, errors.New("empty part")
+	case strings.HasPrefix(part, "0x"):
+		return strconv.ParseUint("0"+part[2:], 16, 32)
+	case len(part) > 1 && part[0] == '0':
+		return strconv.ParseUint(part[1:], 8, 32)
+	default:
+		return strconv.ParseUint(part, 10, 32)
+	}
+}
+
+// internalHost - адрес или имя внутренней сети: IP-адрес внутренней сети, localhost, имя без домена
+func internalHost(host string) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return internalAddr(addr)
+	}
+	return host == "localhost" || strings.HasSuffix(host, ".localhost") || !strings.Contains(host, ".")
+}
+
+// internalAddr - адрес loopback, частной сети, link-local, групповой или неопределенный
+func internalAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified()
+}
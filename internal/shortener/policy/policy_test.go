@@ -0,0 +1,167 @@
+package policy
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/policy/config"
+)
+
+func TestEngine_Check(t *testing.T) {
+	ctx := context.Background()
+
+	// Правила по умолчанию: запрещены только внутренние адреса
+	engine, err := New(config.Config{})
+	require.NoError(t, err)
+	defer engine.Close()
+	require.NoError(t, engine.Check(ctx, "https://ya.ru/"))
+	for _, url := range []string{
+		"http://127.0.0.1/",
+		"http://10.0.0.1:8080/admin",
+		"http://192.168.1.1/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/",
+		"http://[::ffff:127.0.0.1]/",
+		"http://0.0.0.0/",
+		"http://localhost/",
+		"http://api.localhost/",
+		"http://intranet/",
+		// Числовые формы IPv4, которые браузер приводит к 127.0.0.1 и 10.0.0.1
+		"http://127.1/",
+		"http://0177.0.0.1/",
+		"http://2130706433/",
+		"http://0x7f.0.0.1/",
+		"http://0x7f000001/",
+		"http://10.1/",
+		"http://127.0.0.1./",
+		// Некорректные числовые хосты
+		"http://256.0.0.1/",
+		"http://1.2.3.4.5/",
+		"http://08.0.0.1/",
+		"http://example.123/",
+	} {
+		require.ErrorIs(t, engine.Check(ctx, url), ErrForbidden, url)
+	}
+	require.NoError(t, engine.Check(ctx, "http://0x5d.0xb8.0xd8.0x22/"))
+	require.NoError(t, engine.Check(ctx, "http://1.2.3.example/"))
+
+	rulesFile := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`{
+		"block": ["evil.com", "*.phishing.org"],
+		"block_regex": ["/login\\.php$"],
+		"allow_private": true
+	}`), 0666))
+	engine, err = New(config.Config{RulesFile: rulesFile})
+	require.NoError(t, err)
+	defer engine.Close()
+
+	require.ErrorIs(t, engine.Check(ctx, "https://evil.com/"), ErrBlocked)
+	require.ErrorIs(t, engine.Check(ctx, "https://www.evil.com/"), ErrBlocked)
+	require.ErrorIs(t, engine.Check(ctx, "https://phishing.org/"), ErrBlocked)
+	require.ErrorIs(t, engine.Check(ctx, "https://bank.example.com/login.php"), ErrBlocked)
+	require.NoError(t, engine.Check(ctx, "https://notevil.com/"))
+	require.NoError(t, engine.Check(ctx, "http://127.0.0.1/"))
+
+	// Разрешенный список
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`{"allow": ["example.com", "пример.рф"]}`), 0666))
+	require.NoError(t, engine.Reload())
+	require.NoError(t, engine.Check(ctx, "https://docs.example.com/"))
+	require.NoError(t, engine.Check(ctx, "https://xn--e1afmkfd.xn--p1ai/"))
+	require.ErrorIs(t, engine.Check(ctx, "https://ya.ru/"), ErrForbidden)
+
+	// Некорректные правила
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`{"block_regex": ["("]}`), 0666))
+	require.Error(t, engine.Reload())
+	_, err = New(config.Config{RulesFile: rulesFile})
+	require.Error(t, err)
+}
+
+func TestIPv4Host(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    string
+		numeric bool
+	}{
+		{host: "127.0.0.1", want: "127.0.0.1", numeric: true},
+		{host: "127.1", want: "127.0.0.1", numeric: true},
+		{host: "127.0.1", want: "127.0.0.1", numeric: true},
+		{host: "0177.0.0.1", want: "127.0.0.1", numeric: true},
+		{host: "0x7f.0.0.1", want: "127.0.0.1", numeric: true},
+		{host: "2130706433", want: "127.0.0.1", numeric: true},
+		{host: "0x", want: "0.0.0.0", numeric: true},
+		{host: "4294967296", numeric: true},
+		{host: "1.2.65536", numeric: true},
+		{host: "1..2", numeric: true},
+		{host: "example.com"},
+		{host: "0x7f.example"},
+		{host: "::1"},
+	}
+	for _, test := range tests {
+		addr, numeric := ipv4Host(test.host)
+		require.Equal(t, test.numeric, numeric, test.host)
+		if test.want == "" {
+			require.False(t, addr.IsValid(), test.host)
+			continue
+		}
+		require.Equal(t, test.want, addr.String(), test.host)
+	}
+}
+
+func TestEngine_ResolveCache(t *testing.T) {
+	ctx := context.Background()
+	rulesFile := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`{"resolve_hosts": true}`), 0666))
+	engine, err := New(config.Config{RulesFile: rulesFile})
+	require.NoError(t, err)
+	defer engine.Close()
+
+	lookups := 0
+	engine.lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		lookups++
+		if host == "internal.example.com" {
+			return []netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil
+		}
+		return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+	}
+
+	for range 3 {
+		require.NoError(t, engine.Check(ctx, "https://example.com/"))
+		require.ErrorIs(t, engine.Check(ctx, "https://internal.example.com/"), ErrForbidden)
+	}
+	require.Equal(t, 2, lookups)
+
+	// Истекшая запись разрешается заново
+	engine.resolved["example.com"] = resolvedHost{expires: time.Now().Add(-time.Second)}
+	require.NoError(t, engine.Check(ctx, "https://example.com/"))
+	require.Equal(t, 3, lookups)
+}
+
+func TestEngine_HotReload(t *testing.T) {
+	ctx := context.Background()
+	rulesFile := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`{}`), 0666))
+
+	engine, err := New(config.Config{RulesFile: rulesFile, ReloadInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer engine.Close()
+	require.NoError(t, engine.Check(ctx, "https://evil.com/"))
+
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`{"block": ["evil.com"]}`), 0666))
+	// Время изменения файла может совпасть с прежним при грубой точности часов ФС
+	require.NoError(t, os.Chtimes(rulesFile, time.Now(), time.Now().Add(time.Second)))
+	require.Eventually(t, func() bool {
+		return engine.Check(ctx, "https://evil.com/") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// Ошибка в файле не сбрасывает загруженные правила
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`{`), 0666))
+	require.NoError(t, os.Chtimes(rulesFile, time.Now(), time.Now().Add(2*time.Second)))
+	time.Sleep(50 * time.Millisecond)
+	require.ErrorIs(t, engine.Check(ctx, "https://evil.com/"), ErrBlocked)
+}
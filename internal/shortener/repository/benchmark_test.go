@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iurnickita/vigilant-train/internal/common/rand"
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+)
+
+// newBenchBatch - пакет ссылок с уникальными URL
+func newBenchBatch(n int) []model.ShortenerBatchRow {
+	batch := make([]model.ShortenerBatchRow, n)
+	prefix := rand.String(8)
+	for i := range batch {
+		batch[i].Shortener = model.Shortener{
+			Key:  model.ShortenerKey{Code: rand.String(10)},
+			Data: model.ShortenerData{URL: fmt.Sprintf("https://example.com/%s/%d", prefix, i), User: "bench"},
+		}
+	}
+	return batch
+}
+
+// Для запуска нужна тестовая база: TEST_DATABASE_DSN="..." go test -bench StoreDB_SetShortenerBatch
+func BenchmarkStoreDB_SetShortenerBatch(b *testing.B) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		b.Skip("TEST_DATABASE_DSN is not set")
+	}
+	store, err := NewStoreDB(config.Config{DBDsn: dsn})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	for _, size := range []int{100, 10000} {
+		// Один запрос на пакет
+		b.Run(fmt.Sprintf("bulk/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				batch := newBenchBatch(size)
+				b.StartTimer()
+				if _, err := store.SetShortenerBatch(ctx, batch); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		// Прежний способ: запрос на каждую позицию
+		b.Run(fmt.Sprintf("row_by_row/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				batch := newBenchBatch(size)
+				b.StartTimer()
+				for _, row := range batch {
+					if _, err := store.SetShortener(ctx, row.Shortener); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkStoreFile_SetShortenerBatch(b *testing.B) {
+	store, err := NewStoreFile(config.Config{Filename: filepath.Join(b.TempDir(), "store.json")})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		batch := newBenchBatch(100)
+		b.StartTimer()
+		if _, err := store.SetShortenerBatch(ctx, batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// newBenchStore - хранилище в памяти, заполненное n ссылками n/10 пользователей
+func newBenchStore(b *testing.B, n int) *StoreVar {
+	store, err := NewStoreVar(config.Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	batch := newBenchBatch(n)
+	for i := range batch {
+		batch[i].Shortener.Data.User = fmt.Sprintf("user%d", i%(n/10+1))
+	}
+	if _, err := store.SetShortenerBatch(context.Background(), batch); err != nil {
+		b.Fatal(err)
+	}
+	return store
+}
+
+// Время не должно зависеть от кол-ва ссылок в хранилище
+func BenchmarkStoreVar_SetShortener(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("stored/%d", size), func(b *testing.B) {
+			store := newBenchStore(b, size)
+			batch := newBenchBatch(b.N)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.SetShortener(ctx, batch[i].Shortener); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkStoreVar_GetStats(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("stored/%d", size), func(b *testing.B) {
+			store := newBenchStore(b, size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.GetStats(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Чтение по коду с нескольких ядер: go test -bench StoreVar_GetShortenerParallel -cpu 1,4,8
+func BenchmarkStoreVar_GetShortenerParallel(b *testing.B) {
+	store := newBenchStore(b, 100000)
+	links, err := store.GetShortenerBatch(context.Background(), "user0")
+	if err != nil || len(links) == 0 {
+		b.Fatal("no links", err)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := store.GetShortener(links[i%len(links)].Key.Code); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
+
+// Смешанная нагрузка: одна запись на 10 чтений
+func BenchmarkStoreVar_MixedParallel(b *testing.B) {
+	store := newBenchStore(b, 100000)
+	links, err := store.GetShortenerBatch(context.Background(), "user0")
+	if err != nil || len(links) == 0 {
+		b.Fatal("no links", err)
+	}
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		batch := newBenchBatch(1)
+		prefix := batch[0].Shortener.Key.Code
+		i := 0
+		for pb.Next() {
+			if i%10 == 0 {
+				s := model.Shortener{
+					Key:  model.ShortenerKey{Code: fmt.Sprintf("%s%d", prefix, i)},
+					Data: model.ShortenerData{URL: fmt.Sprintf("https://example.com/%s/%d", prefix, i)},
+				}
+				if _, err := store.SetShortener(ctx, s); err != nil {
+					b.Fatal(err)
+				}
+			} else if _, err := store.GetShortener(links[i%len(links)].Key.Code); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+)
+
+// StoreCache - Декоратор хранилища с кэшем ссылок в памяти (LRU).
+// Кэшируются найденные ссылки и ответы "не найдено"/"удалено".
+// Изменения через этот экземпляр сбрасывают кэш сразу, изменения других экземпляров сервиса -
+// по истечении CacheTTL
+type StoreCache struct {
+	Repository
+	mux   sync.Mutex
+	size  int
+	ttl   time.Duration
+	lru   *list.List
+	items map[string]*list.Element
+	// epoch увеличивается при каждом сбросе: результат чтения, начатого до сброса, не кэшируется
+	epoch  uint64
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	now    func() time.Time
+}
+
+// cacheEntry - элемент кэша
+type cacheEntry struct {
+	code      string
+	shortener model.Shortener
+	err       error
+	expires   time.Time
+}
+
+// CacheStats - счетчики кэша
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Len    int
+}
+
+// NewStoreCache - конструктор декоратора
+func NewStoreCache(cfg config.Config, store Repository) *StoreCache {
+	return &StoreCache{
+		Repository: store,
+		size:       cfg.CacheSize,
+		ttl:        cfg.CacheTTL,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// GetShortener читает короткую ссылку из кэша или хранилища
+func (store *StoreCache) GetShortener(code string) (model.Shortener, error) {
+	store.mux.Lock()
+	if elem, ok := store.items[code]; ok {
+		entry := elem.Value.(*cacheEntry)
+		now := store.now()
+		if (store.ttl == 0 || now.Before(entry.expires)) && !entry.shortener.Data.Expired(now) {
+			store.lru.MoveToFront(elem)
+			store.mux.Unlock()
+			store.hits.Add(1)
+			return entry.shortener, entry.err
+		}
+		// Элемент устарел или истек срок действия ссылки
+		store.remove(elem)
+	}
+	epoch := store.epoch
+	store.mux.Unlock()
+	store.misses.Add(1)
+
+	s, err := store.Repository.GetShortener(code)
+	if err == nil || errors.Is(err, ErrGetShortenerNotFound) || errors.Is(err, ErrGetShortenerGone) {
+		store.put(epoch, &cacheEntry{code: code, shortener: s, err: err})
+	}
+	return s, err
+}
+
+// UseShortener учитывает переход по ссылке с ограничением кол-ва переходов и сбрасывает ее кэш
+func (store *StoreCache) UseShortener(ctx context.Context, code string) (model.Shortener, error) {
+	resp, err := store.Repository.UseShortener(ctx, code)
+	store.invalidate(code)
+	return resp, err
+}
+
+// put добавляет элемент, если с начала чтения кэш не сбрасывался
+func (store *StoreCache) put(epoch uint64, entry *cacheEntry) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	if epoch != store.epoch {
+		return
+	}
+	if store.ttl > 0 {
+		entry.expires = store.now().Add(store.ttl)
+	}
+	if elem, ok := store.items[entry.code]; ok {
+		elem.Value = entry
+		store.lru.MoveToFront(elem)
+		return
+	}
+	store.items[entry.code] = store.lru.PushFront(entry)
+	for store.lru.Len() > store.size {
+		store.remove(store.lru.Back())
+	}
+}
+
+// remove удаляет элемент. Вызывается под блокировкой
+func (store *StoreCache) remove(elem *list.Element) {
+	store.lru.Remove(elem)
+	delete(store.items, elem.Value.(*cacheEntry).code)
+}
+
+// invalidate сбрасывает кэш для кодов
+func (store *StoreCache) invalidate(codes ...string) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	store.epoch++
+	for _, code := range codes {
+		if elem, ok := store.items[code]; ok {
+			store.remove(elem)
+		}
+	}
+}
+
+// SetShortener создает короткую ссылку. Сбрасывает ответ "не найдено" для нового кода
+func (store *StoreCache) SetShortener(ctx context.Context, s model.Shortener) (model.Shortener, error) {
+	resp, err := store.Repository.SetShortener(ctx, s)
+	if err == nil {
+		store.invalidate(s.Key.Code)
+	}
+	return resp, err
+}
+
+// SetShortenerBatch создает короткую ссылку для набора данных
+func (store *StoreCache) SetShortenerBatch(ctx context.Context, s []model.ShortenerBatchRow) ([]model.ShortenerBatchRow, error) {
+	resp, err := store.Repository.SetShortenerBatch(ctx, s)
+	if err != nil {
+		return resp, err
+	}
+	codes := make([]string, 0, len(resp))
+	for _, row := range resp {
+		if row.Status == model.ShortenerStatusCreated {
+			codes = append(codes, row.Shortener.Key.Code)
+		}
+	}
+	store.invalidate(codes...)
+	return resp, nil
+}
+
+// UpdateShortener меняет адрес назначения ссылки и сбрасывает ее кэш
+func (store *StoreCache) UpdateShortener(ctx context.Context, s model.Shortener) (model.Shortener, error) {
+	resp, err := store.Repository.UpdateShortener(ctx, s)
+	store.invalidate(s.Key.Code)
+	return resp, err
+}
+
+// DeleteShortenerBatch удаляет короткую ссылку и сбрасывает ее кэш
+func (store *StoreCache) DeleteShortenerBatch(ctx context.Context, s []model.Shortener) ([]model.DeleteStatus, error) {
+	resp, err := store.Repository.DeleteShortenerBatch(ctx, s)
+	codes := make([]string, len(s))
+	for i := range s {
+		codes[i] = s[i].Key.Code
+	}
+	// Сброс и при ошибке: часть пакета могла быть удалена
+	store.invalidate(codes...)
+	return resp, err
+}
+
+// RestoreShortener восстанавливает удаленную ссылку и сбрасывает ее кэш
+func (store *StoreCache) RestoreShortener(ctx context.Context, s model.Shortener, deletedAfter time.Time) (model.Shortener, error) {
+	resp, err := store.Repository.RestoreShortener(ctx, s, deletedAfter)
+	store.invalidate(s.Key.Code)
+	return resp, err
+}
+
+// PurgeDeleted окончательно удаляет ссылки, удаленные раньше before, и сбрасывает кэш удаленных ссылок
+func (store *StoreCache) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	n, err := store.Repository.PurgeDeleted(ctx, before)
+	if n == 0 {
+		return n, err
+	}
+
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	store.epoch++
+	for elem := store.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if errors.Is(elem.Value.(*cacheEntry).err, ErrGetShortenerGone) {
+			store.remove(elem)
+		}
+		elem = next
+	}
+	return n, err
+}
+
+// DeleteExpired окончательно удаляет истекшие ссылки и сбрасывает их кэш
+func (store *StoreCache) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	n, err := store.Repository.DeleteExpired(ctx, now)
+	if n == 0 {
+		return n, err
+	}
+
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	store.epoch++
+	for elem := store.lru.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*cacheEntry)
+		if errors.Is(entry.err, ErrGetShortenerExpired) || entry.shortener.Data.Expired(now) {
+			store.remove(elem)
+		}
+		elem = next
+	}
+	return n, err
+}
+
+// CacheStats возвращает счетчики кэша
+func (store *StoreCache) CacheStats() CacheStats {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	return CacheStats{
+		Hits:   store.hits.Load(),
+		Misses: store.misses.Load(),
+		Len:    store.lru.Len(),
+	}
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+)
+
+func TestConformance_StoreCache(t *testing.T) {
+	Conformance(t, func(t *testing.T) Repository {
+		store, err := NewStoreVar(config.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewStoreCache(config.Config{CacheSize: 2}, store)
+	})
+}
+
+func TestStoreCache(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := NewStoreVar(config.Config{})
+	store := NewStoreCache(config.Config{CacheSize: 2, CacheTTL: time.Minute}, backend)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	a := newConformanceShortener("a", "https://a.ru/", "u1")
+	b := newConformanceShortener("b", "https://b.ru/", "u1")
+
+	// Отрицательный ответ кэшируется и сбрасывается при создании ссылки
+	if _, err := store.GetShortener("a"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Fatalf("get unknown code: %v", err)
+	}
+	if _, err := store.GetShortener("a"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Fatalf("get unknown code from cache: %v", err)
+	}
+	mustSetShortener(t, store, a)
+	mustSetShortener(t, store, b)
+	if got, err := store.GetShortener("a"); err != nil || got.Data.URL != a.Data.URL {
+		t.Fatalf("get after create = %+v, %v", got, err)
+	}
+	store.GetShortener("a")
+	if stats := store.CacheStats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("counters = %+v, want 2 hits and 2 misses", stats)
+	}
+
+	// Удаление сбрасывает кэш
+	if _, err := store.DeleteShortenerBatch(ctx, []model.Shortener{a}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetShortener("a"); !errors.Is(err, ErrGetShortenerGone) {
+		t.Errorf("get deleted code: %v", err)
+	}
+
+	// Восстановление и окончательное удаление сбрасывают кэш
+	if _, err := store.RestoreShortener(ctx, a, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetShortener("a"); err != nil {
+		t.Errorf("get restored code: %v", err)
+	}
+	if _, err := store.DeleteShortenerBatch(ctx, []model.Shortener{a}); err != nil {
+		t.Fatal(err)
+	}
+	store.GetShortener("a")
+	if _, err := store.PurgeDeleted(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetShortener("a"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("get purged code: %v", err)
+	}
+
+	// Вытеснение самой давно прочитанной ссылки
+	store.GetShortener("b")
+	store.GetShortener("c")
+	if _, ok := store.items["a"]; ok {
+		t.Error("least recently used code is not evicted")
+	}
+	if stats := store.CacheStats(); stats.Len != 2 {
+		t.Errorf("cache len = %d, want 2", stats.Len)
+	}
+
+	// Изменение в обход кэша видно после истечения TTL
+	backend.DeleteShortenerBatch(ctx, []model.Shortener{b})
+	if _, err := store.GetShortener("b"); err != nil {
+		t.Errorf("cached link: %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := store.GetShortener("b"); !errors.Is(err, ErrGetShortenerGone) {
+		t.Errorf("get expired entry: %v", err)
+	}
+}
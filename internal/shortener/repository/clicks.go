@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+)
+
+// clickLog - моменты переходов по ссылкам в памяти. Используется StoreVar и StoreFile
+type clickLog struct {
+	mux    sync.RWMutex
+	byCode map[string][]time.Time
+}
+
+// newClickLog - конструктор
+func newClickLog() *clickLog {
+	return &clickLog{byCode: make(map[string][]time.Time)}
+}
+
+// add добавляет переходы. Моменты переходов ссылки хранятся упорядоченными
+func (l *clickLog) add(clicks []model.Click) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	for _, click := range clicks {
+		times := l.byCode[click.Code]
+		// Почти всегда переход добавляется в конец
+		i := sort.Search(len(times), func(j int) bool { return times[j].After(click.Time) })
+		l.byCode[click.Code] = slices.Insert(times, i, click.Time)
+	}
+}
+
+// remove удаляет переходы по окончательно удаленным ссылкам
+func (l *clickLog) remove(purged []model.Shortener) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	for _, s := range purged {
+		delete(l.byCode, s.Key.Code)
+	}
+}
+
+// stats - статистика переходов по ссылке начиная с from
+func (l *clickLog) stats(code string, from time.Time, bucket time.Duration) model.ClickStats {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	times := l.byCode[code]
+	i, _ := slices.BinarySearchFunc(times, from, time.Time.Compare)
+	return model.ClickStats{Total: len(times), Buckets: clickBuckets(times[i:], bucket)}
+}
+
+// top - ссылки с наибольшим кол-вом переходов за период [from, to)
+func (l *clickLog) top(from, to time.Time, n int) []model.LinkClicks {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	counts := make(map[string]int)
+	for code, times := range l.byCode {
+		i, _ := slices.BinarySearchFunc(times, from, time.Time.Compare)
+		j, _ := slices.BinarySearchFunc(times, to, time.Time.Compare)
+		if j > i {
+			counts[code] = j - i
+		}
+	}
+	return topLinks(counts, n)
+}
+
+// topLinks - не более n ссылок по убыванию кол-ва переходов, при равенстве - по коду
+func topLinks(counts map[string]int, n int) []model.LinkClicks {
+	links := make([]model.LinkClicks, 0, len(counts))
+	for code, clicks := range counts {
+		links = append(links, model.LinkClicks{Code: code, Clicks: clicks})
+	}
+	slices.SortFunc(links, func(a, b model.LinkClicks) int {
+		if a.Clicks != b.Clicks {
+			return b.Clicks - a.Clicks
+		}
+		return strings.Compare(a.Code, b.Code)
+	})
+	if len(links) > n {
+		links = links[:n]
+	}
+	return links
+}
+
+// clickBuckets - кол-во переходов по интервалам для упорядоченных моментов
+func clickBuckets(times []time.Time, bucket time.Duration) []model.TimeBucket {
+	var buckets []model.TimeBucket
+	for _, t := range times {
+		start := model.BucketStart(t, bucket)
+		if n := len(buckets); n > 0 && buckets[n-1].Start.Equal(start) {
+			buckets[n-1].Count++
+			continue
+		}
+		buckets = append(buckets, model.TimeBucket{Start: start, Count: 1})
+	}
+	return buckets
+}
+
+// ClickJSON - структура файла переходов StoreFile (файл хранилища с суффиксом clicksFileSuffix).
+// Запись с Purge = true - событие удаления переходов по окончательно удаленной ссылке
+type ClickJSON struct {
+	Code      string    `json:"code"`
+	Time      time.Time `json:"time"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IPHash    string    `json:"ip_hash,omitempty"`
+	Purge     bool      `json:"purge,omitempty"`
+}
+
+// clicksFileSuffix - суффикс имени файла переходов
+const clicksFileSuffix = ".clicks"
+
+// openClickFile открывает файл переходов и восстанавливает из него переходы.
+// Поврежденные записи (в т.ч. недописанная последняя) пропускаются
+func openClickFile(filename string) (*os.File, *clickLog, error) {
+	file, err := os.OpenFile(filename+clicksFileSuffix, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clicks := newClickLog()
+	var batch []model.Click
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var clickJSON ClickJSON
+		if err := json.Unmarshal(scanner.Bytes(), &clickJSON); err != nil || clickJSON.Code == "" {
+			continue
+		}
+		if clickJSON.Purge {
+			// Удаляются только переходы, записанные раньше события
+			clicks.add(batch)
+			batch = batch[:0]
+			clicks.remove([]model.Shortener{{Key: model.ShortenerKey{Code: clickJSON.Code}}})
+			continue
+		}
+		batch = append(batch, model.Click{Code: clickJSON.Code, Time: clickJSON.Time})
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	clicks.add(batch)
+	return file, clicks, nil
+}
+
+// writeClicks дописывает переходы в файл одной записью и сбрасывает файл на диск
+func writeClicks(file *os.File, clicks []model.Click) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, click := range clicks {
+		err := encoder.Encode(ClickJSON{
+			Code:      click.Code,
+			Time:      click.Time,
+			Referrer:  click.Referrer,
+			UserAgent: click.UserAgent,
+			IPHash:    click.IPHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// writeClicksPurge дописывает в файл события удаления переходов по ссылкам и сбрасывает файл на диск
+func writeClicksPurge(file *os.File, purged []model.Shortener) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, s := range purged {
+		if err := encoder.Encode(ClickJSON{Code: s.Key.Code, Purge: true}); err != nil {
+			return err
+		}
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+)
+
+// compactMinLines - минимальное кол-во записей в файле, начиная с которого имеет смысл сжатие
+const compactMinLines = 1000
+
+// replayFile восстанавливает индекс из файла хранилища.
+// Недописанная последняя строка (сбой во время записи) отрезается от файла с предупреждением в журнале
+func replayFile(file *os.File) (*memIndex, int, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	reader := bufio.NewReaderSize(file, 1<<20)
+	index := newMemIndex()
+	var lines int
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				// Строка без переноса - запись прервана
+				log.Printf("repository: torn tail in %s at offset %d (%d bytes), truncating",
+					file.Name(), offset, len(line))
+				if err := file.Truncate(offset); err != nil {
+					return nil, 0, err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += int64(len(line))
+		lines++
+
+		var fileJSON FileJSON
+		if err := json.Unmarshal(bytes.TrimSpace(line), &fileJSON); err != nil || fileJSON.Code == "" && fileJSON.IDBlock == 0 {
+			log.Printf("repository: skipping corrupted record in %s at offset %d", file.Name(), offset-int64(len(line)))
+			continue
+		}
+		applyFileJSON(index, fileJSON)
+	}
+
+	return index, lines, nil
+}
+
+// applyFileJSON применяет запись файла к индексу
+func applyFileJSON(index *memIndex, fileJSON FileJSON) {
+	if fileJSON.Code == "" {
+		index.restoreIDs(fileJSON.IDBlock)
+		return
+	}
+	key := model.ShortenerKey{Code: fileJSON.Code}
+	if fileJSON.Purge {
+		index.purge(key)
+		return
+	}
+	if fileJSON.Revision > 0 {
+		rev := model.Revision{Revision: fileJSON.Revision, URL: fileJSON.URL}
+		if fileJSON.CreatedAt != nil {
+			rev.CreatedAt = *fileJSON.CreatedAt
+		}
+		index.restoreRevision(key, rev)
+		return
+	}
+	var deletedAt time.Time
+	if fileJSON.DeletedAt != nil {
+		deletedAt = *fileJSON.DeletedAt
+	}
+	if fileJSON.DelFlag && fileJSON.URL == "" {
+		// Событие удаления: применяется только для ссылки того же владельца
+		index.markDeleted(key, fileJSON.User, deletedAt)
+		return
+	}
+	if fileJSON.Restore {
+		// Срок восстановления проверен до записи события
+		index.restore(key, fileJSON.User, time.Time{})
+		return
+	}
+	data := model.ShortenerData{URL: fileJSON.URL, User: fileJSON.User, DelFlag: fileJSON.DelFlag, DeletedAt: deletedAt, PasswordHash: fileJSON.PasswordHash,
+		MaxClicks: fileJSON.MaxClicks, ClicksLeft: fileJSON.ClicksLeft}
+	if fileJSON.ExpiresAt != nil {
+		data.ExpiresAt = *fileJSON.ExpiresAt
+	}
+	if fileJSON.CreatedAt != nil {
+		data.CreatedAt = *fileJSON.CreatedAt
+	}
+	index.replace(key, data)
+}
+
+// Compact переписывает файл хранилища в виде снимка текущего состояния
+func (store *StoreFile) Compact() error {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	return store.compact()
+}
+
+// needCompact - в файле накопилось больше записей, чем ссылок в хранилище
+func (store *StoreFile) needCompact() bool {
+	return store.lines > compactMinLines && store.lines > 2*store.index.len()
+}
+
+// compact записывает снимок во временный файл и подменяет им файл хранилища.
+// Вызывается под блокировкой
+func (store *StoreFile) compact() error {
+	if err := store.writer.Flush(); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(store.filename)
+	tmp, err := os.CreateTemp(dir, filepath.Base(store.filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// при успехе файл уже переименован и удаление вернет ошибку
+	defer os.Remove(tmp.Name())
+
+	// Снимок: одна запись на ссылку, удаленные - с признаком удаления, и граница выделенных номеров.
+	// История изменений пишется перед записью ссылки: при восстановлении она не меняет индекс URL
+	writer := bufio.NewWriterSize(tmp, 1<<20)
+	encoder := json.NewEncoder(writer)
+	// lines - кол-во записей снимка
+	lines := 0
+	err = store.index.each(func(key model.ShortenerKey, data model.ShortenerData, revisions []model.Revision) error {
+		for _, rev := range revisions {
+			if err := encoder.Encode(newRevisionJSON(key, data.User, rev)); err != nil {
+				return err
+			}
+			lines++
+		}
+		lines++
+		return encoder.Encode(newFileJSON(model.Shortener{Key: key, Data: data}))
+	})
+	if leased := store.index.leasedIDs(); err == nil && leased > 0 {
+		err = encoder.Encode(FileJSON{IDBlock: leased})
+		lines++
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// Подмена файла
+	if err := os.Rename(tmp.Name(), store.filename); err != nil {
+		return err
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+
+	// Дальнейшая запись - в новый файл
+	file, err := os.OpenFile(store.filename, os.O_RDWR|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	store.file.Close()
+	store.file = file
+	store.writer = bufio.NewWriter(file)
+	store.lines = lines
+
+	return nil
+}
+
+// compactLoop периодически сжимает файл, если в нем есть лишние записи
+func (store *StoreFile) compactLoop(interval time.Duration) {
+	defer store.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-store.done:
+			return
+		case <-ticker.C:
+			store.mux.Lock()
+			if store.needCompact() {
+				if err := store.compact(); err != nil {
+					log.Printf("repository: compaction of %s failed: %s", store.filename, err)
+				}
+			}
+			store.mux.Unlock()
+		}
+	}
+}
+
+// syncDir сбрасывает на диск каталог (фиксация переименования файла)
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
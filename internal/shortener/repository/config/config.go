@@ -1,15 +1,29 @@
 package config
 
+import "time"
+
 // Возможные типы хранилища
 const (
-	StoreTypeVar  string = ""
-	StoreTypeFile string = "1"
-	StoreTypeDB   string = "2"
+	StoreTypeVar    string = ""
+	StoreTypeFile   string = "1"
+	StoreTypeDB     string = "2"
+	StoreTypeKV     string = "3"
+	StoreTypeSQLite string = "4"
 )
 
+// SQLiteScheme - префикс DSN, выбирающий хранилище SQLite: sqlite://path/to/file.db
+const SQLiteScheme = "sqlite://"
+
 // Кофигурация store
 type Config struct {
 	StoreType string
 	Filename  string
 	DBDsn     string
+	KVPath    string
+	// CompactInterval - периодичность сжатия файла хранилища (0 - не сжимать по расписанию)
+	CompactInterval time.Duration
+	// CacheSize - кол-во ссылок в кэше чтения (0 - без кэша)
+	CacheSize int
+	// CacheTTL - время жизни ссылки в кэше (0 - до вытеснения)
+	CacheTTL time.Duration
 }
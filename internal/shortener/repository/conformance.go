@@ -0,0 +1,886 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+)
+
+// Conformance - общий набор тестов поведения хранилища.
+// Подходит для любой реализации Repository: newStore должен возвращать пустое хранилище,
+// закрытие хранилища выполняет сам набор тестов
+func Conformance(t *testing.T, newStore func(t *testing.T) Repository) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store Repository)
+	}{
+		{name: "create", fn: conformanceCreate},
+		{name: "not found", fn: conformanceNotFound},
+		{name: "duplicate", fn: conformanceDuplicate},
+		{name: "code conflict", fn: conformanceCodeConflict},
+		{name: "batch", fn: conformanceBatch},
+		{name: "batch duplicate", fn: conformanceBatchDuplicate},
+		{name: "user listing", fn: conformanceUserListing},
+		{name: "delete", fn: conformanceDelete},
+		{name: "stats", fn: conformanceStats},
+		{name: "expiry", fn: conformanceExpiry},
+		{name: "lease id block", fn: conformanceLeaseIDBlock},
+		{name: "clicks", fn: conformanceClicks},
+		{name: "stats report", fn: conformanceStatsReport},
+		{name: "update", fn: conformanceUpdate},
+		{name: "trash", fn: conformanceTrash},
+		{name: "purge clicks", fn: conformancePurgeClicks},
+		{name: "delete jobs", fn: conformanceDeleteJobs},
+		{name: "password", fn: conformancePassword},
+		{name: "max clicks", fn: conformanceMaxClicks},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newStore(t)
+			defer store.Close()
+			test.fn(t, store)
+		})
+	}
+}
+
+// newConformanceShortener - тестовая ссылка
+func newConformanceShortener(code, url, user string) model.Shortener {
+	return model.Shortener{
+		Key:  model.ShortenerKey{Code: code},
+		Data: model.ShortenerData{URL: url, User: user},
+	}
+}
+
+// newConformanceBatch - пакет с идентификаторами позиций "1", "2", ...
+func newConformanceBatch(s ...model.Shortener) []model.ShortenerBatchRow {
+	batch := make([]model.ShortenerBatchRow, len(s))
+	for i := range s {
+		batch[i] = model.ShortenerBatchRow{ID: strconv.Itoa(i + 1), Shortener: s[i]}
+	}
+	return batch
+}
+
+// mustSetShortener создает ссылку и завершает тест при ошибке
+func mustSetShortener(t *testing.T, store Repository, s model.Shortener) {
+	t.Helper()
+	if _, err := store.SetShortener(context.Background(), s); err != nil {
+		t.Fatalf("SetShortener(%s) error = %v", s.Key.Code, err)
+	}
+}
+
+// userCodes - отсортированные коды ссылок пользователя
+func userCodes(t *testing.T, store Repository, userCode string) []string {
+	t.Helper()
+	batch, err := store.GetShortenerBatch(context.Background(), userCode)
+	if err != nil {
+		t.Fatalf("GetShortenerBatch(%s) error = %v", userCode, err)
+	}
+	codes := make([]string, 0, len(batch))
+	for _, s := range batch {
+		codes = append(codes, s.Key.Code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// equalCodes сравнивает наборы кодов
+func equalCodes(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func conformanceCreate(t *testing.T, store Repository) {
+	s := newConformanceShortener("code01", "https://example.com/1", "user1")
+	resp, err := store.SetShortener(context.Background(), s)
+	if err != nil {
+		t.Fatalf("SetShortener error = %v", err)
+	}
+	if resp.Key.Code != s.Key.Code {
+		t.Errorf("SetShortener code = %s, want %s", resp.Key.Code, s.Key.Code)
+	}
+
+	got, err := store.GetShortener(s.Key.Code)
+	if err != nil {
+		t.Fatalf("GetShortener error = %v", err)
+	}
+	if got.Key.Code != s.Key.Code || got.Data.URL != s.Data.URL || got.Data.User != s.Data.User {
+		t.Errorf("GetShortener = %+v, want %+v", got, s)
+	}
+}
+
+func conformanceNotFound(t *testing.T, store Repository) {
+	_, err := store.GetShortener("nope00")
+	if !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("GetShortener error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+}
+
+func conformanceDuplicate(t *testing.T, store Repository) {
+	mustSetShortener(t, store, newConformanceShortener("code01", "https://example.com/1", "user1"))
+
+	// тот же URL с другим кодом и от другого пользователя
+	resp, err := store.SetShortener(context.Background(),
+		newConformanceShortener("code02", "https://example.com/1", "user2"))
+	if !errors.Is(err, ErrSetShortenerAlreadyExists) {
+		t.Fatalf("SetShortener error = %v, want %v", err, ErrSetShortenerAlreadyExists)
+	}
+	if resp.Key.Code != "code01" {
+		t.Errorf("SetShortener existing code = %s, want code01", resp.Key.Code)
+	}
+	if _, err := store.GetShortener("code02"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("GetShortener(code02) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+}
+
+func conformanceCodeConflict(t *testing.T, store Repository) {
+	mustSetShortener(t, store, newConformanceShortener("code01", "https://example.com/1", "user1"))
+
+	// занятый код не перезаписывается
+	_, err := store.SetShortener(context.Background(),
+		newConformanceShortener("code01", "https://example.com/2", "user1"))
+	if !errors.Is(err, ErrSetShortenerCodeConflict) {
+		t.Fatalf("SetShortener error = %v, want %v", err, ErrSetShortenerCodeConflict)
+	}
+	got, err := store.GetShortener("code01")
+	if err != nil {
+		t.Fatalf("GetShortener error = %v", err)
+	}
+	if got.Data.URL != "https://example.com/1" {
+		t.Errorf("GetShortener URL = %s, want https://example.com/1", got.Data.URL)
+	}
+}
+
+func conformanceBatch(t *testing.T, store Repository) {
+	batch := newConformanceBatch(
+		newConformanceShortener("code01", "https://example.com/1", "user1"),
+		newConformanceShortener("code02", "https://example.com/2", "user1"),
+		newConformanceShortener("code03", "https://example.com/3", "user1"),
+	)
+	resp, err := store.SetShortenerBatch(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("SetShortenerBatch error = %v", err)
+	}
+	if len(resp) != len(batch) {
+		t.Fatalf("SetShortenerBatch len = %d, want %d", len(resp), len(batch))
+	}
+	for i := range batch {
+		code := batch[i].Shortener.Key.Code
+		if resp[i].ID != batch[i].ID || resp[i].Status != model.ShortenerStatusCreated || resp[i].Shortener.Key.Code != code {
+			t.Errorf("SetShortenerBatch[%d] = %+v, want created %s", i, resp[i], code)
+		}
+		if _, err := store.GetShortener(code); err != nil {
+			t.Errorf("GetShortener(%s) error = %v", code, err)
+		}
+	}
+}
+
+func conformanceBatchDuplicate(t *testing.T, store Repository) {
+	mustSetShortener(t, store, newConformanceShortener("code01", "https://example.com/1", "user1"))
+
+	// результат по каждой позиции в порядке запроса: новые создаются, для существующих возвращается код
+	resp, err := store.SetShortenerBatch(context.Background(), newConformanceBatch(
+		newConformanceShortener("code02", "https://example.com/2", "user1"),
+		newConformanceShortener("code03", "https://example.com/1", "user1"),
+		newConformanceShortener("code01", "https://example.com/4", "user1"),
+		newConformanceShortener("code05", "https://example.com/2", "user1"),
+	))
+	if err != nil {
+		t.Fatalf("SetShortenerBatch error = %v", err)
+	}
+	want := []struct {
+		code   string
+		status model.ShortenerStatus
+	}{
+		{code: "code02", status: model.ShortenerStatusCreated},
+		{code: "code01", status: model.ShortenerStatusExists},
+		{code: "code01", status: model.ShortenerStatusCodeConflict},
+		{code: "code02", status: model.ShortenerStatusExists},
+	}
+	if len(resp) != len(want) {
+		t.Fatalf("SetShortenerBatch len = %d, want %d", len(resp), len(want))
+	}
+	for i := range want {
+		if resp[i].ID != strconv.Itoa(i+1) {
+			t.Errorf("SetShortenerBatch[%d] ID = %s, want %d", i, resp[i].ID, i+1)
+		}
+		if resp[i].Shortener.Key.Code != want[i].code || resp[i].Status != want[i].status {
+			t.Errorf("SetShortenerBatch[%d] = {%s %d}, want {%s %d}",
+				i, resp[i].Shortener.Key.Code, resp[i].Status, want[i].code, want[i].status)
+		}
+	}
+	if _, err := store.GetShortener("code03"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("GetShortener(code03) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+	if _, err := store.GetShortener("code05"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("GetShortener(code05) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+}
+
+func conformanceUserListing(t *testing.T, store Repository) {
+	mustSetShortener(t, store, newConformanceShortener("code01", "https://example.com/1", "user1"))
+	mustSetShortener(t, store, newConformanceShortener("code02", "https://example.com/2", "user1"))
+	mustSetShortener(t, store, newConformanceShortener("code03", "https://example.com/3", "user2"))
+
+	if got := userCodes(t, store, "user1"); !equalCodes(got, []string{"code01", "code02"}) {
+		t.Errorf("GetShortenerBatch(user1) = %v", got)
+	}
+	if got := userCodes(t, store, "user2"); !equalCodes(got, []string{"code03"}) {
+		t.Errorf("GetShortenerBatch(user2) = %v", got)
+	}
+	if got := userCodes(t, store, "user3"); len(got) != 0 {
+		t.Errorf("GetShortenerBatch(user3) = %v, want empty", got)
+	}
+	// пустой пользователь не означает "все ссылки"
+	if got := userCodes(t, store, ""); len(got) != 0 {
+		t.Errorf("GetShortenerBatch(\"\") = %v, want empty", got)
+	}
+}
+
+func conformanceDelete(t *testing.T, store Repository) {
+	ctx := context.Background()
+	mustSetShortener(t, store, newConformanceShortener("code01", "https://example.com/1", "user1"))
+	mustSetShortener(t, store, newConformanceShortener("code02", "https://example.com/2", "user1"))
+
+	// удаляет только владелец, чужие и неизвестные коды не найдены
+	statuses, err := store.DeleteShortenerBatch(ctx, []model.Shortener{
+		{Key: model.ShortenerKey{Code: "code01"}, Data: model.ShortenerData{User: "user1"}},
+		{Key: model.ShortenerKey{Code: "code02"}, Data: model.ShortenerData{User: "user2"}},
+		{Key: model.ShortenerKey{Code: "nope00"}, Data: model.ShortenerData{User: "user1"}},
+	})
+	if err != nil {
+		t.Fatalf("DeleteShortenerBatch error = %v", err)
+	}
+	want := []model.DeleteStatus{model.DeleteStatusDeleted, model.DeleteStatusNotFound, model.DeleteStatusNotFound}
+	if !slices.Equal(statuses, want) {
+		t.Errorf("DeleteShortenerBatch = %v, want %v", statuses, want)
+	}
+
+	// повторное удаление
+	statuses, err = store.DeleteShortenerBatch(ctx, []model.Shortener{newConformanceShortener("code01", "", "user1")})
+	if err != nil {
+		t.Fatalf("DeleteShortenerBatch error = %v", err)
+	}
+	if !slices.Equal(statuses, []model.DeleteStatus{model.DeleteStatusAlreadyDeleted}) {
+		t.Errorf("DeleteShortenerBatch(deleted) = %v, want [%s]", statuses, model.DeleteStatusAlreadyDeleted)
+	}
+
+	if _, err := store.GetShortener("code01"); !errors.Is(err, ErrGetShortenerGone) {
+		t.Errorf("GetShortener(code01) error = %v, want %v", err, ErrGetShortenerGone)
+	}
+	if _, err := store.GetShortener("code02"); err != nil {
+		t.Errorf("GetShortener(code02) error = %v", err)
+	}
+	if got := userCodes(t, store, "user1"); !equalCodes(got, []string{"code02"}) {
+		t.Errorf("GetShortenerBatch(user1) = %v, want [code02]", got)
+	}
+
+	// удаленный URL по-прежнему занят
+	_, err = store.SetShortener(ctx, newConformanceShortener("code03", "https://example.com/1", "user1"))
+	if !errors.Is(err, ErrSetShortenerAlreadyExists) {
+		t.Errorf("SetShortener(deleted url) error = %v, want %v", err, ErrSetShortenerAlreadyExists)
+	}
+}
+
+func conformanceStats(t *testing.T, store Repository) {
+	ctx := context.Background()
+	stats, err := store.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats error = %v", err)
+	}
+	if stats.URLs != 0 || stats.Users != 0 {
+		t.Errorf("GetStats on empty store = %+v", stats)
+	}
+
+	mustSetShortener(t, store, newConformanceShortener("code01", "https://example.com/1", "user1"))
+	mustSetShortener(t, store, newConformanceShortener("code02", "https://example.com/2", "user1"))
+	mustSetShortener(t, store, newConformanceShortener("code03", "https://example.com/3", "user2"))
+	_, err = store.DeleteShortenerBatch(ctx, []model.Shortener{
+		{Key: model.ShortenerKey{Code: "code03"}, Data: model.ShortenerData{User: "user2"}},
+	})
+	if err != nil {
+		t.Fatalf("DeleteShortenerBatch error = %v", err)
+	}
+
+	// удаленные ссылки не учитываются
+	stats, err = store.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats error = %v", err)
+	}
+	if stats.URLs != 2 || stats.Users != 1 {
+		t.Errorf("GetStats = %+v, want {URLs:2 Users:1}", stats)
+	}
+}
+
+// conformanceExpiry - истекшая ссылка недоступна и окончательно удаляется DeleteExpired
+func conformanceExpiry(t *testing.T, store Repository) {
+	ctx := context.Background()
+	now := time.Now()
+
+	expired := newConformanceShortener("code01", "https://example.com/1", "user1")
+	expired.Data.ExpiresAt = now.Add(-time.Minute)
+	live := newConformanceShortener("code02", "https://example.com/2", "user1")
+	live.Data.ExpiresAt = now.Add(time.Hour)
+	mustSetShortener(t, store, expired)
+	mustSetShortener(t, store, live)
+	mustSetShortener(t, store, newConformanceShortener("code03", "https://example.com/3", "user1"))
+
+	if _, err := store.GetShortener("code01"); !errors.Is(err, ErrGetShortenerGone) {
+		t.Errorf("GetShortener(expired) error = %v, want ErrGetShortenerGone", err)
+	}
+	got, err := store.GetShortener("code02")
+	if err != nil {
+		t.Fatalf("GetShortener(live) error = %v", err)
+	}
+	if !got.Data.ExpiresAt.Equal(live.Data.ExpiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", got.Data.ExpiresAt, live.Data.ExpiresAt)
+	}
+
+	// истекшие ссылки не попадают в список пользователя
+	if codes := userCodes(t, store, "user1"); !equalCodes(codes, []string{"code02", "code03"}) {
+		t.Errorf("GetShortenerBatch codes = %v, want [code02 code03]", codes)
+	}
+
+	n, err := store.DeleteExpired(ctx, now)
+	if err != nil {
+		t.Fatalf("DeleteExpired error = %v", err)
+	}
+	if n != 1 {
+		t.Errorf("DeleteExpired = %d, want 1", n)
+	}
+	if _, err := store.GetShortener("code01"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("GetShortener(purged) error = %v, want ErrGetShortenerNotFound", err)
+	}
+	stats, err := store.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats error = %v", err)
+	}
+	if stats.URLs != 2 || stats.Users != 1 {
+		t.Errorf("GetStats = %+v, want {URLs:2 Users:1}", stats)
+	}
+
+	// URL окончательно удаленной ссылки можно сократить снова
+	if _, err := store.SetShortener(ctx, newConformanceShortener("code04", "https://example.com/1", "user1")); err != nil {
+		t.Errorf("SetShortener(purged url) error = %v", err)
+	}
+}
+
+// conformanceLeaseIDBlock - блоки номеров начинаются с 1 и не пересекаются
+func conformanceLeaseIDBlock(t *testing.T, store Repository) {
+	ctx := context.Background()
+
+	var want uint64 = 1
+	for _, size := range []uint64{10, 1, 100} {
+		start, err := store.LeaseIDBlock(ctx, size)
+		if err != nil {
+			t.Fatalf("LeaseIDBlock(%d) error = %v", size, err)
+		}
+		if start != want {
+			t.Errorf("LeaseIDBlock(%d) = %d, want %d", size, start, want)
+		}
+		want = start + size
+	}
+}
+
+// conformanceClicks - переходы считаются по ссылке и группируются по интервалам
+func conformanceClicks(t *testing.T, store Repository) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	clicks := []model.Click{
+		{Code: "code01", Time: base.Add(-2 * time.Hour), Referrer: "https://ya.ru/", UserAgent: "test", IPHash: "hash"},
+		{Code: "code01", Time: base.Add(5 * time.Minute)},
+		{Code: "code01", Time: base.Add(5 * time.Minute)},
+		{Code: "code02", Time: base.Add(10 * time.Minute)},
+	}
+	if err := store.SaveClicks(ctx, clicks[:2]); err != nil {
+		t.Fatalf("SaveClicks error = %v", err)
+	}
+	if err := store.SaveClicks(ctx, append(clicks[2:], model.Click{Code: "code01", Time: base.Add(90 * time.Minute)})); err != nil {
+		t.Fatalf("SaveClicks error = %v", err)
+	}
+
+	stats, err := store.GetClickStats(ctx, "code01", base, time.Hour)
+	if err != nil {
+		t.Fatalf("GetClickStats error = %v", err)
+	}
+	want := []model.TimeBucket{
+		{Start: base, Count: 2},
+		{Start: base.Add(time.Hour), Count: 1},
+	}
+	if stats.Total != 4 || len(stats.Buckets) != len(want) {
+		t.Fatalf("GetClickStats = %+v, want total 4 and buckets %+v", stats, want)
+	}
+	for i := range want {
+		if !stats.Buckets[i].Start.Equal(want[i].Start) || stats.Buckets[i].Count != want[i].Count {
+			t.Errorf("bucket %d = %+v, want %+v", i, stats.Buckets[i], want[i])
+		}
+	}
+
+	stats, err = store.GetClickStats(ctx, "code03", base, time.Hour)
+	if err != nil {
+		t.Fatalf("GetClickStats error = %v", err)
+	}
+	if stats.Total != 0 || len(stats.Buckets) != 0 {
+		t.Errorf("GetClickStats(no clicks) = %+v, want empty", stats)
+	}
+}
+
+// conformanceStatsReport - статистика за период: создание по суткам, активные пользователи,
+// удаленные ссылки и ссылки с наибольшим кол-вом переходов
+func conformanceStatsReport(t *testing.T, store Repository) {
+	ctx := context.Background()
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	for _, s := range []struct {
+		code, user string
+		createdAt  time.Time
+	}{
+		{code: "code00", user: "user03", createdAt: day.Add(-time.Hour)},
+		{code: "code01", user: "user01", createdAt: day.Add(time.Hour)},
+		{code: "code02", user: "user02", createdAt: day.Add(23 * time.Hour)},
+		{code: "code03", user: "user01", createdAt: day.Add(model.StatsDay + time.Hour)},
+	} {
+		shortener := newConformanceShortener(s.code, "https://"+s.code+".ru/", s.user)
+		shortener.Data.CreatedAt = s.createdAt
+		mustSetShortener(t, store, shortener)
+	}
+	if _, err := store.DeleteShortenerBatch(ctx, []model.Shortener{newConformanceShortener("code02", "", "user02")}); err != nil {
+		t.Fatalf("DeleteShortenerBatch error = %v", err)
+	}
+	err := store.SaveClicks(ctx, []model.Click{
+		{Code: "code00", Time: day.Add(-time.Minute)},
+		{Code: "code00", Time: day.Add(-time.Minute)},
+		{Code: "code00", Time: day.Add(-time.Minute)},
+		{Code: "code01", Time: day.Add(time.Hour)},
+		{Code: "code03", Time: day.Add(2 * time.Hour)},
+		{Code: "code03", Time: day.Add(model.StatsDay + 2*time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("SaveClicks error = %v", err)
+	}
+
+	report, err := store.GetStatsReport(ctx, day, day.Add(2*model.StatsDay), 2)
+	if err != nil {
+		t.Fatalf("GetStatsReport error = %v", err)
+	}
+	if report.URLs != 3 || report.Users != 2 || report.DeletedURLs != 0 || report.ActiveUsers != 2 {
+		t.Errorf("GetStatsReport = %+v, want 3 urls, 2 users, 0 deleted, 2 active users", report)
+	}
+	wantCreated := []model.TimeBucket{
+		{Start: day, Count: 2},
+		{Start: day.Add(model.StatsDay), Count: 1},
+	}
+	if len(report.Created) != len(wantCreated) {
+		t.Fatalf("Created = %+v, want %+v", report.Created, wantCreated)
+	}
+	for i := range wantCreated {
+		if !report.Created[i].Start.Equal(wantCreated[i].Start) || report.Created[i].Count != wantCreated[i].Count {
+			t.Errorf("Created[%d] = %+v, want %+v", i, report.Created[i], wantCreated[i])
+		}
+	}
+	wantTop := []model.LinkClicks{{Code: "code03", Clicks: 2}, {Code: "code01", Clicks: 1}}
+	if len(report.TopLinks) != len(wantTop) {
+		t.Fatalf("TopLinks = %+v, want %+v", report.TopLinks, wantTop)
+	}
+	for i := range wantTop {
+		if report.TopLinks[i] != wantTop[i] {
+			t.Errorf("TopLinks[%d] = %+v, want %+v", i, report.TopLinks[i], wantTop[i])
+		}
+	}
+
+	// Удаленные ссылки учитываются в периоде удаления
+	today := model.BucketStart(time.Now(), model.StatsDay)
+	report, err = store.GetStatsReport(ctx, today.Add(-model.StatsDay), today.Add(2*model.StatsDay), 2)
+	if err != nil {
+		t.Fatalf("GetStatsReport error = %v", err)
+	}
+	if report.DeletedURLs != 1 {
+		t.Errorf("GetStatsReport(today).DeletedURLs = %d, want 1", report.DeletedURLs)
+	}
+}
+
+// conformanceUpdate - изменение адреса ссылки владельцем и история изменений
+func conformanceUpdate(t *testing.T, store Repository) {
+	ctx := context.Background()
+	mustSetShortener(t, store, newConformanceShortener("code01", "https://a.ru/", "user01"))
+	mustSetShortener(t, store, newConformanceShortener("code02", "https://b.ru/", "user01"))
+	mustSetShortener(t, store, newConformanceShortener("code03", "https://c.ru/", "user02"))
+
+	update := func(code, url, user string) (model.Shortener, error) {
+		return store.UpdateShortener(ctx, newConformanceShortener(code, url, user))
+	}
+	for _, url := range []string{"https://d.ru/", "https://d.ru/", "https://a.ru/"} {
+		s, err := update("code01", url, "user01")
+		if err != nil || s.Data.URL != url {
+			t.Fatalf("UpdateShortener(code01, %s) = %+v, %v", url, s, err)
+		}
+	}
+	if s, err := store.GetShortener("code01"); err != nil || s.Data.URL != "https://a.ru/" {
+		t.Errorf("GetShortener(code01) = %+v, %v, want https://a.ru/", s, err)
+	}
+
+	// URL другой ссылки
+	s, err := update("code01", "https://b.ru/", "user01")
+	if !errors.Is(err, ErrSetShortenerAlreadyExists) || s.Key.Code != "code02" {
+		t.Errorf("UpdateShortener(url of code02) = %+v, %v, want code02 and %v", s, err, ErrSetShortenerAlreadyExists)
+	}
+	// Чужая ссылка
+	if _, err := update("code03", "https://e.ru/", "user01"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("UpdateShortener(foreign) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+	// Прежний адрес освобождается для сокращения
+	if _, err := store.SetShortener(ctx, newConformanceShortener("code04", "https://d.ru/", "user02")); err != nil {
+		t.Errorf("SetShortener(released url) error = %v", err)
+	}
+
+	revs, err := store.GetRevisions(ctx, "code01")
+	if err != nil {
+		t.Fatalf("GetRevisions error = %v", err)
+	}
+	wantURLs := []string{"https://a.ru/", "https://d.ru/", "https://a.ru/"}
+	if len(revs) != len(wantURLs) {
+		t.Fatalf("GetRevisions(code01) = %+v, want %v", revs, wantURLs)
+	}
+	for i, rev := range revs {
+		if rev.Revision != i+1 || rev.URL != wantURLs[i] {
+			t.Errorf("revision %d = %+v, want %s", i+1, rev, wantURLs[i])
+		}
+	}
+	if revs, err := store.GetRevisions(ctx, "code02"); err != nil || len(revs) != 1 || revs[0].URL != "https://b.ru/" {
+		t.Errorf("GetRevisions(unchanged) = %+v, %v, want original url", revs, err)
+	}
+	if _, err := store.GetRevisions(ctx, "code09"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("GetRevisions(unknown) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+
+	// Удаленная ссылка не изменяется
+	if _, err := store.DeleteShortenerBatch(ctx, []model.Shortener{newConformanceShortener("code02", "", "user01")}); err != nil {
+		t.Fatalf("DeleteShortenerBatch error = %v", err)
+	}
+	if _, err := update("code02", "https://e.ru/", "user01"); !errors.Is(err, ErrGetShortenerGone) {
+		t.Errorf("UpdateShortener(deleted) error = %v, want %v", err, ErrGetShortenerGone)
+	}
+}
+
+// conformanceTrash - список удаленных ссылок, восстановление в течение срока и окончательное удаление
+func conformanceTrash(t *testing.T, store Repository) {
+	ctx := context.Background()
+	mustSetShortener(t, store, newConformanceShortener("code01", "https://a.ru/", "user01"))
+	mustSetShortener(t, store, newConformanceShortener("code02", "https://b.ru/", "user01"))
+	mustSetShortener(t, store, newConformanceShortener("code03", "https://c.ru/", "user01"))
+	if _, err := store.UpdateShortener(ctx, newConformanceShortener("code03", "https://d.ru/", "user01")); err != nil {
+		t.Fatalf("UpdateShortener error = %v", err)
+	}
+	before := time.Now()
+	_, err := store.DeleteShortenerBatch(ctx, []model.Shortener{
+		newConformanceShortener("code01", "", "user01"),
+		newConformanceShortener("code03", "", "user01"),
+	})
+	if err != nil {
+		t.Fatalf("DeleteShortenerBatch error = %v", err)
+	}
+
+	deleted, err := store.GetDeletedBatch(ctx, "user01")
+	if err != nil {
+		t.Fatalf("GetDeletedBatch error = %v", err)
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].Key.Code < deleted[j].Key.Code })
+	if len(deleted) != 2 || deleted[0].Key.Code != "code01" || deleted[1].Key.Code != "code03" {
+		t.Fatalf("GetDeletedBatch(user01) = %+v, want code01 and code03", deleted)
+	}
+	if deleted[0].Data.URL != "https://a.ru/" || deleted[0].Data.DeletedAt.Before(before.Add(-time.Second)) {
+		t.Errorf("GetDeletedBatch[0] = %+v, want url and deletion time", deleted[0])
+	}
+	if deleted, err := store.GetDeletedBatch(ctx, "user02"); err != nil || len(deleted) != 0 {
+		t.Errorf("GetDeletedBatch(user02) = %+v, %v, want empty", deleted, err)
+	}
+
+	restore := func(code, user string, deletedAfter time.Time) (model.Shortener, error) {
+		return store.RestoreShortener(ctx, newConformanceShortener(code, "", user), deletedAfter)
+	}
+	// Срок восстановления истек
+	if _, err := restore("code01", "user01", time.Now().Add(time.Hour)); !errors.Is(err, ErrRestoreGracePassed) {
+		t.Errorf("RestoreShortener(grace passed) error = %v, want %v", err, ErrRestoreGracePassed)
+	}
+	// Чужая, неудаленная и несуществующая ссылки
+	if _, err := restore("code01", "user02", time.Time{}); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("RestoreShortener(foreign) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+	if _, err := restore("code02", "user01", time.Time{}); !errors.Is(err, ErrShortenerNotDeleted) {
+		t.Errorf("RestoreShortener(not deleted) error = %v, want %v", err, ErrShortenerNotDeleted)
+	}
+	if _, err := restore("code09", "user01", time.Time{}); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("RestoreShortener(unknown) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+
+	s, err := restore("code01", "user01", before.Add(-time.Minute))
+	if err != nil || s.Data.URL != "https://a.ru/" || s.Data.DelFlag {
+		t.Fatalf("RestoreShortener(code01) = %+v, %v", s, err)
+	}
+	if _, err := store.GetShortener("code01"); err != nil {
+		t.Errorf("GetShortener(restored) error = %v", err)
+	}
+	if got := userCodes(t, store, "user01"); !equalCodes(got, []string{"code01", "code02"}) {
+		t.Errorf("GetShortenerBatch(user01) = %v, want [code01 code02]", got)
+	}
+	if stats, err := store.GetStats(ctx); err != nil || stats.URLs != 2 || stats.Users != 1 {
+		t.Errorf("GetStats = %+v, %v, want {URLs:2 Users:1}", stats, err)
+	}
+
+	// Ссылки, удаленные позже before, не удаляются окончательно
+	if n, err := store.PurgeDeleted(ctx, before.Add(-time.Minute)); err != nil || n != 0 {
+		t.Errorf("PurgeDeleted(before deletion) = %d, %v, want 0", n, err)
+	}
+	n, err := store.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	if err != nil || n != 1 {
+		t.Fatalf("PurgeDeleted = %d, %v, want 1", n, err)
+	}
+	if _, err := store.GetShortener("code03"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("GetShortener(purged) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+	if _, err := store.GetRevisions(ctx, "code03"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("GetRevisions(purged) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+	if deleted, err := store.GetDeletedBatch(ctx, "user01"); err != nil || len(deleted) != 0 {
+		t.Errorf("GetDeletedBatch(after purge) = %+v, %v, want empty", deleted, err)
+	}
+	// URL окончательно удаленной ссылки можно сократить снова
+	if _, err := store.SetShortener(ctx, newConformanceShortener("code04", "https://d.ru/", "user02")); err != nil {
+		t.Errorf("SetShortener(purged url) error = %v", err)
+	}
+}
+
+// conformancePurgeClicks - окончательное удаление ссылки (из корзины и по сроку действия) удаляет ее переходы:
+// ссылка с тем же кодом, созданная заново, начинает без переходов
+func conformancePurgeClicks(t *testing.T, store Repository) {
+	ctx := context.Background()
+	now := time.Now()
+
+	expired := newConformanceShortener("code01", "https://a.ru/", "user01")
+	expired.Data.ExpiresAt = now.Add(-time.Minute)
+	mustSetShortener(t, store, expired)
+	mustSetShortener(t, store, newConformanceShortener("code02", "https://b.ru/", "user01"))
+	mustSetShortener(t, store, newConformanceShortener("code03", "https://c.ru/", "user01"))
+	err := store.SaveClicks(ctx, []model.Click{
+		{Code: "code01", Time: now.Add(-time.Hour)},
+		{Code: "code02", Time: now.Add(-time.Hour)},
+		{Code: "code02", Time: now.Add(-time.Hour)},
+		{Code: "code03", Time: now.Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("SaveClicks error = %v", err)
+	}
+	if _, err := store.DeleteShortenerBatch(ctx, []model.Shortener{newConformanceShortener("code02", "", "user01")}); err != nil {
+		t.Fatalf("DeleteShortenerBatch error = %v", err)
+	}
+	if n, err := store.PurgeDeleted(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("PurgeDeleted = %d, %v, want 1", n, err)
+	}
+	if n, err := store.DeleteExpired(ctx, now); err != nil || n != 1 {
+		t.Fatalf("DeleteExpired = %d, %v, want 1", n, err)
+	}
+
+	mustSetShortener(t, store, newConformanceShortener("code01", "https://d.ru/", "user02"))
+	mustSetShortener(t, store, newConformanceShortener("code02", "https://e.ru/", "user02"))
+	from := now.Add(-model.StatsDay)
+	for _, code := range []string{"code01", "code02"} {
+		stats, err := store.GetClickStats(ctx, code, from, time.Hour)
+		if err != nil || stats.Total != 0 || len(stats.Buckets) != 0 {
+			t.Errorf("GetClickStats(%s) = %+v, %v, want empty", code, stats, err)
+		}
+	}
+	if stats, err := store.GetClickStats(ctx, "code03", from, time.Hour); err != nil || stats.Total != 1 {
+		t.Errorf("GetClickStats(code03) = %+v, %v, want 1 click", stats, err)
+	}
+
+	// Переходы удаленных ссылок не учитываются в статистике за период
+	day := model.BucketStart(now, model.StatsDay)
+	report, err := store.GetStatsReport(ctx, day.Add(-model.StatsDay), day.Add(model.StatsDay), 10)
+	if err != nil {
+		t.Fatalf("GetStatsReport error = %v", err)
+	}
+	if len(report.TopLinks) != 1 || report.TopLinks[0] != (model.LinkClicks{Code: "code03", Clicks: 1}) {
+		t.Errorf("TopLinks = %+v, want [code03: 1]", report.TopLinks)
+	}
+}
+
+func conformanceDeleteJobs(t *testing.T, store Repository) {
+	ctx := context.Background()
+	if _, err := store.GetDeleteJob(ctx, "job01"); !errors.Is(err, ErrDeleteJobNotFound) {
+		t.Errorf("GetDeleteJob(unknown) error = %v, want %v", err, ErrDeleteJobNotFound)
+	}
+
+	created := time.Unix(1700000000, 0)
+	job1 := model.DeleteJob{
+		ID:          "job01",
+		User:        "user01",
+		Status:      model.DeleteJobPending,
+		Codes:       []model.DeleteJobCode{{Code: "code01"}, {Code: "code02"}},
+		NextAttempt: created,
+		CreatedAt:   created,
+		UpdatedAt:   created,
+	}
+	job2 := job1
+	job2.ID, job2.Codes, job2.CreatedAt = "job02", []model.DeleteJobCode{{Code: "code03"}}, created.Add(time.Second)
+	for _, job := range []model.DeleteJob{job2, job1} {
+		if err := store.SaveDeleteJob(ctx, job); err != nil {
+			t.Fatalf("SaveDeleteJob error = %v", err)
+		}
+	}
+
+	// невыполненные задания в порядке создания
+	pending, err := store.GetPendingDeleteJobs(ctx)
+	if err != nil {
+		t.Fatalf("GetPendingDeleteJobs error = %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != "job01" || pending[1].ID != "job02" {
+		t.Fatalf("GetPendingDeleteJobs = %+v, want job01 and job02", pending)
+	}
+	if pending[0].User != "user01" || len(pending[0].Codes) != 2 || !pending[0].CreatedAt.Equal(created) {
+		t.Errorf("GetPendingDeleteJobs[0] = %+v, want %+v", pending[0], job1)
+	}
+
+	// замена задания результатом выполнения
+	job1.Status, job1.Attempts, job1.Error = model.DeleteJobDone, 1, "timeout"
+	job1.Codes = []model.DeleteJobCode{
+		{Code: "code01", Status: model.DeleteStatusDeleted},
+		{Code: "code02", Status: model.DeleteStatusNotFound},
+	}
+	job1.UpdatedAt = created.Add(time.Minute)
+	if err := store.SaveDeleteJob(ctx, job1); err != nil {
+		t.Fatalf("SaveDeleteJob error = %v", err)
+	}
+	got, err := store.GetDeleteJob(ctx, "job01")
+	if err != nil {
+		t.Fatalf("GetDeleteJob error = %v", err)
+	}
+	if got.Status != model.DeleteJobDone || got.Attempts != 1 || got.Error != "timeout" ||
+		!slices.Equal(got.Codes, job1.Codes) || !got.UpdatedAt.Equal(job1.UpdatedAt) {
+		t.Errorf("GetDeleteJob = %+v, want %+v", got, job1)
+	}
+	pending, err = store.GetPendingDeleteJobs(ctx)
+	if err != nil {
+		t.Fatalf("GetPendingDeleteJobs error = %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != "job02" {
+		t.Errorf("GetPendingDeleteJobs = %+v, want job02", pending)
+	}
+}
+
+func conformancePassword(t *testing.T, store Repository) {
+	ctx := context.Background()
+	protected := newConformanceShortener("code01", "https://example.com/1", "user1")
+	protected.Data.PasswordHash = "hash01"
+	mustSetShortener(t, store, protected)
+	batchRow := newConformanceShortener("code02", "https://example.com/2", "user1")
+	batchRow.Data.PasswordHash = "hash02"
+	if _, err := store.SetShortenerBatch(ctx, newConformanceBatch(batchRow)); err != nil {
+		t.Fatalf("SetShortenerBatch error = %v", err)
+	}
+	mustSetShortener(t, store, newConformanceShortener("code03", "https://example.com/3", "user1"))
+
+	// хэш пароля хранится вместе со ссылкой
+	for code, want := range map[string]string{"code01": "hash01", "code02": "hash02", "code03": ""} {
+		got, err := store.GetShortener(code)
+		if err != nil {
+			t.Fatalf("GetShortener(%s) error = %v", code, err)
+		}
+		if got.Data.PasswordHash != want {
+			t.Errorf("GetShortener(%s) password hash = %q, want %q", code, got.Data.PasswordHash, want)
+		}
+	}
+}
+
+// conformanceMaxClicks - ссылка с ограничением кол-ва переходов: остаток расходуется атомарно,
+// исчерпанная ссылка - ErrGetShortenerExhausted
+func conformanceMaxClicks(t *testing.T, store Repository) {
+	ctx := context.Background()
+	limited := newConformanceShortener("code01", "https://example.com/1", "user1")
+	limited.Data.MaxClicks, limited.Data.ClicksLeft = 5, 5
+	mustSetShortener(t, store, limited)
+	batchRow := newConformanceShortener("code02", "https://example.com/2", "user1")
+	batchRow.Data.MaxClicks, batchRow.Data.ClicksLeft = 1, 1
+	if _, err := store.SetShortenerBatch(ctx, newConformanceBatch(batchRow)); err != nil {
+		t.Fatalf("SetShortenerBatch error = %v", err)
+	}
+	mustSetShortener(t, store, newConformanceShortener("code03", "https://example.com/3", "user1"))
+
+	got, err := store.GetShortener("code01")
+	if err != nil || got.Data.MaxClicks != 5 || got.Data.ClicksLeft != 5 {
+		t.Fatalf("GetShortener(code01) = %+v, %v, want 5 of 5 clicks left", got.Data, err)
+	}
+
+	// Параллельные переходы расходуют не больше остатка
+	const workers = 20
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	var used, exhausted int
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.UseShortener(ctx, "code01")
+			mux.Lock()
+			defer mux.Unlock()
+			switch {
+			case err == nil:
+				used++
+			case errors.Is(err, ErrGetShortenerExhausted):
+				exhausted++
+			default:
+				t.Errorf("UseShortener(code01) error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if used != 5 || exhausted != workers-5 {
+		t.Errorf("UseShortener(code01) used = %d, exhausted = %d, want 5 and %d", used, exhausted, workers-5)
+	}
+	if _, err := store.GetShortener("code01"); !errors.Is(err, ErrGetShortenerExhausted) || !errors.Is(err, ErrGetShortenerGone) {
+		t.Errorf("GetShortener(exhausted) error = %v, want %v", err, ErrGetShortenerExhausted)
+	}
+
+	// Одноразовая ссылка из пакета
+	got, err = store.UseShortener(ctx, "code02")
+	if err != nil || got.Data.URL != "https://example.com/2" || got.Data.ClicksLeft != 0 {
+		t.Errorf("UseShortener(code02) = %+v, %v, want url with 0 clicks left", got.Data, err)
+	}
+	if _, err := store.UseShortener(ctx, "code02"); !errors.Is(err, ErrGetShortenerExhausted) {
+		t.Errorf("UseShortener(code02) second error = %v, want %v", err, ErrGetShortenerExhausted)
+	}
+
+	// Ссылка без ограничения не меняется
+	for range 2 {
+		got, err = store.UseShortener(ctx, "code03")
+		if err != nil || got.Data.URL != "https://example.com/3" || got.Data.MaxClicks != 0 {
+			t.Errorf("UseShortener(code03) = %+v, %v, want unlimited link", got.Data, err)
+		}
+	}
+
+	if _, err := store.UseShortener(ctx, "unknown"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("UseShortener(unknown) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+	if _, err := store.DeleteShortenerBatch(ctx, []model.Shortener{newConformanceShortener("code03", "", "user1")}); err != nil {
+		t.Fatalf("DeleteShortenerBatch error = %v", err)
+	}
+	if _, err := store.UseShortener(ctx, "code03"); !errors.Is(err, ErrGetShortenerGone) {
+		t.Errorf("UseShortener(deleted) error = %v, want %v", err, ErrGetShortenerGone)
+	}
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+)
+
+func TestConformance_StoreVar(t *testing.T) {
+	Conformance(t, func(t *testing.T) Repository {
+		store, err := NewStoreVar(config.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestConformance_StoreFile(t *testing.T) {
+	Conformance(t, func(t *testing.T) Repository {
+		store, err := NewStoreFile(config.Config{Filename: filepath.Join(t.TempDir(), "store.json")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestConformance_StoreKV(t *testing.T) {
+	Conformance(t, func(t *testing.T) Repository {
+		store, err := NewStoreKV(config.Config{KVPath: filepath.Join(t.TempDir(), "store.db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestConformance_StoreSQLite(t *testing.T) {
+	Conformance(t, func(t *testing.T) Repository {
+		store, err := NewStoreSQLite(config.Config{DBDsn: config.SQLiteScheme + filepath.Join(t.TempDir(), "store.db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+// Для запуска нужна тестовая база: TEST_DATABASE_DSN="host=localhost user=... dbname=..."
+func TestConformance_StoreDB(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	Conformance(t, func(t *testing.T) Repository {
+		store, err := NewStoreDB(config.Config{DBDsn: dsn})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Зависимые таблицы очищаются вместе со ссылками: история ссылается на shortener
+		if _, err := store.database.Exec("TRUNCATE shortener, shortener_revisions, clicks, delete_jobs"); err != nil {
+			t.Fatal(err)
+		}
+		// Номера выдаются заново с 1
+		if _, err := store.database.Exec("UPDATE id_allocator SET leased = 0"); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+)
+
+// jobTable - задания удаления ссылок в памяти. Используется StoreVar и StoreFile
+type jobTable struct {
+	mux  sync.RWMutex
+	byID map[string]model.DeleteJob
+}
+
+// newJobTable - конструктор
+func newJobTable() *jobTable {
+	return &jobTable{byID: make(map[string]model.DeleteJob)}
+}
+
+// save создает или заменяет задание
+func (t *jobTable) save(job model.DeleteJob) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	job.Codes = slices.Clone(job.Codes)
+	t.byID[job.ID] = job
+}
+
+// get читает задание
+func (t *jobTable) get(id string) (model.DeleteJob, error) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	job, ok := t.byID[id]
+	if !ok {
+		return model.DeleteJob{}, ErrDeleteJobNotFound
+	}
+	job.Codes = slices.Clone(job.Codes)
+	return job, nil
+}
+
+// pending - невыполненные задания в порядке создания
+func (t *jobTable) pending() []model.DeleteJob {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	var jobs []model.DeleteJob
+	for _, job := range t.byID {
+		if job.Status == model.DeleteJobPending {
+			job.Codes = slices.Clone(job.Codes)
+			jobs = append(jobs, job)
+		}
+	}
+	sortJobs(jobs)
+	return jobs
+}
+
+// sortJobs упорядочивает задания по времени создания, при равенстве - по ID
+func sortJobs(jobs []model.DeleteJob) {
+	slices.SortFunc(jobs, func(a, b model.DeleteJob) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+}
+
+// JobJSON - структура файла заданий удаления StoreFile (файл хранилища с суффиксом jobsFileSuffix).
+// Каждое изменение задания дописывается полной записью, действует последняя
+type JobJSON struct {
+	ID          string                `json:"id"`
+	User        string                `json:"user"`
+	Status      model.DeleteJobStatus `json:"status"`
+	Codes       []model.DeleteJobCode `json:"codes"`
+	Attempts    int                   `json:"attempts,omitempty"`
+	Error       string                `json:"error,omitempty"`
+	NextAttempt time.Time             `json:"next_attempt"`
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+}
+
+// jobsFileSuffix - суффикс имени файла заданий удаления
+const jobsFileSuffix = ".jobs"
+
+// openJobFile открывает файл заданий удаления и восстанавливает из него задания.
+// Поврежденные записи (в т.ч. недописанная последняя) пропускаются
+func openJobFile(filename string) (*os.File, *jobTable, error) {
+	file, err := os.OpenFile(filename+jobsFileSuffix, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jobs := newJobTable()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		var jobJSON JobJSON
+		if err := json.Unmarshal(scanner.Bytes(), &jobJSON); err != nil || jobJSON.ID == "" {
+			continue
+		}
+		jobs.save(model.DeleteJob{
+			ID:          jobJSON.ID,
+			User:        jobJSON.User,
+			Status:      jobJSON.Status,
+			Codes:       jobJSON.Codes,
+			Attempts:    jobJSON.Attempts,
+			Error:       jobJSON.Error,
+			NextAttempt: jobJSON.NextAttempt,
+			CreatedAt:   jobJSON.CreatedAt,
+			UpdatedAt:   jobJSON.UpdatedAt,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, jobs, nil
+}
+
+// writeJob дописывает задание в файл заданий и сбрасывает файл на диск
+func writeJob(file *os.File, job model.DeleteJob) error {
+	data, err := json.Marshal(JobJSON{
+		ID:          job.ID,
+		User:        job.User,
+		Status:      job.Status,
+		Codes:       job.Codes,
+		Attempts:    job.Attempts,
+		Error:       job.Error,
+		NextAttempt: job.NextAttempt,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,558 @@
+package repository
+
+import (
+	"hash/maphash"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+)
+
+// memShards - кол-во сегментов мапы ссылок
+const memShards = 64
+
+// memShard - сегмент мапы ссылок со своей блокировкой
+type memShard struct {
+	mux       sync.RWMutex
+	shortener map[model.ShortenerKey]model.ShortenerData
+}
+
+// memIndex - ссылки в памяти с обратными индексами. Используется StoreVar и StoreFile.
+// Безопасен для конкурентного использования: чтение ссылки по коду и переход по ней блокируют только ее сегмент,
+// остальные изменения и чтение индексов выполняются под общей блокировкой индексов
+type memIndex struct {
+	seed   maphash.Seed
+	shards [memShards]memShard
+
+	// mux защищает индексы и порядок изменений. Берется до блокировки сегмента
+	mux sync.RWMutex
+	// urls: URL -> код (включая удаленные ссылки, URL повторно не сокращается)
+	urls map[string]model.ShortenerKey
+	// users: пользователь -> коды неудаленных ссылок. Пользователь без ссылок удаляется
+	users map[string]map[model.ShortenerKey]struct{}
+	// trash: пользователь -> коды удаленных ссылок до окончательного удаления
+	trash map[string]map[model.ShortenerKey]struct{}
+	// expiring: коды ссылок с ограниченным сроком действия
+	expiring map[model.ShortenerKey]struct{}
+	// total - кол-во ссылок, включая удаленные
+	total int
+	// active - кол-во неудаленных ссылок
+	active int
+	// leased - последний выделенный номер (LeaseIDBlock)
+	leased uint64
+	// days: начало суток (unix-время) -> ссылки, созданные за сутки
+	days map[int64]*memDay
+	// revisions: код -> история адресов измененной ссылки
+	revisions map[model.ShortenerKey][]model.Revision
+	// revisionCount - кол-во записей истории всех ссылок
+	revisionCount int
+}
+
+// memDay - ссылки, созданные за сутки, включая удаленные
+type memDay struct {
+	links int
+	// users: пользователь -> кол-во созданных им ссылок
+	users map[string]int
+}
+
+// newMemIndex - конструктор
+func newMemIndex() *memIndex {
+	idx := &memIndex{
+		seed:     maphash.MakeSeed(),
+		urls:     make(map[string]model.ShortenerKey),
+		users:    make(map[string]map[model.ShortenerKey]struct{}),
+		trash:    make(map[string]map[model.ShortenerKey]struct{}),
+		expiring: make(map[model.ShortenerKey]struct{}),
+		days:     make(map[int64]*memDay),
+
+		revisions: make(map[model.ShortenerKey][]model.Revision),
+	}
+	for i := range idx.shards {
+		idx.shards[i].shortener = make(map[model.ShortenerKey]model.ShortenerData)
+	}
+	return idx
+}
+
+// shard - сегмент, в котором хранится код
+func (idx *memIndex) shard(key model.ShortenerKey) *memShard {
+	return &idx.shards[maphash.String(idx.seed, key.Code)%memShards]
+}
+
+// len - кол-во записей снимка: ссылки, включая удаленные, и история их изменений
+func (idx *memIndex) len() int {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	return idx.total + idx.revisionCount
+}
+
+// get читает ссылку по коду
+func (idx *memIndex) get(key model.ShortenerKey) (model.ShortenerData, bool) {
+	shard := idx.shard(key)
+	shard.mux.RLock()
+	defer shard.mux.RUnlock()
+
+	data, ok := shard.shortener[key]
+	return data, ok
+}
+
+// leaseIDs выделяет блок из size номеров и возвращает первый номер блока
+func (idx *memIndex) leaseIDs(size uint64) uint64 {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	start := idx.leased + 1
+	idx.leased += size
+	return start
+}
+
+// restoreIDs восстанавливает границу выделенных номеров (восстановление из файла)
+func (idx *memIndex) restoreIDs(leased uint64) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	idx.leased = max(idx.leased, leased)
+}
+
+// leasedIDs - последний выделенный номер
+func (idx *memIndex) leasedIDs() uint64 {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	return idx.leased
+}
+
+// set создает ссылку позиции, если URL еще не сокращен и код свободен
+func (idx *memIndex) set(row model.ShortenerBatchRow) model.ShortenerBatchRow {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	// Проверка: уже существует
+	if oldKey, ok := idx.urls[row.Shortener.Data.URL]; ok {
+		oldData, _ := idx.get(oldKey)
+		row.Shortener = model.Shortener{Key: oldKey, Data: oldData}
+		row.Status = model.ShortenerStatusExists
+		return row
+	}
+	if _, ok := idx.get(row.Shortener.Key); ok {
+		row.Status = model.ShortenerStatusCodeConflict
+		return row
+	}
+
+	if row.Shortener.Data.CreatedAt.IsZero() {
+		row.Shortener.Data.CreatedAt = time.Now()
+	}
+	idx.put(row.Shortener.Key, row.Shortener.Data)
+	row.Status = model.ShortenerStatusCreated
+	return row
+}
+
+// replace записывает ссылку, заменяя прежнюю с тем же кодом (восстановление из файла)
+func (idx *memIndex) replace(key model.ShortenerKey, data model.ShortenerData) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	idx.put(key, data)
+}
+
+// put записывает ссылку и обновляет индексы. Вызывается под блокировкой индексов
+func (idx *memIndex) put(key model.ShortenerKey, data model.ShortenerData) {
+	shard := idx.shard(key)
+	shard.mux.Lock()
+	old, ok := shard.shortener[key]
+	shard.shortener[key] = data
+	shard.mux.Unlock()
+
+	if ok {
+		idx.unindex(key, old)
+	} else {
+		idx.total++
+	}
+	idx.countDay(data, 1)
+	idx.urls[data.URL] = key
+	idx.link(key, data)
+	if !data.ExpiresAt.IsZero() {
+		idx.expiring[key] = struct{}{}
+	}
+}
+
+// unindex убирает ссылку из индексов. Вызывается под блокировкой индексов
+func (idx *memIndex) unindex(key model.ShortenerKey, data model.ShortenerData) {
+	idx.unlink(key, data)
+	idx.countDay(data, -1)
+	if idx.urls[data.URL] == key {
+		delete(idx.urls, data.URL)
+	}
+	delete(idx.expiring, key)
+}
+
+// remove окончательно удаляет ссылку. Вызывается под блокировкой индексов
+func (idx *memIndex) remove(key model.ShortenerKey) (model.ShortenerData, bool) {
+	shard := idx.shard(key)
+	shard.mux.Lock()
+	data, ok := shard.shortener[key]
+	delete(shard.shortener, key)
+	shard.mux.Unlock()
+
+	if ok {
+		idx.unindex(key, data)
+		idx.revisionCount -= len(idx.revisions[key])
+		delete(idx.revisions, key)
+		idx.total--
+	}
+	return data, ok
+}
+
+// update меняет адрес назначения неудаленной ссылки пользователя и дополняет историю.
+// Возвращает ссылку и добавленные записи истории (при первом изменении - и исходный адрес).
+// Если URL сокращен другой ссылкой, возвращается эта ссылка и ErrSetShortenerAlreadyExists
+func (idx *memIndex) update(key model.ShortenerKey, user, url string, now time.Time) (model.Shortener, []model.Revision, error) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	data, ok := idx.get(key)
+	switch {
+	case !ok || data.User != user:
+		return model.Shortener{}, nil, newErrGetShortenerNotFound(key.Code)
+	case data.DelFlag:
+		return model.Shortener{}, nil, ErrGetShortenerGone
+	case data.Expired(now):
+		return model.Shortener{}, nil, ErrGetShortenerExpired
+	case data.URL == url:
+		return model.Shortener{Key: key, Data: data}, nil, nil
+	}
+	if oldKey, ok := idx.urls[url]; ok {
+		oldData, _ := idx.get(oldKey)
+		return model.Shortener{Key: oldKey, Data: oldData}, nil, ErrSetShortenerAlreadyExists
+	}
+
+	var revs []model.Revision
+	history := idx.revisions[key]
+	if len(history) == 0 {
+		revs = append(revs, model.Revision{Revision: 1, URL: data.URL, CreatedAt: data.CreatedAt})
+	}
+	revs = append(revs, model.Revision{Revision: len(history) + len(revs) + 1, URL: url, CreatedAt: now})
+	for _, rev := range revs {
+		data = idx.applyRevision(key, rev)
+	}
+	return model.Shortener{Key: key, Data: data}, revs, nil
+}
+
+// restoreRevision применяет запись истории (восстановление из файла).
+// История ссылки, которой еще нет, сохраняется без изменения адреса
+func (idx *memIndex) restoreRevision(key model.ShortenerKey, rev model.Revision) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	idx.applyRevision(key, rev)
+}
+
+// applyRevision дополняет историю и меняет адрес ссылки. Вызывается под блокировкой индексов
+func (idx *memIndex) applyRevision(key model.ShortenerKey, rev model.Revision) model.ShortenerData {
+	idx.revisions[key] = append(idx.revisions[key], rev)
+	idx.revisionCount++
+	data, ok := idx.get(key)
+	if !ok {
+		return data
+	}
+	data.URL = rev.URL
+	idx.put(key, data)
+	return data
+}
+
+// history - история адресов ссылки
+func (idx *memIndex) history(key model.ShortenerKey) ([]model.Revision, error) {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	data, ok := idx.get(key)
+	if !ok {
+		return nil, newErrGetShortenerNotFound(key.Code)
+	}
+	if revs := idx.revisions[key]; len(revs) > 0 {
+		return slices.Clone(revs), nil
+	}
+	return []model.Revision{{Revision: 1, URL: data.URL, CreatedAt: data.CreatedAt}}, nil
+}
+
+// countDay учитывает создание ссылки в статистике по суткам. Вызывается под блокировкой индексов
+func (idx *memIndex) countDay(data model.ShortenerData, delta int) {
+	if data.CreatedAt.IsZero() {
+		return
+	}
+	key := model.BucketStart(data.CreatedAt, model.StatsDay).Unix()
+	day, ok := idx.days[key]
+	if !ok {
+		day = &memDay{users: make(map[string]int)}
+		idx.days[key] = day
+	}
+	day.links += delta
+	day.users[data.User] += delta
+	if day.users[data.User] <= 0 {
+		delete(day.users, data.User)
+	}
+	if day.links <= 0 {
+		delete(idx.days, key)
+	}
+}
+
+// purge окончательно удаляет ссылку (восстановление из файла)
+func (idx *memIndex) purge(key model.ShortenerKey) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	idx.remove(key)
+}
+
+// purgeExpired окончательно удаляет ссылки, срок действия которых истек к моменту now.
+// Просматриваются только ссылки с ограниченным сроком действия
+func (idx *memIndex) purgeExpired(now time.Time) []model.Shortener {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	var purged []model.Shortener
+	for key := range idx.expiring {
+		if data, ok := idx.get(key); ok && data.Expired(now) {
+			idx.remove(key)
+			purged = append(purged, model.Shortener{Key: key, Data: data})
+		}
+	}
+	return purged
+}
+
+// markDeleted помечает удаленной в момент now ссылку пользователя и возвращает результат удаления.
+// Ссылка не меняется, если ее нет, она чужая или уже удалена
+func (idx *memIndex) markDeleted(key model.ShortenerKey, user string, now time.Time) model.DeleteStatus {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	shard := idx.shard(key)
+	shard.mux.Lock()
+	data, ok := shard.shortener[key]
+	if status := deleteStatus(data, ok, user); status != model.DeleteStatusDeleted {
+		shard.mux.Unlock()
+		return status
+	}
+	deleted := data
+	deleted.DelFlag = true
+	deleted.DeletedAt = now
+	shard.shortener[key] = deleted
+	shard.mux.Unlock()
+
+	idx.unlink(key, data)
+	idx.link(key, deleted)
+	return model.DeleteStatusDeleted
+}
+
+// use уменьшает в момент now остаток переходов по ссылке с ограничением и возвращает ссылку после перехода.
+// Ссылка без ограничения не меняется. Индексы не меняются: блокируется только сегмент ссылки
+func (idx *memIndex) use(key model.ShortenerKey, now time.Time) (model.Shortener, error) {
+	shard := idx.shard(key)
+	shard.mux.Lock()
+	defer shard.mux.Unlock()
+
+	data, ok := shard.shortener[key]
+	if err := checkShortener(key.Code, data, ok, now); err != nil {
+		return model.Shortener{}, err
+	}
+	if data.MaxClicks > 0 {
+		data.ClicksLeft--
+		shard.shortener[key] = data
+	}
+	return model.Shortener{Key: key, Data: data}, nil
+}
+
+// restore снимает признак удаления со ссылки пользователя, удаленной не раньше deletedAfter
+func (idx *memIndex) restore(key model.ShortenerKey, user string, deletedAfter time.Time) (model.Shortener, error) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	shard := idx.shard(key)
+	shard.mux.Lock()
+	data, ok := shard.shortener[key]
+	if err := checkRestore(key.Code, data, ok, user, deletedAfter); err != nil {
+		shard.mux.Unlock()
+		return model.Shortener{}, err
+	}
+	restored := data
+	restored.DelFlag = false
+	restored.DeletedAt = time.Time{}
+	shard.shortener[key] = restored
+	shard.mux.Unlock()
+
+	idx.unlink(key, data)
+	idx.link(key, restored)
+	return model.Shortener{Key: key, Data: restored}, nil
+}
+
+// purgeDeleted окончательно удаляет ссылки, удаленные раньше before.
+// Ссылки с неизвестным временем удаления не удаляются
+func (idx *memIndex) purgeDeleted(before time.Time) []model.Shortener {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	var purged []model.Shortener
+	for _, codes := range idx.trash {
+		for key := range codes {
+			if data, ok := idx.get(key); ok && !data.DeletedAt.IsZero() && data.DeletedAt.Before(before) {
+				purged = append(purged, model.Shortener{Key: key, Data: data})
+			}
+		}
+	}
+	// Удаление после обхода: remove меняет индекс удаленных ссылок
+	for _, s := range purged {
+		idx.remove(s.Key)
+	}
+	return purged
+}
+
+// backfillDeleted назначает время удаления now удаленным ссылкам с неизвестным временем удаления
+// (удаленным до учета времени). Возвращает кол-во измененных ссылок
+func (idx *memIndex) backfillDeleted(now time.Time) int {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	var n int
+	for _, codes := range idx.trash {
+		for key := range codes {
+			shard := idx.shard(key)
+			shard.mux.Lock()
+			if data, ok := shard.shortener[key]; ok && data.DeletedAt.IsZero() {
+				data.DeletedAt = now
+				shard.shortener[key] = data
+				n++
+			}
+			shard.mux.Unlock()
+		}
+	}
+	return n
+}
+
+// link добавляет ссылку в индекс пользователей или, если она удалена, в индекс удаленных ссылок
+func (idx *memIndex) link(key model.ShortenerKey, data model.ShortenerData) {
+	index := idx.users
+	if data.DelFlag {
+		index = idx.trash
+	}
+	codes, ok := index[data.User]
+	if !ok {
+		codes = make(map[model.ShortenerKey]struct{})
+		index[data.User] = codes
+	}
+	codes[key] = struct{}{}
+	if !data.DelFlag {
+		idx.active++
+	}
+}
+
+// unlink убирает ссылку из индекса пользователей или индекса удаленных ссылок
+func (idx *memIndex) unlink(key model.ShortenerKey, data model.ShortenerData) {
+	index := idx.users
+	if data.DelFlag {
+		index = idx.trash
+	}
+	codes := index[data.User]
+	delete(codes, key)
+	if len(codes) == 0 {
+		delete(index, data.User)
+	}
+	if !data.DelFlag {
+		idx.active--
+	}
+}
+
+// byUser - неудаленные и не истекшие к моменту now ссылки пользователя
+func (idx *memIndex) byUser(user string, now time.Time) []model.Shortener {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	codes := idx.users[user]
+	if len(codes) == 0 {
+		return nil
+	}
+	resp := make([]model.Shortener, 0, len(codes))
+	for key := range codes {
+		data, _ := idx.get(key)
+		if data.Expired(now) {
+			continue
+		}
+		resp = append(resp, model.Shortener{Key: key, Data: data})
+	}
+	return resp
+}
+
+// deletedByUser - удаленные ссылки пользователя до окончательного удаления
+func (idx *memIndex) deletedByUser(user string) []model.Shortener {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	codes := idx.trash[user]
+	if len(codes) == 0 {
+		return nil
+	}
+	resp := make([]model.Shortener, 0, len(codes))
+	for key := range codes {
+		data, _ := idx.get(key)
+		resp = append(resp, model.Shortener{Key: key, Data: data})
+	}
+	return resp
+}
+
+// stats - кол-во неудаленных ссылок и пользователей, у которых они есть.
+// Истекшие ссылки учитываются до окончательного удаления
+func (idx *memIndex) stats() model.Stats {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	return model.Stats{URLs: idx.active, Users: len(idx.users)}
+}
+
+// report - статистика ссылок за период [from, to), границы выровнены по суткам.
+// Просматриваются только счетчики суток периода и удаленные ссылки
+func (idx *memIndex) report(from, to time.Time) model.StatsReport {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	report := model.StatsReport{Stats: model.Stats{URLs: idx.active, Users: len(idx.users)}}
+	for _, codes := range idx.trash {
+		for key := range codes {
+			if data, ok := idx.get(key); ok && !data.DeletedAt.Before(from) && data.DeletedAt.Before(to) {
+				report.DeletedURLs++
+			}
+		}
+	}
+	users := make(map[string]struct{})
+	for start := from; start.Before(to); start = start.Add(model.StatsDay) {
+		day, ok := idx.days[start.Unix()]
+		if !ok {
+			continue
+		}
+		report.Created = append(report.Created, model.TimeBucket{Start: start, Count: day.links})
+		for user := range day.users {
+			users[user] = struct{}{}
+		}
+	}
+	report.ActiveUsers = len(users)
+	return report
+}
+
+// each вызывает fn для каждой ссылки, включая удаленные, с историей изменений ссылки.
+// Изменения индекса на это время блокируются
+func (idx *memIndex) each(fn func(key model.ShortenerKey, data model.ShortenerData, revisions []model.Revision) error) error {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	for i := range idx.shards {
+		shard := &idx.shards[i]
+		shard.mux.RLock()
+		for key, data := range shard.shortener {
+			if err := fn(key, data, idx.revisions[key]); err != nil {
+				shard.mux.RUnlock()
+				return err
+			}
+		}
+		shard.mux.RUnlock()
+	}
+	return nil
+}
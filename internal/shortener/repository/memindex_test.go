@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+)
+
+func TestMemIndex(t *testing.T) {
+	idx := newMemIndex()
+	a := model.ShortenerKey{Code: "a"}
+	b := model.ShortenerKey{Code: "b"}
+	c := model.ShortenerKey{Code: "c"}
+	idx.replace(a, model.ShortenerData{URL: "https://a.ru/", User: "u1"})
+	idx.replace(b, model.ShortenerData{URL: "https://b.ru/", User: "u1"})
+	idx.replace(c, model.ShortenerData{URL: "https://c.ru/", User: "u2"})
+
+	if stats := idx.stats(); stats != (model.Stats{URLs: 3, Users: 2}) {
+		t.Errorf("stats = %+v, want 3 urls and 2 users", stats)
+	}
+
+	// Удаление последней ссылки пользователя убирает его из статистики
+	if idx.markDeleted(c, "u1", time.Now()) != model.DeleteStatusNotFound {
+		t.Error("link deleted by another user")
+	}
+	if idx.markDeleted(c, "u2", time.Now()) != model.DeleteStatusDeleted ||
+		idx.markDeleted(c, "u2", time.Now()) != model.DeleteStatusAlreadyDeleted {
+		t.Error("link must be deleted exactly once")
+	}
+	if stats := idx.stats(); stats != (model.Stats{URLs: 2, Users: 1}) {
+		t.Errorf("stats after delete = %+v, want 2 urls and 1 user", stats)
+	}
+	if got := idx.byUser("u2", time.Now()); len(got) != 0 {
+		t.Errorf("deleted link is listed: %+v", got)
+	}
+	if got := idx.deletedByUser("u2"); len(got) != 1 || got[0].Key != c || got[0].Data.DeletedAt.IsZero() {
+		t.Errorf("deletedByUser(u2) = %+v, want c with deletion time", got)
+	}
+
+	// Удаленный URL повторно не сокращается
+	row := idx.set(model.ShortenerBatchRow{Shortener: model.Shortener{
+		Key:  model.ShortenerKey{Code: "d"},
+		Data: model.ShortenerData{URL: "https://c.ru/"},
+	}})
+	if row.Status != model.ShortenerStatusExists || row.Shortener.Key != c {
+		t.Errorf("check deleted url = %+v, want exists with code c", row)
+	}
+
+	// Замена ссылки с тем же кодом (повтор записи в файле) обновляет индексы
+	idx.replace(a, model.ShortenerData{URL: "https://a2.ru/", User: "u3"})
+	if _, ok := idx.urls["https://a.ru/"]; ok {
+		t.Error("old url of replaced link is still indexed")
+	}
+	if got := idx.byUser("u1", time.Now()); len(got) != 1 || got[0].Key != b {
+		t.Errorf("byUser(u1) = %+v, want only b", got)
+	}
+	if stats := idx.stats(); stats != (model.Stats{URLs: 2, Users: 2}) {
+		t.Errorf("stats after replace = %+v, want 2 urls and 2 users", stats)
+	}
+}
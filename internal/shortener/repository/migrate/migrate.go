@@ -0,0 +1,304 @@
+// Пакет migrate. Версионные миграции схемы базы данных
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed postgres/*.sql
+var postgresFS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFS embed.FS
+
+// Dialect - особенности конкретной СУБД
+type Dialect struct {
+	// Name - имя каталога с миграциями
+	Name string
+	// FS - файлы миграций вида 0001_name.up.sql / 0001_name.down.sql
+	FS fs.FS
+	// CreateTable - создание таблицы учета миграций
+	CreateTable string
+	// Lock - блокировка, исключающая одновременный запуск миграций несколькими экземплярами сервиса.
+	// Возвращает функцию снятия блокировки
+	Lock func(ctx context.Context, conn *sql.Conn) (func(), error)
+}
+
+// advisoryLockID - ключ рекомендательной блокировки PostgreSQL для миграций
+const advisoryLockID = 7242305167
+
+// Postgres - диалект PostgreSQL
+var Postgres = Dialect{
+	Name: "postgres",
+	FS:   postgresFS,
+	CreateTable: "CREATE TABLE IF NOT EXISTS schema_migrations (" +
+		" version BIGINT PRIMARY KEY," +
+		" name VARCHAR (255) NOT NULL," +
+		" applied_at TIMESTAMPTZ NOT NULL DEFAULT now()" +
+		" );",
+	Lock: func(ctx context.Context, conn *sql.Conn) (func(), error) {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID); err != nil {
+			return nil, err
+		}
+		return func() {
+			conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockID)
+		}, nil
+	},
+}
+
+// SQLite - диалект SQLite.
+// Файл базы открывает один экземпляр сервиса, запись в SQLite и так выполняется последовательно
+var SQLite = Dialect{
+	Name: "sqlite",
+	FS:   sqliteFS,
+	CreateTable: "CREATE TABLE IF NOT EXISTS schema_migrations (" +
+		" version INTEGER PRIMARY KEY," +
+		" name TEXT NOT NULL," +
+		" applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP" +
+		" );",
+	Lock: func(ctx context.Context, conn *sql.Conn) (func(), error) {
+		return func() {}, nil
+	},
+}
+
+// Migration - шаг миграции
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status - состояние шага миграции
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Ошибки пакета
+var (
+	ErrInvalidMigration = errors.New("invalid migration")
+)
+
+// Migrator применяет и откатывает миграции
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New - конструктор. Читает миграции диалекта
+func New(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	migrations, err := readMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+// readMigrations читает и упорядочивает файлы миграций
+func readMigrations(dialect Dialect) ([]Migration, error) {
+	files, err := fs.ReadDir(dialect.FS, dialect.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		name := file.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		// 0001_name.up.sql
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, migrationName, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, name)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, name)
+		}
+
+		data, err := fs.ReadFile(dialect.FS, path.Join(dialect.Name, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: migrationName}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%w: version %d has no up step", ErrInvalidMigration, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up применяет все неприменённые миграции. Возвращает примененные шаги
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err := m.apply(ctx, conn, migration.Up,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+				migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down откатывает последние steps применённых миграций. Возвращает откаченные шаги
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w: version %d has no down step", ErrInvalidMigration, migration.Version)
+			}
+			err := m.apply(ctx, conn, migration.Down,
+				"DELETE FROM schema_migrations WHERE version = $1",
+				migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status возвращает состояние всех известных миграций
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			appliedAt, ok := applied[migration.Version]
+			statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// locked выполняет fn на отдельном соединении под блокировкой миграций
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	unlock, err := m.dialect.Lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := conn.ExecContext(ctx, m.dialect.CreateTable); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// applied - применённые версии и время применения
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// apply выполняет шаг миграции и запись в schema_migrations в одной транзакции
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, step string, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, step); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
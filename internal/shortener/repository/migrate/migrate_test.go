@@ -0,0 +1,134 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestReadMigrations_Dialects(t *testing.T) {
+	for _, dialect := range []Dialect{Postgres, SQLite} {
+		t.Run(dialect.Name, func(t *testing.T) {
+			migrations, err := readMigrations(dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(migrations) == 0 {
+				t.Fatal("no migrations")
+			}
+			for i, m := range migrations {
+				if m.Version != int64(i+1) {
+					t.Errorf("migration %d has version %d, want sequential versions", i, m.Version)
+				}
+				if m.Up == "" || m.Down == "" {
+					t.Errorf("migration %d_%s must have up and down steps", m.Version, m.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestReadMigrations_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fs   fstest.MapFS
+	}{
+		{
+			name: "no version",
+			fs:   fstest.MapFS{"test/init.up.sql": {Data: []byte("SELECT 1")}},
+		}, {
+			name: "no up step",
+			fs:   fstest.MapFS{"test/0001_init.down.sql": {Data: []byte("SELECT 1")}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := readMigrations(Dialect{Name: "test", FS: test.fs})
+			if !errors.Is(err, ErrInvalidMigration) {
+				t.Errorf("readMigrations error = %v, want %v", err, ErrInvalidMigration)
+			}
+		})
+	}
+}
+
+// Для запуска нужна тестовая база: TEST_DATABASE_DSN="host=localhost user=... dbname=..."
+func TestMigrator_UniqueURLKeepsCodes(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	ctx := context.Background()
+
+	// Миграции применяются в отдельной схеме: таблицы сервиса в тестовой базе не затрагиваются
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	defer admin.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE")
+
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connConfig.RuntimeParams["search_path"] = schema
+	db := stdlib.OpenDB(*connConfig)
+	defer db.Close()
+
+	migrator, err := New(db, Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Схема до уникального индекса URL
+	all := migrator.migrations
+	migrator.migrations = all[:2]
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO shortener (code, url) VALUES"+
+		" ('code01', 'https://ya.ru/'), ('code02', 'https://ya.ru/'), ('code03', 'https://go.dev/')"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Дубликаты останавливают миграцию с их перечнем
+	migrator.migrations = all
+	_, err = migrator.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "code01, code02") {
+		t.Fatalf("Up error = %v, want duplicate urls error", err)
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT count(*) FROM shortener").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("shortener has %d codes after failed migration, want 3", count)
+	}
+
+	// После разрешения дубликатов миграция продолжается
+	if _, err := db.ExecContext(ctx, "UPDATE shortener SET url = 'https://ya.ru/?2' WHERE code = 'code02'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT count(*) FROM shortener").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("shortener has %d codes after migration, want 3", count)
+	}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS shortener (
+    code VARCHAR (10) PRIMARY KEY,
+    url VARCHAR (255) NOT NULL,
+    uuid VARCHAR (10) DEFAULT NULL,
+    del_flag BOOLEAN DEFAULT FALSE
+);
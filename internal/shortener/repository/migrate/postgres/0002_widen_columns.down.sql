@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS shortener_uuid_idx;
+ALTER TABLE shortener DROP COLUMN IF EXISTS created_at;
+ALTER TABLE shortener ALTER COLUMN uuid TYPE VARCHAR (10);
+ALTER TABLE shortener ALTER COLUMN url TYPE VARCHAR (255);
@@ -0,0 +1,4 @@
+ALTER TABLE shortener ALTER COLUMN url TYPE TEXT;
+ALTER TABLE shortener ALTER COLUMN uuid TYPE VARCHAR (64);
+ALTER TABLE shortener ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS shortener_uuid_idx ON shortener (uuid);
@@ -0,0 +1 @@
+ALTER TABLE shortener DROP CONSTRAINT IF EXISTS shortener_url_key;
@@ -0,0 +1,22 @@
+ALTER TABLE shortener DROP CONSTRAINT IF EXISTS shortener_url_key;
+-- Дубликаты URL могли появиться из-за гонки проверки и вставки. Коды не удаляются:
+-- миграция останавливается со списком дубликатов, оставить по одному коду на URL нужно вручную
+DO $$
+DECLARE
+    total BIGINT;
+    duplicates TEXT;
+BEGIN
+    SELECT count(*) INTO total
+        FROM (SELECT url FROM shortener GROUP BY url HAVING count(*) > 1) d;
+    IF total > 0 THEN
+        SELECT string_agg(d.url || ' (' || d.codes || ')', '; ') INTO duplicates
+            FROM (SELECT url, string_agg(code, ', ' ORDER BY created_at, code) AS codes
+                    FROM shortener
+                    GROUP BY url
+                    HAVING count(*) > 1
+                    ORDER BY url
+                    LIMIT 20) d;
+        RAISE EXCEPTION 'shortener has % duplicate urls, keep one code per url and retry: %', total, duplicates;
+    END IF;
+END $$;
+ALTER TABLE shortener ADD CONSTRAINT shortener_url_key UNIQUE (url);
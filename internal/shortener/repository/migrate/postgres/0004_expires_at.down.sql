@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS shortener_expires_at_idx;
+ALTER TABLE shortener DROP COLUMN IF EXISTS expires_at;
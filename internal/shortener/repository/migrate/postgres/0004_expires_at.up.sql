@@ -0,0 +1,2 @@
+ALTER TABLE shortener ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS shortener_expires_at_idx ON shortener (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE shortener ALTER COLUMN code TYPE VARCHAR (10);
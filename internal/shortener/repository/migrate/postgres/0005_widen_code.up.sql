@@ -0,0 +1,2 @@
+-- Пользовательские коды ссылок длиннее сгенерированных
+ALTER TABLE shortener ALTER COLUMN code TYPE VARCHAR (32);
@@ -0,0 +1,6 @@
+-- Граница выделенных номеров для генераторов кодов (одна строка)
+CREATE TABLE IF NOT EXISTS id_allocator (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    leased BIGINT NOT NULL
+);
+INSERT INTO id_allocator (id, leased) VALUES (1, 0) ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS clicks (
+    code VARCHAR (32) NOT NULL,
+    clicked_at TIMESTAMPTZ NOT NULL,
+    referrer TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_hash VARCHAR (64) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS clicks_code_clicked_at_idx ON clicks (code, clicked_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS clicks_clicked_at_idx;
+DROP INDEX IF EXISTS shortener_created_at_idx;
@@ -0,0 +1,3 @@
+-- Индексы для статистики за период
+CREATE INDEX IF NOT EXISTS shortener_created_at_idx ON shortener (created_at);
+CREATE INDEX IF NOT EXISTS clicks_clicked_at_idx ON clicks (clicked_at);
@@ -0,0 +1,8 @@
+-- История адресов измененных ссылок
+CREATE TABLE IF NOT EXISTS shortener_revisions (
+    code VARCHAR (32) NOT NULL REFERENCES shortener (code) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (code, revision)
+);
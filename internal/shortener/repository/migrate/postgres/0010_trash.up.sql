@@ -0,0 +1,3 @@
+-- Время удаления ссылки, NULL - не удалена (ссылкам, удаленным до учета времени, его назначает 0014_deleted_at_backfill)
+ALTER TABLE shortener ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS shortener_deleted_at_idx ON shortener (deleted_at) WHERE del_flag;
@@ -0,0 +1,13 @@
+-- Задания удаления ссылок, codes - коды и результаты их удаления
+CREATE TABLE IF NOT EXISTS delete_jobs (
+    id VARCHAR (32) PRIMARY KEY,
+    uuid VARCHAR (64) NOT NULL,
+    status VARCHAR (16) NOT NULL,
+    codes JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    next_attempt TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS delete_jobs_pending_idx ON delete_jobs (created_at) WHERE status = 'pending';
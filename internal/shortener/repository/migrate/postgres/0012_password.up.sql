@@ -0,0 +1,2 @@
+-- Хэш пароля для перехода по ссылке, пусто - переход без пароля
+ALTER TABLE shortener ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT '';
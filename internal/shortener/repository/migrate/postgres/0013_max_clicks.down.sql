@@ -0,0 +1,2 @@
+ALTER TABLE shortener DROP COLUMN IF EXISTS clicks_left;
+ALTER TABLE shortener DROP COLUMN IF EXISTS max_clicks;
@@ -0,0 +1,3 @@
+-- Ограничение и остаток переходов по ссылке, 0 - без ограничения
+ALTER TABLE shortener ADD COLUMN IF NOT EXISTS max_clicks INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE shortener ADD COLUMN IF NOT EXISTS clicks_left INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Ссылкам, удаленным до учета времени удаления, назначается время миграции:
+-- они хранятся в корзине полный срок, а не удаляются окончательно при первой очистке
+UPDATE shortener SET deleted_at = now() WHERE del_flag AND deleted_at IS NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS shortener (
+    code TEXT PRIMARY KEY,
+    url TEXT NOT NULL,
+    uuid TEXT DEFAULT NULL,
+    del_flag BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS shortener_url_key ON shortener (url);
+CREATE INDEX IF NOT EXISTS shortener_uuid_idx ON shortener (uuid);
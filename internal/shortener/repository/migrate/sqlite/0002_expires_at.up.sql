@@ -0,0 +1,3 @@
+-- unix-время в наносекундах, NULL - бессрочная ссылка
+ALTER TABLE shortener ADD COLUMN expires_at INTEGER;
+CREATE INDEX IF NOT EXISTS shortener_expires_at_idx ON shortener (expires_at) WHERE expires_at IS NOT NULL;
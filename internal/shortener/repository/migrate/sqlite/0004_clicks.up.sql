@@ -0,0 +1,9 @@
+-- clicked_at - unix-время в наносекундах
+CREATE TABLE IF NOT EXISTS clicks (
+    code TEXT NOT NULL,
+    clicked_at INTEGER NOT NULL,
+    referrer TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_hash TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS clicks_code_clicked_at_idx ON clicks (code, clicked_at);
@@ -0,0 +1,8 @@
+-- История адресов измененных ссылок, created_at - unix-время в наносекундах
+CREATE TABLE IF NOT EXISTS shortener_revisions (
+    code TEXT NOT NULL REFERENCES shortener (code) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    created_at INTEGER,
+    PRIMARY KEY (code, revision)
+);
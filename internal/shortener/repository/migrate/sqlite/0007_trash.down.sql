@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS shortener_deleted_at_idx;
+ALTER TABLE shortener DROP COLUMN deleted_at;
@@ -0,0 +1,3 @@
+-- Время удаления ссылки, unix-время в наносекундах, NULL - не удалена (ссылкам, удаленным до учета времени, его назначает 0011_deleted_at_backfill)
+ALTER TABLE shortener ADD COLUMN deleted_at INTEGER;
+CREATE INDEX IF NOT EXISTS shortener_deleted_at_idx ON shortener (deleted_at) WHERE del_flag;
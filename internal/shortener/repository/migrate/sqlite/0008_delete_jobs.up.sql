@@ -0,0 +1,14 @@
+-- Задания удаления ссылок, codes - коды и результаты их удаления в JSON,
+-- время - unix-время в наносекундах
+CREATE TABLE IF NOT EXISTS delete_jobs (
+    id TEXT PRIMARY KEY,
+    uuid TEXT NOT NULL,
+    status TEXT NOT NULL,
+    codes TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    next_attempt INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS delete_jobs_pending_idx ON delete_jobs (created_at) WHERE status = 'pending';
@@ -0,0 +1 @@
+ALTER TABLE shortener DROP COLUMN password_hash;
@@ -0,0 +1,2 @@
+ALTER TABLE shortener DROP COLUMN clicks_left;
+ALTER TABLE shortener DROP COLUMN max_clicks;
@@ -0,0 +1,3 @@
+-- Ограничение и остаток переходов по ссылке, 0 - без ограничения
+ALTER TABLE shortener ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE shortener ADD COLUMN clicks_left INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Назначенное время удаления не отличить от настоящего: откат не меняет данные
+SELECT 1;
@@ -0,0 +1,3 @@
+-- Ссылкам, удаленным до учета времени удаления, назначается время миграции (unix-время в наносекундах):
+-- они хранятся в корзине полный срок, а не удаляются окончательно при первой очистке
+UPDATE shortener SET deleted_at = CAST(strftime('%s', 'now') AS INTEGER) * 1000000000 WHERE del_flag AND deleted_at IS NULL;
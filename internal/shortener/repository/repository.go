@@ -11,35 +11,93 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 
 	"github.com/iurnickita/vigilant-train/internal/shortener/model"
 	"github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/migrate"
 )
 
 // Repository - интерфейс хранилища
 type Repository interface {
 	// GetShortener читает короткую ссылку
 	GetShortener(code string) (model.Shortener, error)
+	// UseShortener учитывает переход по ссылке с ограничением кол-ва переходов: атомарно уменьшает
+	// остаток переходов и возвращает ссылку после перехода. Исчерпанная ссылка - ErrGetShortenerExhausted,
+	// ссылка без ограничения возвращается без изменений
+	UseShortener(ctx context.Context, code string) (model.Shortener, error)
 	// SetShortener создает короткую ссылку
 	SetShortener(ctx context.Context, s model.Shortener) (model.Shortener, error)
-	// SetShortenerBatch создает короткую ссылку для набора данных
-	SetShortenerBatch(ctx context.Context, s []model.Shortener) ([]model.Shortener, error)
+	// SetShortenerBatch создает короткую ссылку для набора данных.
+	// Результат по каждой позиции возвращается в порядке запроса с тем же ID
+	SetShortenerBatch(ctx context.Context, s []model.ShortenerBatchRow) ([]model.ShortenerBatchRow, error)
 	// Ping
 	Ping() error
 	// GetShortenerBatch возвращает все ссылки, добавленные пользователем
 	GetShortenerBatch(ctx context.Context, userCode string) ([]model.Shortener, error)
-	// DeleteShortenerBatch удаляет короткую ссылку
-	DeleteShortenerBatch(ctx context.Context, s []model.Shortener) error
+	// DeleteShortenerBatch удаляет ссылки s.Key.Code пользователей s.Data.User (удалить может только владелец).
+	// Результат по каждой позиции возвращается в порядке запроса
+	DeleteShortenerBatch(ctx context.Context, s []model.Shortener) ([]model.DeleteStatus, error)
+	// GetDeletedBatch возвращает удаленные ссылки пользователя до их окончательного удаления (с DeletedAt)
+	GetDeletedBatch(ctx context.Context, userCode string) ([]model.Shortener, error)
+	// RestoreShortener снимает признак удаления со ссылки s.Key.Code пользователя s.Data.User.
+	// Чужая ссылка не найдется, неудаленная - ErrShortenerNotDeleted,
+	// удаленная раньше deletedAfter (или в неизвестный момент) - ErrRestoreGracePassed
+	RestoreShortener(ctx context.Context, s model.Shortener, deletedAfter time.Time) (model.Shortener, error)
+	// PurgeDeleted окончательно удаляет ссылки, удаленные раньше before, вместе с историей изменений и переходами.
+	// Ссылкам, удаленным до учета времени удаления, хранилище назначает время при загрузке или миграции.
+	// Возвращает кол-во удаленных ссылок
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
 	// GetStats возвращает статистические данные
 	GetStats(ctx context.Context) (model.Stats, error)
+	// DeleteExpired окончательно удаляет ссылки, срок действия которых истек к моменту now,
+	// вместе с переходами. Возвращает кол-во удаленных ссылок
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+	// LeaseIDBlock выделяет блок из size уникальных номеров и возвращает первый номер блока.
+	// Номера начинаются с 1, блоки не пересекаются между экземплярами сервиса, работающими с одним хранилищем
+	LeaseIDBlock(ctx context.Context, size uint64) (uint64, error)
+	// SaveClicks сохраняет переходы по ссылкам
+	SaveClicks(ctx context.Context, clicks []model.Click) error
+	// GetClickStats возвращает статистику переходов по ссылке: общее кол-во
+	// и кол-во по интервалам длины bucket начиная с from (только непустые интервалы)
+	GetClickStats(ctx context.Context, code string, from time.Time, bucket time.Duration) (model.ClickStats, error)
+	// UpdateShortener меняет адрес назначения ссылки s.Key.Code пользователя s.Data.User на s.Data.URL
+	// и дополняет историю изменений (при первом изменении в историю записывается и исходный адрес).
+	// Чужая ссылка не найдется, URL другой ссылки - ErrSetShortenerAlreadyExists с кодом этой ссылки,
+	// прежний адрес - ссылка без изменений
+	UpdateShortener(ctx context.Context, s model.Shortener) (model.Shortener, error)
+	// GetRevisions возвращает историю адресов ссылки по возрастанию номера изменения.
+	// Для неизменявшейся ссылки - только исходный адрес
+	GetRevisions(ctx context.Context, code string) ([]model.Revision, error)
+	// GetStatsReport возвращает статистику за период [from, to), границы выровнены по суткам UTC.
+	// Created - только непустые сутки, TopLinks - не более top ссылок по убыванию переходов.
+	// Результаты переходов (Redirects) хранилище не заполняет
+	GetStatsReport(ctx context.Context, from, to time.Time, top int) (model.StatsReport, error)
+	// SaveDeleteJob создает или заменяет задание удаления ссылок
+	SaveDeleteJob(ctx context.Context, job model.DeleteJob) error
+	// GetDeleteJob читает задание удаления ссылок, нет задания - ErrDeleteJobNotFound
+	GetDeleteJob(ctx context.Context, id string) (model.DeleteJob, error)
+	// GetPendingDeleteJobs возвращает невыполненные задания удаления в порядке создания
+	GetPendingDeleteJobs(ctx context.Context) ([]model.DeleteJob, error)
 	// Close закрывает соединение
 	Close()
 }
 
-// NewStore возвращает одну из сущестующих реализаций хранилища в зависимости от конфигурации сервиса
+// NewStore возвращает одну из сущестующих реализаций хранилища в зависимости от конфигурации сервиса.
+// При заданном CacheSize хранилище оборачивается кэшем чтения
 func NewStore(cfg config.Config) (Repository, error) {
+	store, err := newStore(cfg)
+	if err != nil || cfg.CacheSize <= 0 {
+		return store, err
+	}
+	return NewStoreCache(cfg, store), nil
+}
+
+// newStore - реализация хранилища по типу
+func newStore(cfg config.Config) (Repository, error) {
 	switch cfg.StoreType {
 	case config.StoreTypeFile:
 		if cfg.Filename != "" {
@@ -49,6 +107,14 @@ func NewStore(cfg config.Config) (Repository, error) {
 		if cfg.DBDsn != "" {
 			return NewStoreDB(cfg)
 		}
+	case config.StoreTypeSQLite:
+		if cfg.DBDsn != "" {
+			return NewStoreSQLite(cfg)
+		}
+	case config.StoreTypeKV:
+		if cfg.KVPath != "" {
+			return NewStoreKV(cfg)
+		}
 	}
 	return NewStoreVar(cfg)
 }
@@ -58,6 +124,17 @@ var (
 	ErrGetShortenerNotFound      = errors.New("data not found")
 	ErrSetShortenerAlreadyExists = errors.New("url already exists")
 	ErrGetShortenerGone          = errors.New("code is deleted")
+	ErrSetShortenerCodeConflict  = errors.New("code already taken")
+	// ErrGetShortenerExpired - срок действия ссылки истек (частный случай ErrGetShortenerGone)
+	ErrGetShortenerExpired = fmt.Errorf("%w: link expired", ErrGetShortenerGone)
+	// ErrGetShortenerExhausted - переходы по ссылке исчерпаны (частный случай ErrGetShortenerGone)
+	ErrGetShortenerExhausted = fmt.Errorf("%w: click limit reached", ErrGetShortenerGone)
+	// ErrShortenerNotDeleted - восстанавливаемая ссылка не удалена
+	ErrShortenerNotDeleted = errors.New("code is not deleted")
+	// ErrRestoreGracePassed - ссылка удалена слишком давно для восстановления (частный случай ErrGetShortenerGone)
+	ErrRestoreGracePassed = fmt.Errorf("%w: restore period passed", ErrGetShortenerGone)
+	// ErrDeleteJobNotFound - задания удаления нет
+	ErrDeleteJobNotFound = errors.New("delete job not found")
 )
 
 // newErrGetShortenerNotFound - подробная ошибка NotFound
@@ -65,29 +142,69 @@ func newErrGetShortenerNotFound(code string) error {
 	return fmt.Errorf("%w for code = %s", ErrGetShortenerNotFound, code)
 }
 
-// StoreVar - Реализация с хранением в переменной
+// checkShortener - ошибка перехода в момент now по ссылке в состоянии data. ok = false - ссылки нет
+func checkShortener(code string, data model.ShortenerData, ok bool, now time.Time) error {
+	switch {
+	case !ok:
+		return newErrGetShortenerNotFound(code)
+	case data.DelFlag:
+		return ErrGetShortenerGone
+	case data.Expired(now):
+		return ErrGetShortenerExpired
+	case data.Exhausted():
+		return ErrGetShortenerExhausted
+	}
+	return nil
+}
+
+// checkRestore проверяет, может ли пользователь user восстановить ссылку, удаленную не раньше deletedAfter.
+// ok = false - ссылки нет
+func checkRestore(code string, data model.ShortenerData, ok bool, user string, deletedAfter time.Time) error {
+	switch {
+	case !ok || data.User != user:
+		return newErrGetShortenerNotFound(code)
+	case !data.DelFlag:
+		return ErrShortenerNotDeleted
+	case data.DeletedAt.Before(deletedAfter):
+		return ErrRestoreGracePassed
+	}
+	return nil
+}
+
+// deleteStatus - результат удаления пользователем user ссылки в состоянии data. ok = false - ссылки нет
+func deleteStatus(data model.ShortenerData, ok bool, user string) model.DeleteStatus {
+	switch {
+	case !ok || data.User != user:
+		return model.DeleteStatusNotFound
+	case data.DelFlag:
+		return model.DeleteStatusAlreadyDeleted
+	}
+	return model.DeleteStatusDeleted
+}
+
+// StoreVar - Реализация с хранением в переменной.
+// Ссылки разбиты на сегменты, чтение по коду не блокирует остальные сегменты
 type StoreVar struct {
-	mux       *sync.Mutex
-	shortener map[model.ShortenerKey]model.ShortenerData
+	index  *memIndex
+	clicks *clickLog
+	jobs   *jobTable
 }
 
 // NewStoreVar - конструктор хранилища
 func NewStoreVar(cfg config.Config) (*StoreVar, error) {
 	return &StoreVar{
-		mux:       &sync.Mutex{},
-		shortener: make(map[model.ShortenerKey]model.ShortenerData),
+		index:  newMemIndex(),
+		clicks: newClickLog(),
+		jobs:   newJobTable(),
 	}, nil
 }
 
 // GetShortener читает короткую ссылку
 func (store *StoreVar) GetShortener(code string) (model.Shortener, error) {
-	store.mux.Lock()
-	defer store.mux.Unlock()
-
 	key := model.ShortenerKey{Code: code}
-	data, ok := store.shortener[key]
-	if !ok {
-		return model.Shortener{}, newErrGetShortenerNotFound(code)
+	data, ok := store.index.get(key)
+	if err := checkShortener(code, data, ok, time.Now()); err != nil {
+		return model.Shortener{}, err
 	}
 	return model.Shortener{
 		Key:  key,
@@ -95,39 +212,32 @@ func (store *StoreVar) GetShortener(code string) (model.Shortener, error) {
 	}, nil
 }
 
+// UseShortener учитывает переход по ссылке с ограничением кол-ва переходов
+func (store *StoreVar) UseShortener(_ context.Context, code string) (model.Shortener, error) {
+	return store.index.use(model.ShortenerKey{Code: code}, time.Now())
+}
+
 // SetShortener создает короткую ссылку
 func (store *StoreVar) SetShortener(_ context.Context, s model.Shortener) (model.Shortener, error) {
-	store.mux.Lock()
-	defer store.mux.Unlock()
-
-	// Проверка: уже существует
-	for oldKey, oldData := range store.shortener {
-		if oldData.URL == s.Data.URL {
-			return model.Shortener{
-				Key:  oldKey,
-				Data: oldData,
-			}, ErrSetShortenerAlreadyExists
-		}
+	row := store.index.set(model.ShortenerBatchRow{Shortener: s})
+	switch row.Status {
+	case model.ShortenerStatusExists:
+		return row.Shortener, ErrSetShortenerAlreadyExists
+	case model.ShortenerStatusCodeConflict:
+		return model.Shortener{}, ErrSetShortenerCodeConflict
 	}
 
-	// Запись в хранилище
-	store.shortener[s.Key] = s.Data
-
 	// Ответ
-	return s, nil
+	return row.Shortener, nil
 }
 
 // SetShortenerBatch создает короткую ссылку для набора данных
-func (store *StoreVar) SetShortenerBatch(_ context.Context, s []model.Shortener) ([]model.Shortener, error) {
-	var respSBatch []model.Shortener
-	for _, reqS := range s {
-		respS, err := store.SetShortener(context.Background(), reqS)
-		if err != nil {
-			return []model.Shortener{1: respS}, err
-		}
-		respSBatch = append(respSBatch, respS)
+func (store *StoreVar) SetShortenerBatch(_ context.Context, s []model.ShortenerBatchRow) ([]model.ShortenerBatchRow, error) {
+	resp := make([]model.ShortenerBatchRow, 0, len(s))
+	for _, reqRow := range s {
+		resp = append(resp, store.index.set(reqRow))
 	}
-	return respSBatch, nil
+	return resp, nil
 }
 
 // Ping
@@ -137,48 +247,96 @@ func (store *StoreVar) Ping() error {
 
 // GetShortenerBatch возвращает все ссылки, добавленные пользователем
 func (store *StoreVar) GetShortenerBatch(_ context.Context, userCode string) ([]model.Shortener, error) {
-	var resp []model.Shortener
-	for key, data := range store.shortener {
-		if data.User == userCode || userCode == "" {
-			resp = append(resp, model.Shortener{Key: key, Data: data})
-		}
-	}
-	return resp, nil
+	return store.index.byUser(userCode, time.Now()), nil
 }
 
 // DeleteShortenerBatch удаляет короткую ссылку
-func (store *StoreVar) DeleteShortenerBatch(_ context.Context, s []model.Shortener) error {
+func (store *StoreVar) DeleteShortenerBatch(_ context.Context, s []model.Shortener) ([]model.DeleteStatus, error) {
+	resp := make([]model.DeleteStatus, 0, len(s))
 	for _, s := range s {
-		store.shortener[s.Key] = model.ShortenerData{}
+		// Удалить может только владелец ссылки
+		resp = append(resp, store.index.markDeleted(s.Key, s.Data.User, time.Now()))
 	}
-	return nil
+	return resp, nil
+}
+
+// GetDeletedBatch возвращает удаленные ссылки пользователя
+func (store *StoreVar) GetDeletedBatch(_ context.Context, userCode string) ([]model.Shortener, error) {
+	return store.index.deletedByUser(userCode), nil
+}
+
+// RestoreShortener восстанавливает удаленную ссылку
+func (store *StoreVar) RestoreShortener(_ context.Context, s model.Shortener, deletedAfter time.Time) (model.Shortener, error) {
+	return store.index.restore(s.Key, s.Data.User, deletedAfter)
+}
+
+// PurgeDeleted окончательно удаляет ссылки, удаленные раньше before
+func (store *StoreVar) PurgeDeleted(_ context.Context, before time.Time) (int, error) {
+	purged := store.index.purgeDeleted(before)
+	store.clicks.remove(purged)
+	return len(purged), nil
 }
 
 // GetStats возвращает статистические данные
 func (store *StoreVar) GetStats(ctx context.Context) (model.Stats, error) {
-	var stats model.Stats
+	return store.index.stats(), nil
+}
 
-	// кол-во сокращенных ссылок
-	stats.URLs = len(store.shortener)
-
-	// кол-во пользователей
-	// *здесь не помешал бы бинарный поиск
-	users := make([]string, 0, 10)
-	for _, sh := range store.shortener {
-		exists := false
-		for _, us := range users {
-			if us == sh.User {
-				exists = true
-				break
-			}
-		}
-		if !exists {
-			users = append(users, sh.User)
-		}
-	}
-	stats.Users = len(users)
+// DeleteExpired окончательно удаляет истекшие ссылки
+func (store *StoreVar) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	purged := store.index.purgeExpired(now)
+	store.clicks.remove(purged)
+	return len(purged), nil
+}
 
-	return stats, nil
+// LeaseIDBlock выделяет блок номеров
+func (store *StoreVar) LeaseIDBlock(_ context.Context, size uint64) (uint64, error) {
+	return store.index.leaseIDs(size), nil
+}
+
+// SaveClicks сохраняет переходы
+func (store *StoreVar) SaveClicks(_ context.Context, clicks []model.Click) error {
+	store.clicks.add(clicks)
+	return nil
+}
+
+// GetClickStats возвращает статистику переходов
+func (store *StoreVar) GetClickStats(_ context.Context, code string, from time.Time, bucket time.Duration) (model.ClickStats, error) {
+	return store.clicks.stats(code, from, bucket), nil
+}
+
+// UpdateShortener меняет адрес назначения ссылки
+func (store *StoreVar) UpdateShortener(_ context.Context, s model.Shortener) (model.Shortener, error) {
+	resp, _, err := store.index.update(s.Key, s.Data.User, s.Data.URL, time.Now())
+	return resp, err
+}
+
+// GetRevisions возвращает историю адресов ссылки
+func (store *StoreVar) GetRevisions(_ context.Context, code string) ([]model.Revision, error) {
+	return store.index.history(model.ShortenerKey{Code: code})
+}
+
+// GetStatsReport возвращает статистику за период
+func (store *StoreVar) GetStatsReport(_ context.Context, from, to time.Time, top int) (model.StatsReport, error) {
+	report := store.index.report(from, to)
+	report.TopLinks = store.clicks.top(from, to, top)
+	return report, nil
+}
+
+// SaveDeleteJob создает или заменяет задание удаления
+func (store *StoreVar) SaveDeleteJob(_ context.Context, job model.DeleteJob) error {
+	store.jobs.save(job)
+	return nil
+}
+
+// GetDeleteJob читает задание удаления
+func (store *StoreVar) GetDeleteJob(_ context.Context, id string) (model.DeleteJob, error) {
+	return store.jobs.get(id)
+}
+
+// GetPendingDeleteJobs возвращает невыполненные задания удаления
+func (store *StoreVar) GetPendingDeleteJobs(_ context.Context) ([]model.DeleteJob, error) {
+	return store.jobs.pending(), nil
 }
 
 // Close закрывает соединение
@@ -186,56 +344,147 @@ func (store *StoreVar) Close() {
 
 }
 
-// StoreFile - Реализация с хранением в файле
+// StoreFile - Реализация с хранением в файле.
+// Ссылки читаются из индекса в памяти, mux упорядочивает запись в файл
 type StoreFile struct {
-	mux       *sync.Mutex
-	shortener map[model.ShortenerKey]model.ShortenerData
-	file      *os.File
-	writer    *bufio.Writer
+	mux      *sync.Mutex
+	index    *memIndex
+	filename string
+	file     *os.File
+	writer   *bufio.Writer
+	// lines - кол-во записей в файле (для принятия решения о сжатии)
+	lines int
+	done  chan struct{}
+	wg    sync.WaitGroup
+	// closeOnce - Close выполняется один раз
+	closeOnce sync.Once
+	// Переходы хранятся в отдельном файле со своей блокировкой
+	clicksMux  sync.Mutex
+	clicksFile *os.File
+	clicks     *clickLog
+	// Задания удаления также хранятся в отдельном файле
+	jobsMux  sync.Mutex
+	jobsFile *os.File
+	jobs     *jobTable
 }
 
 // FileJSON Структура JSON-файла для хранения
+// Запись с DelFlag = true и пустым URL - событие удаления ссылки (DeletedAt - время удаления),
+// запись с Restore = true - событие восстановления удаленной ссылки,
+// запись с Purge = true - событие окончательного удаления истекшей или удаленной ссылки,
+// запись с IDBlock без кода - выделены номера до IDBlock включительно,
+// запись с Revision - изменение адреса ссылки (CreatedAt - время изменения)
 type FileJSON struct {
-	Code string `json:"code"`
-	URL  string `json:"url"`
-	User string `json:"user"`
+	Code      string     `json:"code"`
+	URL       string     `json:"url,omitempty"`
+	User      string     `json:"user"`
+	DelFlag   bool       `json:"del_flag,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Restore   bool       `json:"restore,omitempty"`
+	Purge     bool       `json:"purge,omitempty"`
+	IDBlock   uint64     `json:"id_block,omitempty"`
+	Revision  int        `json:"revision,omitempty"`
+	// PasswordHash - хэш пароля ссылки
+	PasswordHash string `json:"password_hash,omitempty"`
+	// MaxClicks, ClicksLeft - ограничение и остаток переходов по ссылке
+	MaxClicks  int `json:"max_clicks,omitempty"`
+	ClicksLeft int `json:"clicks_left,omitempty"`
+}
+
+// newFileJSON - запись файла для ссылки
+func newFileJSON(s model.Shortener) FileJSON {
+	fileJSON := FileJSON{Code: s.Key.Code, URL: s.Data.URL, User: s.Data.User, DelFlag: s.Data.DelFlag, PasswordHash: s.Data.PasswordHash,
+		MaxClicks: s.Data.MaxClicks, ClicksLeft: s.Data.ClicksLeft}
+	if !s.Data.ExpiresAt.IsZero() {
+		fileJSON.ExpiresAt = &s.Data.ExpiresAt
+	}
+	if !s.Data.CreatedAt.IsZero() {
+		fileJSON.CreatedAt = &s.Data.CreatedAt
+	}
+	if !s.Data.DeletedAt.IsZero() {
+		fileJSON.DeletedAt = &s.Data.DeletedAt
+	}
+	return fileJSON
+}
+
+// newRevisionJSON - запись файла для изменения адреса ссылки
+func newRevisionJSON(key model.ShortenerKey, user string, rev model.Revision) FileJSON {
+	fileJSON := FileJSON{Code: key.Code, URL: rev.URL, User: user, Revision: rev.Revision}
+	if !rev.CreatedAt.IsZero() {
+		fileJSON.CreatedAt = &rev.CreatedAt
+	}
+	return fileJSON
 }
 
 // NewStoreFile - конструктор хранилища
 func NewStoreFile(cfg config.Config) (*StoreFile, error) {
-	file, err := os.OpenFile(cfg.Filename, os.O_RDWR|os.O_CREATE, 0666)
+	file, err := os.OpenFile(cfg.Filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
 	if err != nil {
 		return nil, err
 	}
 
-	// Наполнение мапы из файла
-	scanner := bufio.NewScanner(file)
-	var fileJSON FileJSON
-	shortener := map[model.ShortenerKey]model.ShortenerData{}
-	for scanner.Scan() {
-		if err := json.Unmarshal(scanner.Bytes(), &fileJSON); err == nil && fileJSON.Code != "" {
-			shortener[model.ShortenerKey{Code: fileJSON.Code}] =
-				model.ShortenerData{URL: fileJSON.URL, User: fileJSON.User}
+	// Наполнение индекса из файла
+	index, lines, err := replayFile(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	clicksFile, clicks, err := openClickFile(cfg.Filename)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	jobsFile, jobs, err := openJobFile(cfg.Filename)
+	if err != nil {
+		file.Close()
+		clicksFile.Close()
+		return nil, err
+	}
+
+	store := &StoreFile{
+		mux:      &sync.Mutex{},
+		index:    index,
+		filename: cfg.Filename,
+		file:     file,
+		writer:   bufio.NewWriter(file),
+		lines:    lines,
+		done:     make(chan struct{}),
+
+		clicksFile: clicksFile,
+		clicks:     clicks,
+		jobsFile:   jobsFile,
+		jobs:       jobs,
+	}
+
+	// Сжатие при старте, чтобы следующий запуск не читал лишнее.
+	// Время удаления, назначенное при загрузке, сохраняется сжатием
+	backfilled := index.backfillDeleted(time.Now())
+	if backfilled > 0 || store.needCompact() {
+		if err := store.compact(); err != nil {
+			store.file.Close()
+			store.clicksFile.Close()
+			store.jobsFile.Close()
+			return nil, err
 		}
 	}
 
-	return &StoreFile{
-		mux:       &sync.Mutex{},
-		shortener: shortener,
-		file:      file,
-		writer:    bufio.NewWriter(file),
-	}, nil
+	// Периодическое сжатие
+	if cfg.CompactInterval > 0 {
+		store.wg.Add(1)
+		go store.compactLoop(cfg.CompactInterval)
+	}
+
+	return store, nil
 }
 
 // GetShortener читает короткую ссылку
 func (store *StoreFile) GetShortener(code string) (model.Shortener, error) {
-	store.mux.Lock()
-	defer store.mux.Unlock()
-
 	Key := model.ShortenerKey{Code: code}
-	data, ok := store.shortener[Key]
-	if !ok {
-		return model.Shortener{}, newErrGetShortenerNotFound(code)
+	data, ok := store.index.get(Key)
+	if err := checkShortener(code, data, ok, time.Now()); err != nil {
+		return model.Shortener{}, err
 	}
 	return model.Shortener{
 		Key:  Key,
@@ -243,59 +492,112 @@ func (store *StoreFile) GetShortener(code string) (model.Shortener, error) {
 	}, nil
 }
 
+// UseShortener учитывает переход по ссылке с ограничением кол-ва переходов.
+// Остаток переходов записывается в файл полной записью ссылки до изменения индекса
+func (store *StoreFile) UseShortener(_ context.Context, code string) (model.Shortener, error) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	s, err := store.GetShortener(code)
+	if err != nil || s.Data.MaxClicks == 0 {
+		return s, err
+	}
+	s.Data.ClicksLeft--
+	if err := store.writeJSON(newFileJSON(s)); err != nil {
+		return model.Shortener{}, err
+	}
+	store.index.replace(s.Key, s.Data)
+
+	if store.needCompact() {
+		return s, store.compact()
+	}
+	return s, nil
+}
+
 // SetShortener создает короткую ссылку
 func (store *StoreFile) SetShortener(_ context.Context, s model.Shortener) (model.Shortener, error) {
 	store.mux.Lock()
 	defer store.mux.Unlock()
 
-	// Проверка: уже существует
-	for oldKey, oldData := range store.shortener {
-		if oldData.URL == s.Data.URL {
-			return model.Shortener{
-				Key:  oldKey,
-				Data: oldData,
-			}, ErrSetShortenerAlreadyExists
-		}
+	// Запись в хранилище
+	row := store.index.set(model.ShortenerBatchRow{Shortener: s})
+	switch row.Status {
+	case model.ShortenerStatusExists:
+		return row.Shortener, ErrSetShortenerAlreadyExists
+	case model.ShortenerStatusCodeConflict:
+		return model.Shortener{}, ErrSetShortenerCodeConflict
 	}
+	respS := row.Shortener
 
-	// Запись в хранилище
-	store.shortener[s.Key] = s.Data
-	respS := s
+	err := store.writeJSON(newFileJSON(respS))
+	if err != nil {
+		return respS, err
+	}
+
+	return respS, nil
 
-	fileJSON := FileJSON{Code: s.Key.Code, URL: s.Data.URL, User: s.Data.User}
+}
+
+// writeJSON дописывает запись в файл хранилища и сбрасывает ее на диск
+func (store *StoreFile) writeJSON(fileJSON FileJSON) error {
+	if err := store.appendJSON(fileJSON); err != nil {
+		return err
+	}
+	return store.sync()
+}
+
+// appendJSON дописывает запись в буфер файла хранилища
+func (store *StoreFile) appendJSON(fileJSON FileJSON) error {
 	data, err := json.Marshal(&fileJSON)
 	if err != nil {
-		return respS, err
+		return err
 	}
 
 	// записываем в буфер
 	if _, err := store.writer.Write(data); err != nil {
-		return respS, err
+		return err
 	}
 
 	// добавляем перенос строки
 	if err := store.writer.WriteByte('\n'); err != nil {
-		return respS, err
+		return err
 	}
+	store.lines++
 
-	// записываем буфер в файл
-	store.writer.Flush()
-
-	return respS, nil
+	return nil
+}
 
+// sync записывает буфер в файл и сбрасывает файл на диск
+func (store *StoreFile) sync() error {
+	if err := store.writer.Flush(); err != nil {
+		return err
+	}
+	return store.file.Sync()
 }
 
 // SetShortenerBatch создает короткую ссылку для набора данных
-func (store *StoreFile) SetShortenerBatch(_ context.Context, s []model.Shortener) ([]model.Shortener, error) {
-	var respSBatch []model.Shortener
-	for _, reqS := range s {
-		respS, err := store.SetShortener(context.Background(), reqS)
-		if err != nil {
-			return []model.Shortener{1: respS}, err
+func (store *StoreFile) SetShortenerBatch(_ context.Context, s []model.ShortenerBatchRow) ([]model.ShortenerBatchRow, error) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	resp := make([]model.ShortenerBatchRow, 0, len(s))
+	for _, reqRow := range s {
+		row := store.index.set(reqRow)
+		if row.Status == model.ShortenerStatusCreated {
+			reqS := row.Shortener
+			err := store.appendJSON(newFileJSON(reqS))
+			if err != nil {
+				return nil, err
+			}
 		}
-		respSBatch = append(respSBatch, respS)
+		resp = append(resp, row)
 	}
-	return respSBatch, nil
+
+	// Один сброс на диск на весь пакет
+	if err := store.sync(); err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
 // Ping
@@ -305,51 +607,228 @@ func (store *StoreFile) Ping() error {
 
 // GetShortenerBatch возвращает все ссылки, добавленные пользователем
 func (store *StoreFile) GetShortenerBatch(_ context.Context, userCode string) ([]model.Shortener, error) {
-	var resp []model.Shortener
-	for key, data := range store.shortener {
-		if data.User == userCode || userCode == "" {
-			resp = append(resp, model.Shortener{Key: key, Data: data})
+	return store.index.byUser(userCode, time.Now()), nil
+}
+
+// DeleteShortenerBatch удаляет короткую ссылку
+func (store *StoreFile) DeleteShortenerBatch(_ context.Context, s []model.Shortener) ([]model.DeleteStatus, error) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	resp := make([]model.DeleteStatus, 0, len(s))
+	for _, s := range s {
+		// Удалить может только владелец ссылки
+		data, ok := store.index.get(s.Key)
+		status := deleteStatus(data, ok, s.Data.User)
+		resp = append(resp, status)
+		if status != model.DeleteStatusDeleted {
+			continue
+		}
+
+		// Событие удаления записывается в файл до изменения индекса
+		now := time.Now()
+		err := store.writeJSON(FileJSON{Code: s.Key.Code, User: s.Data.User, DelFlag: true, DeletedAt: &now})
+		if err != nil {
+			return nil, err
 		}
+
+		store.index.markDeleted(s.Key, s.Data.User, now)
+	}
+
+	// Сжатие при накоплении событий удаления
+	if store.needCompact() {
+		return resp, store.compact()
 	}
 	return resp, nil
 }
 
-// DeleteShortenerBatch удаляет короткую ссылку
-func (store *StoreFile) DeleteShortenerBatch(_ context.Context, s []model.Shortener) error {
-	return nil
+// GetDeletedBatch возвращает удаленные ссылки пользователя
+func (store *StoreFile) GetDeletedBatch(_ context.Context, userCode string) ([]model.Shortener, error) {
+	return store.index.deletedByUser(userCode), nil
+}
+
+// RestoreShortener восстанавливает удаленную ссылку.
+// Событие восстановления записывается в файл после проверки под блокировкой записи
+func (store *StoreFile) RestoreShortener(_ context.Context, s model.Shortener, deletedAfter time.Time) (model.Shortener, error) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	resp, err := store.index.restore(s.Key, s.Data.User, deletedAfter)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	if err := store.writeJSON(FileJSON{Code: s.Key.Code, User: s.Data.User, Restore: true}); err != nil {
+		return model.Shortener{}, err
+	}
+	return resp, nil
+}
+
+// PurgeDeleted окончательно удаляет ссылки, удаленные раньше before.
+// В файл дописываются события удаления, сами записи ссылок убирает сжатие
+func (store *StoreFile) PurgeDeleted(_ context.Context, before time.Time) (int, error) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	return store.appendPurged(store.index.purgeDeleted(before))
+}
+
+// appendPurged дописывает в файл события окончательного удаления ссылок. Вызывается под блокировкой.
+// Переходы удаляются раньше: при сбое между записями ссылка останется без переходов, но не передаст их новой
+func (store *StoreFile) appendPurged(purged []model.Shortener) (int, error) {
+	if len(purged) == 0 {
+		return 0, nil
+	}
+	if err := store.purgeClicks(purged); err != nil {
+		return 0, err
+	}
+	for _, s := range purged {
+		if err := store.appendJSON(FileJSON{Code: s.Key.Code, Purge: true}); err != nil {
+			return 0, err
+		}
+	}
+	if err := store.sync(); err != nil {
+		return 0, err
+	}
+
+	if store.needCompact() {
+		return len(purged), store.compact()
+	}
+	return len(purged), nil
 }
 
 // GetStats возвращает статистические данные
 func (store *StoreFile) GetStats(ctx context.Context) (model.Stats, error) {
-	var stats model.Stats
+	return store.index.stats(), nil
+}
 
-	// кол-во сокращенных ссылок
-	stats.URLs = len(store.shortener)
-
-	// кол-во пользователей
-	// *здесь не помешал бы бинарный поиск
-	users := make([]string, 0, 10)
-	for _, sh := range store.shortener {
-		exists := false
-		for _, us := range users {
-			if us == sh.User {
-				exists = true
-				break
-			}
-		}
-		if !exists {
-			users = append(users, sh.User)
+// DeleteExpired окончательно удаляет истекшие ссылки.
+// В файл дописываются события удаления, сами записи ссылок убирает сжатие
+func (store *StoreFile) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	return store.appendPurged(store.index.purgeExpired(now))
+}
+
+// LeaseIDBlock выделяет блок номеров. Граница блока записывается в файл до выдачи номеров
+func (store *StoreFile) LeaseIDBlock(_ context.Context, size uint64) (uint64, error) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	start := store.index.leaseIDs(size)
+	if err := store.writeJSON(FileJSON{IDBlock: start + size - 1}); err != nil {
+		return 0, err
+	}
+	return start, nil
+}
+
+// SaveClicks сохраняет переходы в файл переходов
+func (store *StoreFile) SaveClicks(_ context.Context, clicks []model.Click) error {
+	store.clicksMux.Lock()
+	defer store.clicksMux.Unlock()
+
+	if err := writeClicks(store.clicksFile, clicks); err != nil {
+		return err
+	}
+	store.clicks.add(clicks)
+	return nil
+}
+
+// purgeClicks удаляет переходы по ссылкам и дописывает в файл переходов события их удаления
+func (store *StoreFile) purgeClicks(purged []model.Shortener) error {
+	store.clicksMux.Lock()
+	defer store.clicksMux.Unlock()
+
+	if err := writeClicksPurge(store.clicksFile, purged); err != nil {
+		return err
+	}
+	store.clicks.remove(purged)
+	return nil
+}
+
+// GetClickStats возвращает статистику переходов
+func (store *StoreFile) GetClickStats(_ context.Context, code string, from time.Time, bucket time.Duration) (model.ClickStats, error) {
+	return store.clicks.stats(code, from, bucket), nil
+}
+
+// UpdateShortener меняет адрес назначения ссылки. Записи истории дописываются в файл
+func (store *StoreFile) UpdateShortener(_ context.Context, s model.Shortener) (model.Shortener, error) {
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
+	resp, revs, err := store.index.update(s.Key, s.Data.User, s.Data.URL, time.Now())
+	if err != nil || len(revs) == 0 {
+		return resp, err
+	}
+	for _, rev := range revs {
+		if err := store.appendJSON(newRevisionJSON(s.Key, s.Data.User, rev)); err != nil {
+			return model.Shortener{}, err
 		}
 	}
-	stats.Users = len(users)
+	if err := store.sync(); err != nil {
+		return model.Shortener{}, err
+	}
+	return resp, nil
+}
 
-	return stats, nil
+// GetRevisions возвращает историю адресов ссылки
+func (store *StoreFile) GetRevisions(_ context.Context, code string) ([]model.Revision, error) {
+	return store.index.history(model.ShortenerKey{Code: code})
 }
 
-// Close закрывает соединение
+// GetStatsReport возвращает статистику за период
+func (store *StoreFile) GetStatsReport(_ context.Context, from, to time.Time, top int) (model.StatsReport, error) {
+	report := store.index.report(from, to)
+	report.TopLinks = store.clicks.top(from, to, top)
+	return report, nil
+}
+
+// SaveDeleteJob создает или заменяет задание удаления. Задание записывается в файл заданий
+func (store *StoreFile) SaveDeleteJob(_ context.Context, job model.DeleteJob) error {
+	store.jobsMux.Lock()
+	defer store.jobsMux.Unlock()
+
+	if err := writeJob(store.jobsFile, job); err != nil {
+		return err
+	}
+	store.jobs.save(job)
+	return nil
+}
+
+// GetDeleteJob читает задание удаления
+func (store *StoreFile) GetDeleteJob(_ context.Context, id string) (model.DeleteJob, error) {
+	return store.jobs.get(id)
+}
+
+// GetPendingDeleteJobs возвращает невыполненные задания удаления
+func (store *StoreFile) GetPendingDeleteJobs(_ context.Context) ([]model.DeleteJob, error) {
+	return store.jobs.pending(), nil
+}
+
+// Close закрывает файлы хранилища. Повторный вызов ничего не делает
 func (store *StoreFile) Close() {
+	store.closeOnce.Do(store.close)
+}
+
+// close останавливает периодическое сжатие и закрывает файлы
+func (store *StoreFile) close() {
+	// Остановка периодического сжатия
+	close(store.done)
+	store.wg.Wait()
+
+	store.mux.Lock()
+	defer store.mux.Unlock()
+
 	store.writer.Flush()
 	store.file.Close()
+
+	store.clicksMux.Lock()
+	defer store.clicksMux.Unlock()
+	store.clicksFile.Close()
+
+	store.jobsMux.Lock()
+	defer store.jobsMux.Unlock()
+	store.jobsFile.Close()
 }
 
 // StoreDB - Реализация с хранением в базе данных
@@ -364,15 +843,14 @@ func NewStoreDB(cfg config.Config) (*StoreDB, error) {
 		return nil, err
 	}
 
-	// Создаем таблицу
-	_, err = db.Exec(
-		"CREATE TABLE IF NOT EXISTS shortener (" +
-			" code VARCHAR (10) PRIMARY KEY," +
-			" url VARCHAR (255) NOT NULL," +
-			" uuid VARCHAR (10) DEFAULT NULL," +
-			" del_flag BOOLEAN DEFAULT FALSE" +
-			" );")
+	// Применяем миграции схемы
+	migrator, err := migrate.New(db, migrate.Postgres)
 	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err = migrator.Up(context.Background()); err != nil {
+		db.Close()
 		return nil, err
 	}
 
@@ -383,96 +861,206 @@ func NewStoreDB(cfg config.Config) (*StoreDB, error) {
 
 // GetShortener читает короткую ссылку
 func (store *StoreDB) GetShortener(code string) (model.Shortener, error) {
-	var url string
-	var delFlag bool
 	row := store.database.QueryRow(
-		"SELECT url, del_flag FROM shortener"+
+		"SELECT "+getShortenerColumns+" FROM shortener"+
 			" WHERE code = $1",
 		code)
-	err := row.Scan(&url, &delFlag)
+	data, ok, err := scanShortener(row)
 	if err != nil {
 		return model.Shortener{}, err
 	}
-	if delFlag {
-		return model.Shortener{}, ErrGetShortenerGone
+	if err := checkShortener(code, data, ok, time.Now()); err != nil {
+		return model.Shortener{}, err
+	}
+
+	return model.Shortener{
+		Key:  model.ShortenerKey{Code: code},
+		Data: data,
+	}, nil
+}
+
+// getShortenerColumns - поля ссылки для scanShortener
+const getShortenerColumns = "url, uuid, del_flag, expires_at, password_hash, max_clicks, clicks_left"
+
+// scanShortener читает данные ссылки из строки с полями getShortenerColumns. ok = false - строки нет
+func scanShortener(row *sql.Row) (model.ShortenerData, bool, error) {
+	var data model.ShortenerData
+	var user sql.NullString
+	var expiresAt sql.NullTime
+	err := row.Scan(&data.URL, &user, &data.DelFlag, &expiresAt, &data.PasswordHash, &data.MaxClicks, &data.ClicksLeft)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ShortenerData{}, false, nil
+	}
+	if err != nil {
+		return model.ShortenerData{}, false, err
 	}
+	data.User = user.String
+	data.ExpiresAt = expiresAt.Time
+	return data, true, nil
+}
 
+// useShortenerQuery - уменьшение остатка переходов доступной ссылки с ограничением.
+// Обновление блокирует строку, поэтому параллельные переходы не расходуют больше остатка
+const useShortenerQuery = "UPDATE shortener SET clicks_left = clicks_left - 1" +
+	" WHERE code = $1 AND max_clicks > 0 AND clicks_left > 0 AND NOT del_flag" +
+	" AND (expires_at IS NULL OR expires_at > now())" +
+	" RETURNING " + getShortenerColumns
+
+// UseShortener учитывает переход по ссылке с ограничением кол-ва переходов.
+// Ссылка не изменена (нет ограничения, исчерпана, удалена) - результат чтения ссылки
+func (store *StoreDB) UseShortener(ctx context.Context, code string) (model.Shortener, error) {
+	data, ok, err := scanShortener(store.database.QueryRowContext(ctx, useShortenerQuery, code))
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	if !ok {
+		return store.GetShortener(code)
+	}
 	return model.Shortener{
 		Key:  model.ShortenerKey{Code: code},
-		Data: model.ShortenerData{URL: url},
+		Data: data,
 	}, nil
 }
 
+// nullTime - NULL для бессрочной ссылки
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// upsertShortenerQuery - вставка ссылки или получение кода существующей ссылки с тем же URL.
+// Пустое обновление нужно, чтобы RETURNING вернул конфликтующую строку; xmax = 0 - строка вставлена
+const upsertShortenerQuery = "INSERT INTO shortener (code, url, uuid, expires_at, created_at, password_hash, max_clicks, clicks_left)" +
+	" VALUES ($1, $2, $3, $4, COALESCE($5, now()), $6, $7, $8)" +
+	" ON CONFLICT (url) DO UPDATE SET url = EXCLUDED.url" +
+	" RETURNING code, (xmax = 0) AS inserted"
+
+// isCodeConflict - нарушение первичного ключа (код уже занят другой ссылкой)
+func isCodeConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		pgErr.Code == pgUniqueViolation &&
+		pgErr.ConstraintName == "shortener_pkey"
+}
+
+// isURLConflict - нарушение уникальности URL (URL уже сокращен другой ссылкой)
+func isURLConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		pgErr.Code == pgUniqueViolation &&
+		pgErr.ConstraintName == "shortener_url_key"
+}
+
+// pgUniqueViolation - код ошибки PostgreSQL unique_violation
+const pgUniqueViolation = "23505"
+
 // SetShortener создает короткую ссылку
 func (store *StoreDB) SetShortener(ctx context.Context, s model.Shortener) (model.Shortener, error) {
-	// Проверка: уже существует
-	var oldCode string
-	row := store.database.QueryRowContext(ctx,
-		"SELECT code FROM shortener"+
-			" WHERE url = $1",
-		s.Data.URL)
-	err := row.Scan(&oldCode)
-	if err == nil { // как ловить именно пустой результат, а не все ошибки БД? Ошибка нетипизирована error(*errors.errorString) *{s: "sql: no rows in result set"}
+	var code string
+	var inserted bool
+	row := store.database.QueryRowContext(ctx, upsertShortenerQuery,
+		s.Key.Code, s.Data.URL, s.Data.User, nullTime(s.Data.ExpiresAt), nullTime(s.Data.CreatedAt), s.Data.PasswordHash,
+		s.Data.MaxClicks, s.Data.ClicksLeft)
+	if err := row.Scan(&code, &inserted); err != nil {
+		if isCodeConflict(err) {
+			return model.Shortener{}, ErrSetShortenerCodeConflict
+		}
+		return model.Shortener{}, err
+	}
+	if !inserted {
 		return model.Shortener{
-			Key:  model.ShortenerKey{Code: oldCode},
+			Key:  model.ShortenerKey{Code: code},
 			Data: model.ShortenerData{URL: s.Data.URL},
 		}, ErrSetShortenerAlreadyExists
 	}
-
-	query := "INSERT INTO shortener (code, url, uuid)" +
-		" VALUES ($1, $2, $3)" +
-		" ON CONFLICT (code) DO NOTHING"
-	_, err = store.database.ExecContext(ctx, query, // не понял как вернуть отсюда конфликтующую строку. Returning при конфликте возвращает пустоту
-		s.Key.Code, s.Data.URL, s.Data.User)
-	if err != nil {
-		return model.Shortener{}, err
-	}
-
 	return s, nil
 }
 
+// setShortenerBatchQuery - пакетная вставка одним запросом.
+// Из повторяющихся в пакете URL вставляется первый, конфликты (URL или код) пропускаются.
+// Для каждой позиции возвращается вставленный либо существующий код; пустой код - код занят другой ссылкой
+const setShortenerBatchQuery = "WITH req AS (" +
+	"   SELECT * FROM unnest($1::int[], $2::text[], $3::text[], $4::text[], $5::timestamptz[], $6::timestamptz[], $7::text[]," +
+	"     $8::int[], $9::int[])" +
+	"     AS r(ord, code, url, uuid, expires_at, created_at, password_hash, max_clicks, clicks_left)" +
+	" ), ins AS (" +
+	"   INSERT INTO shortener (code, url, uuid, expires_at, created_at, password_hash, max_clicks, clicks_left)" +
+	"   SELECT DISTINCT ON (url) code, url, uuid, expires_at, COALESCE(created_at, now()), password_hash, max_clicks, clicks_left" +
+	"   FROM req ORDER BY url, ord" +
+	"   ON CONFLICT DO NOTHING" +
+	"   RETURNING code, url" +
+	" )" +
+	" SELECT req.ord, COALESCE(ins.code, ex.code, ''), COALESCE(ins.code = req.code, FALSE)" +
+	" FROM req" +
+	" LEFT JOIN ins ON ins.url = req.url" +
+	" LEFT JOIN shortener ex ON ex.url = req.url" +
+	" ORDER BY req.ord"
+
 // SetShortenerBatch создает короткую ссылку для набора данных
-func (store *StoreDB) SetShortenerBatch(ctx context.Context, s []model.Shortener) ([]model.Shortener, error) {
+func (store *StoreDB) SetShortenerBatch(ctx context.Context, s []model.ShortenerBatchRow) ([]model.ShortenerBatchRow, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	ords := make([]int32, len(s))
+	codes := make([]string, len(s))
+	urls := make([]string, len(s))
+	users := make([]string, len(s))
+	expires := make([]*time.Time, len(s))
+	created := make([]*time.Time, len(s))
+	passwords := make([]string, len(s))
+	maxClicks := make([]int32, len(s))
+	clicksLeft := make([]int32, len(s))
+	for i, reqRow := range s {
+		ords[i] = int32(i)
+		codes[i] = reqRow.Shortener.Key.Code
+		urls[i] = reqRow.Shortener.Data.URL
+		users[i] = reqRow.Shortener.Data.User
+		expires[i] = nullTime(reqRow.Shortener.Data.ExpiresAt)
+		created[i] = nullTime(reqRow.Shortener.Data.CreatedAt)
+		passwords[i] = reqRow.Shortener.Data.PasswordHash
+		maxClicks[i] = int32(reqRow.Shortener.Data.MaxClicks)
+		clicksLeft[i] = int32(reqRow.Shortener.Data.ClicksLeft)
+	}
 
-	tx, err := store.database.Begin()
+	rows, err := store.database.QueryContext(ctx, setShortenerBatchQuery, ords, codes, urls, users, expires, created, passwords,
+		maxClicks, clicksLeft)
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback()
+	defer rows.Close()
 
-	var respSBatch []model.Shortener
-	for _, reqS := range s {
-		// Проверка: уже существует
-		var oldCode string
-		row := store.database.QueryRowContext(ctx,
-			"SELECT code FROM shortener"+
-				" WHERE url = $1"+
-				" FOR UPDATE",
-			reqS.Data.URL)
-		err := row.Scan(&oldCode)
-		if err == nil {
-			return []model.Shortener{1: {Key: model.ShortenerKey{Code: oldCode},
-					Data: model.ShortenerData{URL: reqS.Data.URL}}},
-				ErrSetShortenerAlreadyExists
+	resp := make([]model.ShortenerBatchRow, len(s))
+	for rows.Next() {
+		var ord int
+		var code string
+		var created bool
+		if err := rows.Scan(&ord, &code, &created); err != nil {
+			return nil, err
 		}
 
-		// Запись отдельной позиции
-		_, err = tx.ExecContext(ctx,
-			"INSERT INTO shortener AS t (code, url, uuid)"+
-				" VALUES ($1, $2, $3)"+
-				" ON CONFLICT (code) DO NOTHING",
-			reqS.Key.Code, reqS.Data.URL, reqS.Data.User)
-		if err != nil {
-			return nil, err
+		row := s[ord]
+		switch {
+		case created:
+			row.Status = model.ShortenerStatusCreated
+		case code != "":
+			row.Status = model.ShortenerStatusExists
+			row.Shortener = model.Shortener{
+				Key:  model.ShortenerKey{Code: code},
+				Data: model.ShortenerData{URL: s[ord].Shortener.Data.URL},
+			}
+		default:
+			row.Status = model.ShortenerStatusCodeConflict
 		}
-		respSBatch = append(respSBatch, reqS)
+		resp[ord] = row
 	}
-
-	err = tx.Commit()
-	if err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	return respSBatch, nil
+
+	return resp, nil
 }
 
 // Ping
@@ -485,9 +1073,10 @@ func (store *StoreDB) GetShortenerBatch(ctx context.Context, userCode string) ([
 	var resp []model.Shortener
 
 	rows, err := store.database.QueryContext(ctx,
-		"SELECT code, url FROM shortener"+
+		"SELECT code, url, expires_at FROM shortener"+
 			" WHERE uuid = $1"+ // как сделать опциональное условие
-			" AND del_flag = FALSE",
+			" AND del_flag = FALSE"+
+			" AND (expires_at IS NULL OR expires_at > now())",
 		userCode)
 	if err != nil {
 		return nil, err
@@ -495,10 +1084,12 @@ func (store *StoreDB) GetShortenerBatch(ctx context.Context, userCode string) ([
 	defer rows.Close()
 	for rows.Next() {
 		var respRow model.Shortener
-		err := rows.Scan(&respRow.Key.Code, &respRow.Data.URL)
+		var expiresAt sql.NullTime
+		err := rows.Scan(&respRow.Key.Code, &respRow.Data.URL, &expiresAt)
 		if err != nil {
 			return nil, err
 		}
+		respRow.Data.ExpiresAt = expiresAt.Time
 		resp = append(resp, respRow)
 	}
 	if err := rows.Err(); err != nil {
@@ -509,8 +1100,12 @@ func (store *StoreDB) GetShortenerBatch(ctx context.Context, userCode string) ([
 
 }
 
-// DeleteShortenerBatch удаляет короткую ссылку
-func (store *StoreDB) DeleteShortenerBatch(ctx context.Context, s []model.Shortener) error {
+// DeleteShortenerBatch удаляет короткую ссылку.
+// Результат определяется по состоянию ссылок до удаления в том же запросе
+func (store *StoreDB) DeleteShortenerBatch(ctx context.Context, s []model.Shortener) ([]model.DeleteStatus, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
 
 	var values []string
 	var args []any
@@ -522,17 +1117,136 @@ func (store *StoreDB) DeleteShortenerBatch(ctx context.Context, s []model.Shorte
 		args = append(args, s.Key.Code, s.Data.User)
 	}
 
-	query := "UPDATE shortener AS s" +
-		" SET del_flag = TRUE" +
-		" FROM (values " +
+	query := "WITH k(code, uuid) AS (VALUES " +
 		strings.Join(values, ",") +
-		" ) AS k(code, uuid) " +
-		" WHERE s.code = k.code" +
-		"   AND s.uuid = k.uuid"
+		" ), upd AS (" +
+		"   UPDATE shortener AS s" +
+		"   SET del_flag = TRUE, deleted_at = now()" +
+		"   FROM k" +
+		"   WHERE s.code = k.code" +
+		"     AND s.uuid = k.uuid" +
+		"     AND s.del_flag = FALSE" +
+		"   RETURNING s.code)" +
+		" SELECT k.code, k.uuid, upd.code IS NOT NULL, s.code IS NOT NULL" +
+		" FROM k" +
+		" LEFT JOIN upd ON upd.code = k.code" +
+		" LEFT JOIN shortener AS s ON s.code = k.code AND s.uuid = k.uuid"
+
+	rows, err := store.database.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	// Результат по паре (код, пользователь)
+	statuses := make(map[[2]string]model.DeleteStatus, len(s))
+	for rows.Next() {
+		var key [2]string
+		var deleted, found bool
+		if err := rows.Scan(&key[0], &key[1], &deleted, &found); err != nil {
+			return nil, err
+		}
+		switch {
+		case deleted:
+			statuses[key] = model.DeleteStatusDeleted
+		case found:
+			statuses[key] = model.DeleteStatusAlreadyDeleted
+		default:
+			statuses[key] = model.DeleteStatusNotFound
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	_, err := store.database.ExecContext(ctx, query, args...)
+	resp := make([]model.DeleteStatus, 0, len(s))
+	for _, s := range s {
+		resp = append(resp, statuses[[2]string{s.Key.Code, s.Data.User}])
+	}
+	return resp, nil
+}
 
-	return err
+// GetDeletedBatch возвращает удаленные ссылки пользователя
+func (store *StoreDB) GetDeletedBatch(ctx context.Context, userCode string) ([]model.Shortener, error) {
+	var resp []model.Shortener
+
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT code, url, expires_at, created_at, deleted_at FROM shortener"+
+			" WHERE uuid = $1 AND del_flag",
+		userCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code string
+		var expiresAt, createdAt, deletedAt sql.NullTime
+		data := model.ShortenerData{User: userCode, DelFlag: true}
+		if err := rows.Scan(&code, &data.URL, &expiresAt, &createdAt, &deletedAt); err != nil {
+			return nil, err
+		}
+		data.ExpiresAt, data.CreatedAt, data.DeletedAt = expiresAt.Time, createdAt.Time, deletedAt.Time
+		resp = append(resp, model.Shortener{Key: model.ShortenerKey{Code: code}, Data: data})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RestoreShortener восстанавливает удаленную ссылку. Строка блокируется на время проверки
+func (store *StoreDB) RestoreShortener(ctx context.Context, s model.Shortener, deletedAfter time.Time) (model.Shortener, error) {
+	tx, err := store.database.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	defer tx.Rollback()
+
+	var data model.ShortenerData
+	var user sql.NullString
+	var expiresAt, createdAt, deletedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT url, uuid, del_flag, expires_at, created_at, deleted_at FROM shortener"+
+			" WHERE code = $1 FOR UPDATE",
+		s.Key.Code).Scan(&data.URL, &user, &data.DelFlag, &expiresAt, &createdAt, &deletedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return model.Shortener{}, err
+	}
+	data.User, data.ExpiresAt, data.CreatedAt, data.DeletedAt = user.String, expiresAt.Time, createdAt.Time, deletedAt.Time
+	if err := checkRestore(s.Key.Code, data, err == nil, s.Data.User, deletedAfter); err != nil {
+		return model.Shortener{}, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE shortener SET del_flag = FALSE, deleted_at = NULL WHERE code = $1", s.Key.Code)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Shortener{}, err
+	}
+
+	data.DelFlag, data.DeletedAt = false, time.Time{}
+	return model.Shortener{Key: s.Key, Data: data}, nil
+}
+
+// PurgeDeleted окончательно удаляет ссылки, удаленные раньше before
+func (store *StoreDB) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	return store.purge(ctx, "del_flag AND deleted_at < $1", before)
+}
+
+// purge окончательно удаляет ссылки по условию cond вместе с переходами одним запросом.
+// История удаляется каскадно
+func (store *StoreDB) purge(ctx context.Context, cond string, arg any) (int, error) {
+	var n int
+	row := store.database.QueryRowContext(ctx,
+		"WITH purged AS (DELETE FROM shortener WHERE "+cond+" RETURNING code),"+
+			" purged_clicks AS (DELETE FROM clicks WHERE code IN (SELECT code FROM purged))"+
+			" SELECT count(*) FROM purged", arg)
+	if err := row.Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
 // GetStats возвращает статистические данные
@@ -540,24 +1254,339 @@ func (store *StoreDB) GetStats(ctx context.Context) (model.Stats, error) {
 	var stats model.Stats
 
 	row := store.database.QueryRowContext(ctx,
-		"SELECT count(code)"+
-			"FROM shortener"+
-			"WHERE del_flag = FALSE"+
-			"LIMIT 1")
-	if err := row.Scan(stats.URLs); err != nil {
+		"SELECT count(code), count(DISTINCT uuid)"+
+			" FROM shortener"+
+			" WHERE del_flag = FALSE")
+	if err := row.Scan(&stats.URLs, &stats.Users); err != nil {
 		return model.Stats{}, err
 	}
 
-	row = store.database.QueryRowContext(ctx,
-		"SELECT count(sh.uuid)"+
-			"FROM (SELECT DISTINCT uuid FROM shortener) sh")
-	if err := row.Scan(stats.Users); err != nil {
-		return model.Stats{}, err
+	return stats, nil
+}
+
+// DeleteExpired окончательно удаляет истекшие ссылки
+func (store *StoreDB) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	return store.purge(ctx, "expires_at <= $1", now)
+}
+
+// SaveClicks сохраняет переходы одним запросом
+func (store *StoreDB) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	codes := make([]string, len(clicks))
+	times := make([]time.Time, len(clicks))
+	referrers := make([]string, len(clicks))
+	userAgents := make([]string, len(clicks))
+	ipHashes := make([]string, len(clicks))
+	for i, click := range clicks {
+		codes[i] = click.Code
+		times[i] = click.Time
+		referrers[i] = click.Referrer
+		userAgents[i] = click.UserAgent
+		ipHashes[i] = click.IPHash
+	}
+
+	_, err := store.database.ExecContext(ctx,
+		"INSERT INTO clicks (code, clicked_at, referrer, user_agent, ip_hash)"+
+			" SELECT * FROM unnest($1::text[], $2::timestamptz[], $3::text[], $4::text[], $5::text[])",
+		codes, times, referrers, userAgents, ipHashes)
+	return err
+}
+
+// GetClickStats возвращает статистику переходов. Интервалы группируются в базе
+func (store *StoreDB) GetClickStats(ctx context.Context, code string, from time.Time, bucket time.Duration) (model.ClickStats, error) {
+	var stats model.ClickStats
+	row := store.database.QueryRowContext(ctx, "SELECT count(*) FROM clicks WHERE code = $1", code)
+	if err := row.Scan(&stats.Total); err != nil {
+		return model.ClickStats{}, err
 	}
 
+	seconds := bucket.Seconds()
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT floor(extract(epoch FROM clicked_at) / $2)::bigint AS b, count(*) FROM clicks"+
+			" WHERE code = $1 AND clicked_at >= $3"+
+			" GROUP BY b ORDER BY b",
+		code, seconds, from)
+	if err != nil {
+		return model.ClickStats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n int64
+		var clickBucket model.TimeBucket
+		if err := rows.Scan(&n, &clickBucket.Count); err != nil {
+			return model.ClickStats{}, err
+		}
+		clickBucket.Start = time.Unix(0, n*int64(bucket)).UTC()
+		stats.Buckets = append(stats.Buckets, clickBucket)
+	}
+	if err := rows.Err(); err != nil {
+		return model.ClickStats{}, err
+	}
 	return stats, nil
 }
 
+// UpdateShortener меняет адрес назначения ссылки. Строка ссылки блокируется до конца транзакции
+func (store *StoreDB) UpdateShortener(ctx context.Context, s model.Shortener) (model.Shortener, error) {
+	tx, err := store.database.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var data model.ShortenerData
+	var user sql.NullString
+	var expiresAt, createdAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT url, uuid, del_flag, expires_at, created_at FROM shortener"+
+			" WHERE code = $1 FOR UPDATE",
+		s.Key.Code).Scan(&data.URL, &user, &data.DelFlag, &expiresAt, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Shortener{}, newErrGetShortenerNotFound(s.Key.Code)
+	}
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	data.User, data.ExpiresAt, data.CreatedAt = user.String, expiresAt.Time, createdAt.Time
+	switch {
+	case data.User != s.Data.User:
+		return model.Shortener{}, newErrGetShortenerNotFound(s.Key.Code)
+	case data.DelFlag:
+		return model.Shortener{}, ErrGetShortenerGone
+	case data.Expired(now):
+		return model.Shortener{}, ErrGetShortenerExpired
+	case data.URL == s.Data.URL:
+		return model.Shortener{Key: s.Key, Data: data}, nil
+	}
+
+	var oldCode string
+	err = tx.QueryRowContext(ctx, "SELECT code FROM shortener WHERE url = $1", s.Data.URL).Scan(&oldCode)
+	if err == nil {
+		return model.Shortener{
+			Key:  model.ShortenerKey{Code: oldCode},
+			Data: model.ShortenerData{URL: s.Data.URL},
+		}, ErrSetShortenerAlreadyExists
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return model.Shortener{}, err
+	}
+
+	// История: исходный адрес записывается при первом изменении
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO shortener_revisions (code, revision, url, created_at)"+
+			" VALUES ($1, 1, $2, $3) ON CONFLICT DO NOTHING",
+		s.Key.Code, data.URL, nullTime(data.CreatedAt))
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO shortener_revisions (code, revision, url, created_at)"+
+			" SELECT $1, max(revision) + 1, $2, $3 FROM shortener_revisions WHERE code = $1",
+		s.Key.Code, s.Data.URL, now)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE shortener SET url = $2 WHERE code = $1", s.Key.Code, s.Data.URL)
+	if err != nil {
+		// URL сокращен параллельно другой ссылкой
+		if isURLConflict(err) {
+			return model.Shortener{}, ErrSetShortenerAlreadyExists
+		}
+		return model.Shortener{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Shortener{}, err
+	}
+
+	data.URL = s.Data.URL
+	return model.Shortener{Key: s.Key, Data: data}, nil
+}
+
+// GetRevisions возвращает историю адресов ссылки
+func (store *StoreDB) GetRevisions(ctx context.Context, code string) ([]model.Revision, error) {
+	var url string
+	var createdAt sql.NullTime
+	err := store.database.QueryRowContext(ctx, "SELECT url, created_at FROM shortener WHERE code = $1", code).Scan(&url, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newErrGetShortenerNotFound(code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT revision, url, created_at FROM shortener_revisions WHERE code = $1 ORDER BY revision",
+		code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var revs []model.Revision
+	for rows.Next() {
+		var rev model.Revision
+		var revCreatedAt sql.NullTime
+		if err := rows.Scan(&rev.Revision, &rev.URL, &revCreatedAt); err != nil {
+			return nil, err
+		}
+		rev.CreatedAt = revCreatedAt.Time
+		revs = append(revs, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		revs = []model.Revision{{Revision: 1, URL: url, CreatedAt: createdAt.Time}}
+	}
+	return revs, nil
+}
+
+// GetStatsReport возвращает статистику за период. Агрегаты считаются в базе по индексам времени
+func (store *StoreDB) GetStatsReport(ctx context.Context, from, to time.Time, top int) (model.StatsReport, error) {
+	var report model.StatsReport
+	row := store.database.QueryRowContext(ctx,
+		"SELECT count(*) FILTER (WHERE NOT del_flag),"+
+			" count(DISTINCT uuid) FILTER (WHERE NOT del_flag),"+
+			" count(*) FILTER (WHERE del_flag AND deleted_at >= $1 AND deleted_at < $2)"+
+			" FROM shortener", from, to)
+	if err := row.Scan(&report.URLs, &report.Users, &report.DeletedURLs); err != nil {
+		return model.StatsReport{}, err
+	}
+	row = store.database.QueryRowContext(ctx,
+		"SELECT count(DISTINCT uuid) FROM shortener WHERE created_at >= $1 AND created_at < $2",
+		from, to)
+	if err := row.Scan(&report.ActiveUsers); err != nil {
+		return model.StatsReport{}, err
+	}
+
+	seconds := model.StatsDay.Seconds()
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT floor(extract(epoch FROM created_at) / $3)::bigint AS d, count(*) FROM shortener"+
+			" WHERE created_at >= $1 AND created_at < $2"+
+			" GROUP BY d ORDER BY d",
+		from, to, seconds)
+	if err != nil {
+		return model.StatsReport{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n int64
+		var day model.TimeBucket
+		if err := rows.Scan(&n, &day.Count); err != nil {
+			return model.StatsReport{}, err
+		}
+		day.Start = time.Unix(0, n*int64(model.StatsDay)).UTC()
+		report.Created = append(report.Created, day)
+	}
+	if err := rows.Err(); err != nil {
+		return model.StatsReport{}, err
+	}
+
+	rows, err = store.database.QueryContext(ctx,
+		"SELECT code, count(*) AS n FROM clicks"+
+			" WHERE clicked_at >= $1 AND clicked_at < $2"+
+			" GROUP BY code ORDER BY n DESC, code LIMIT $3",
+		from, to, top)
+	if err != nil {
+		return model.StatsReport{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var link model.LinkClicks
+		if err := rows.Scan(&link.Code, &link.Clicks); err != nil {
+			return model.StatsReport{}, err
+		}
+		report.TopLinks = append(report.TopLinks, link)
+	}
+	if err := rows.Err(); err != nil {
+		return model.StatsReport{}, err
+	}
+	return report, nil
+}
+
+// LeaseIDBlock выделяет блок номеров. Обновление строки счетчика атомарно для всех экземпляров
+func (store *StoreDB) LeaseIDBlock(ctx context.Context, size uint64) (uint64, error) {
+	var leased int64
+	row := store.database.QueryRowContext(ctx,
+		"UPDATE id_allocator SET leased = leased + $1"+
+			" WHERE id = 1"+
+			" RETURNING leased",
+		int64(size))
+	if err := row.Scan(&leased); err != nil {
+		return 0, err
+	}
+	return uint64(leased) - size + 1, nil
+}
+
+// SaveDeleteJob создает или заменяет задание удаления
+func (store *StoreDB) SaveDeleteJob(ctx context.Context, job model.DeleteJob) error {
+	codes, err := json.Marshal(job.Codes)
+	if err != nil {
+		return err
+	}
+	_, err = store.database.ExecContext(ctx,
+		"INSERT INTO delete_jobs (id, uuid, status, codes, attempts, error, next_attempt, created_at, updated_at)"+
+			" VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"+
+			" ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, codes = EXCLUDED.codes,"+
+			"   attempts = EXCLUDED.attempts, error = EXCLUDED.error,"+
+			"   next_attempt = EXCLUDED.next_attempt, updated_at = EXCLUDED.updated_at",
+		job.ID, job.User, string(job.Status), string(codes), job.Attempts, job.Error,
+		job.NextAttempt, job.CreatedAt, job.UpdatedAt)
+	return err
+}
+
+// deleteJobColumns - поля задания удаления в порядке scanDeleteJob
+const deleteJobColumns = "id, uuid, status, codes, attempts, error, next_attempt, created_at, updated_at"
+
+// scanDeleteJob читает задание удаления из строки результата
+func scanDeleteJob(row interface{ Scan(dest ...any) error }) (model.DeleteJob, error) {
+	var job model.DeleteJob
+	var status string
+	var codes []byte
+	err := row.Scan(&job.ID, &job.User, &status, &codes, &job.Attempts, &job.Error,
+		&job.NextAttempt, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return model.DeleteJob{}, err
+	}
+	job.Status = model.DeleteJobStatus(status)
+	if err := json.Unmarshal(codes, &job.Codes); err != nil {
+		return model.DeleteJob{}, err
+	}
+	return job, nil
+}
+
+// GetDeleteJob читает задание удаления
+func (store *StoreDB) GetDeleteJob(ctx context.Context, id string) (model.DeleteJob, error) {
+	row := store.database.QueryRowContext(ctx,
+		"SELECT "+deleteJobColumns+" FROM delete_jobs WHERE id = $1", id)
+	job, err := scanDeleteJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DeleteJob{}, ErrDeleteJobNotFound
+	}
+	return job, err
+}
+
+// GetPendingDeleteJobs возвращает невыполненные задания удаления
+func (store *StoreDB) GetPendingDeleteJobs(ctx context.Context) ([]model.DeleteJob, error) {
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT "+deleteJobColumns+" FROM delete_jobs WHERE status = $1 ORDER BY created_at, id",
+		string(model.DeleteJobPending))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []model.DeleteJob
+	for rows.Next() {
+		job, err := scanDeleteJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
 // Close закрывает соединение
 func (store *StoreDB) Close() {
 	store.database.Close()
@@ -0,0 +1,683 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+)
+
+func TestStoreFile_DeleteReplay(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []model.Shortener{
+		{Key: model.ShortenerKey{Code: "aaaaaa"}, Data: model.ShortenerData{URL: "https://a.ru/", User: "u1"}},
+		{Key: model.ShortenerKey{Code: "bbbbbb"}, Data: model.ShortenerData{URL: "https://b.ru/", User: "u1"}},
+	} {
+		if _, err := store.SetShortener(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Чужой пользователь не может удалить ссылку
+	_, err = store.DeleteShortenerBatch(ctx, []model.Shortener{
+		{Key: model.ShortenerKey{Code: "aaaaaa"}, Data: model.ShortenerData{User: "u1"}},
+		{Key: model.ShortenerKey{Code: "bbbbbb"}, Data: model.ShortenerData{User: "u2"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Удаление восстанавливается из файла
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if _, err := store.GetShortener("aaaaaa"); !errors.Is(err, ErrGetShortenerGone) {
+		t.Errorf("GetShortener(aaaaaa) error = %v, want %v", err, ErrGetShortenerGone)
+	}
+	if _, err := store.GetShortener("bbbbbb"); err != nil {
+		t.Errorf("GetShortener(bbbbbb) error = %v", err)
+	}
+	batch, err := store.GetShortenerBatch(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 1 || batch[0].Key.Code != "bbbbbb" {
+		t.Errorf("GetShortenerBatch(u1) = %v, want only bbbbbb", batch)
+	}
+}
+
+func TestStoreFile_CloseTwice(t *testing.T) {
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json"), CompactInterval: time.Hour}
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Повторное закрытие (например, хранилище и сервис при остановке) не паникует
+	store.Close()
+	store.Close()
+}
+
+func TestStoreFile_TornTail(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "store.json")
+	content := `{"code":"aaaaaa","url":"https://a.ru/","user":"u1"}` + "\n" + `{"code":"bbbbbb","url":"https://b`
+	if err := os.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewStoreFile(config.Config{StoreType: config.StoreTypeFile, Filename: filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetShortener("aaaaaa"); err != nil {
+		t.Errorf("GetShortener(aaaaaa) error = %v", err)
+	}
+	if _, err := store.GetShortener("bbbbbb"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("GetShortener(bbbbbb) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+
+	// Новая запись не склеивается с оборванной строкой
+	s := model.Shortener{Key: model.ShortenerKey{Code: "cccccc"}, Data: model.ShortenerData{URL: "https://c.ru/", User: "u1"}}
+	if _, err := store.SetShortener(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = NewStoreFile(config.Config{StoreType: config.StoreTypeFile, Filename: filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.GetShortener("cccccc"); err != nil {
+		t.Errorf("GetShortener(cccccc) error = %v", err)
+	}
+}
+
+func TestStoreFile_Compact(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []model.Shortener{
+		{Key: model.ShortenerKey{Code: "aaaaaa"}, Data: model.ShortenerData{URL: "https://a.ru/", User: "u1"}},
+		{Key: model.ShortenerKey{Code: "bbbbbb"}, Data: model.ShortenerData{URL: "https://b.ru/", User: "u1"}},
+	} {
+		if _, err := store.SetShortener(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = store.DeleteShortenerBatch(ctx, []model.Shortener{
+		{Key: model.ShortenerKey{Code: "aaaaaa"}, Data: model.ShortenerData{User: "u1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	// После сжатия запись продолжается в новый файл
+	s := model.Shortener{Key: model.ShortenerKey{Code: "cccccc"}, Data: model.ShortenerData{URL: "https://c.ru/", User: "u1"}}
+	if _, err := store.SetShortener(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	data, err := os.ReadFile(cfg.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 3 {
+		t.Errorf("lines after compaction = %d, want 3", lines)
+	}
+
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.GetShortener("aaaaaa"); !errors.Is(err, ErrGetShortenerGone) {
+		t.Errorf("GetShortener(aaaaaa) error = %v, want %v", err, ErrGetShortenerGone)
+	}
+	for _, code := range []string{"bbbbbb", "cccccc"} {
+		if _, err := store.GetShortener(code); err != nil {
+			t.Errorf("GetShortener(%s) error = %v", code, err)
+		}
+	}
+}
+
+func TestStoreFile_CompactLines(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	s := model.Shortener{Key: model.ShortenerKey{Code: "aaaaaa"}, Data: model.ShortenerData{URL: "https://a.ru/", User: "u1"}}
+	if _, err := store.SetShortener(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	s.Data.URL = "https://a.ru/new"
+	if _, err := store.UpdateShortener(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LeaseIDBlock(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Счетчик записей совпадает с файлом: история изменений и граница номеров - отдельные записи
+	data, err := os.ReadFile(cfg.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != store.lines {
+		t.Errorf("lines after compaction = %d, counted %d", lines, store.lines)
+	}
+}
+
+func TestStoreFile_ExpiryReplay(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+	expiresAt := time.Now().Add(time.Second).Truncate(time.Millisecond)
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.SetShortener(ctx, model.Shortener{
+		Key:  model.ShortenerKey{Code: "aaaaaa"},
+		Data: model.ShortenerData{URL: "https://a.ru/", User: "u1", ExpiresAt: expiresAt},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Срок действия восстанавливается из файла
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.GetShortener("aaaaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Data.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", s.Data.ExpiresAt, expiresAt)
+	}
+	if n, err := store.DeleteExpired(ctx, expiresAt); err != nil || n != 1 {
+		t.Fatalf("DeleteExpired = %d, %v", n, err)
+	}
+	store.Close()
+
+	// Окончательное удаление восстанавливается из файла
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.GetShortener("aaaaaa"); !errors.Is(err, ErrGetShortenerNotFound) {
+		t.Errorf("GetShortener(aaaaaa) error = %v, want %v", err, ErrGetShortenerNotFound)
+	}
+}
+
+func TestStoreFile_LeaseIDBlockReplay(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LeaseIDBlock(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Выделенные номера не выдаются повторно после перезапуска и сжатия
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	start, err := store.LeaseIDBlock(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != 101 {
+		t.Errorf("LeaseIDBlock = %d, want 101", start)
+	}
+}
+
+func TestStoreFile_ClicksReplay(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+	clickTime := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.SaveClicks(ctx, []model.Click{
+		{Code: "aaaaaa", Time: clickTime, Referrer: "https://ya.ru/"},
+		{Code: "aaaaaa", Time: clickTime.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Переходы восстанавливаются из файла переходов
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	stats, err := store.GetClickStats(ctx, "aaaaaa", clickTime, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 2 || len(stats.Buckets) != 1 || stats.Buckets[0].Count != 2 {
+		t.Errorf("GetClickStats = %+v, want 2 clicks in one bucket", stats)
+	}
+}
+
+func TestStoreFile_PurgeClicksReplay(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+	clickTime := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+	s := model.Shortener{Key: model.ShortenerKey{Code: "aaaaaa"}, Data: model.ShortenerData{URL: "https://a.ru/", User: "u1"}}
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SetShortener(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveClicks(ctx, []model.Click{{Code: "aaaaaa", Time: clickTime}, {Code: "aaaaaa", Time: clickTime}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.DeleteShortenerBatch(ctx, []model.Shortener{s}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.PurgeDeleted(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("PurgeDeleted = %d, %v, want 1", n, err)
+	}
+	// Код занят заново: учитываются только переходы новой ссылки
+	s.Data.URL = "https://b.ru/"
+	if _, err := store.SetShortener(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveClicks(ctx, []model.Click{{Code: "aaaaaa", Time: clickTime.Add(time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	stats, err := store.GetClickStats(ctx, "aaaaaa", clickTime, time.Hour)
+	if err != nil || stats.Total != 1 {
+		t.Errorf("GetClickStats = %+v, %v, want 1 click", stats, err)
+	}
+}
+
+func TestStoreFile_CreatedAtReplay(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.SetShortener(ctx, model.Shortener{
+		Key:  model.ShortenerKey{Code: "aaaaaa"},
+		Data: model.ShortenerData{URL: "https://a.ru/", User: "u1", CreatedAt: day.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Время создания восстанавливается из файла
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	report, err := store.GetStatsReport(ctx, day, day.Add(model.StatsDay), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Created) != 1 || report.Created[0].Count != 1 || report.ActiveUsers != 1 {
+		t.Errorf("GetStatsReport = %+v, want one link created on %v", report, day)
+	}
+}
+
+func TestStoreFile_RevisionsReplay(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := model.Shortener{Key: model.ShortenerKey{Code: "aaaaaa"}, Data: model.ShortenerData{URL: "https://a.ru/", User: "u1"}}
+	if _, err := store.SetShortener(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	s.Data.URL = "https://b.ru/"
+	if _, err := store.UpdateShortener(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	// Прежний адрес сокращен другой ссылкой после изменения
+	s = model.Shortener{Key: model.ShortenerKey{Code: "cccccc"}, Data: model.ShortenerData{URL: "https://a.ru/", User: "u1"}}
+	if _, err := store.SetShortener(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	check := func(stage string) {
+		store, err := NewStoreFile(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+
+		if s, err := store.GetShortener("aaaaaa"); err != nil || s.Data.URL != "https://b.ru/" {
+			t.Errorf("%s: GetShortener(aaaaaa) = %+v, %v", stage, s, err)
+		}
+		s := model.Shortener{Key: model.ShortenerKey{Code: "dddddd"}, Data: model.ShortenerData{URL: "https://a.ru/", User: "u1"}}
+		if s, err := store.SetShortener(ctx, s); !errors.Is(err, ErrSetShortenerAlreadyExists) || s.Key.Code != "cccccc" {
+			t.Errorf("%s: SetShortener(https://a.ru/) = %+v, %v, want cccccc", stage, s, err)
+		}
+		revs, err := store.GetRevisions(ctx, "aaaaaa")
+		if err != nil || len(revs) != 2 || revs[0].URL != "https://a.ru/" || revs[1].URL != "https://b.ru/" {
+			t.Errorf("%s: GetRevisions = %+v, %v", stage, revs, err)
+		}
+		if err := store.Compact(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// История восстанавливается из файла, в том числе после сжатия
+	check("replay")
+	check("compacted")
+}
+
+func TestStoreFile_TrashReplay(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{"aaaaaa", "bbbbbb", "cccccc"} {
+		s := model.Shortener{Key: model.ShortenerKey{Code: code}, Data: model.ShortenerData{URL: "https://" + code + ".ru/", User: "u1"}}
+		if _, err := store.SetShortener(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var batch []model.Shortener
+	for _, code := range []string{"aaaaaa", "bbbbbb", "cccccc"} {
+		batch = append(batch, model.Shortener{Key: model.ShortenerKey{Code: code}, Data: model.ShortenerData{User: "u1"}})
+	}
+	if _, err := store.DeleteShortenerBatch(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.RestoreShortener(ctx, batch[0], time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.PurgeDeleted(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// Удалена после окончательного удаления
+	if _, err := store.DeleteShortenerBatch(ctx, batch[:1]); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	check := func(stage string) {
+		store, err := NewStoreFile(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+
+		deleted, err := store.GetDeletedBatch(ctx, "u1")
+		if err != nil || len(deleted) != 1 || deleted[0].Key.Code != "aaaaaa" || deleted[0].Data.DeletedAt.IsZero() {
+			t.Errorf("%s: GetDeletedBatch = %+v, %v, want aaaaaa with deletion time", stage, deleted, err)
+		}
+		for _, code := range []string{"bbbbbb", "cccccc"} {
+			if _, err := store.GetShortener(code); !errors.Is(err, ErrGetShortenerNotFound) {
+				t.Errorf("%s: GetShortener(%s) error = %v, want %v", stage, code, err, ErrGetShortenerNotFound)
+			}
+		}
+		if err := store.Compact(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Удаление, восстановление и окончательное удаление восстанавливаются из файла, в том числе после сжатия
+	check("replay")
+	check("compacted")
+}
+
+func TestStoreFile_DeletedAtBackfill(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+
+	// Ссылки, удаленные до учета времени удаления: полная запись и событие удаления
+	legacy := `{"code":"aaaaaa","url":"https://a.ru/","user":"u1","del_flag":true}` + "\n" +
+		`{"code":"bbbbbb","url":"https://b.ru/","user":"u1"}` + "\n" +
+		`{"code":"bbbbbb","user":"u1","del_flag":true}` + "\n"
+	if err := os.WriteFile(cfg.Filename, []byte(legacy), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := time.Now()
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Время удаления назначено при загрузке: ссылки не удаляются окончательно сразу
+	if n, err := store.PurgeDeleted(ctx, loaded); err != nil || n != 0 {
+		t.Errorf("PurgeDeleted = %d, %v, want 0", n, err)
+	}
+	deleted, err := store.GetDeletedBatch(ctx, "u1")
+	if err != nil || len(deleted) != 2 {
+		t.Fatalf("GetDeletedBatch = %+v, %v, want 2 links", deleted, err)
+	}
+	store.Close()
+
+	// Назначенное время сохраняется в файле
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	replayed, err := store.GetDeletedBatch(ctx, "u1")
+	if err != nil || len(replayed) != 2 {
+		t.Fatalf("GetDeletedBatch(replay) = %+v, %v, want 2 links", replayed, err)
+	}
+	want := make(map[string]time.Time)
+	for _, s := range deleted {
+		want[s.Key.Code] = s.Data.DeletedAt
+	}
+	for _, s := range replayed {
+		if s.Data.DeletedAt.Before(loaded) || !s.Data.DeletedAt.Equal(want[s.Key.Code]) {
+			t.Errorf("%s: replayed DeletedAt = %v, want %v", s.Key.Code, s.Data.DeletedAt, want[s.Key.Code])
+		}
+	}
+}
+
+func TestStoreKV_DeletedAtBackfill(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeKV, KVPath: filepath.Join(t.TempDir(), "store.db")}
+
+	store, err := NewStoreKV(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := model.Shortener{Key: model.ShortenerKey{Code: "aaaaaa"}, Data: model.ShortenerData{URL: "https://a.ru/", User: "u1"}}
+	if _, err := store.SetShortener(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.DeleteShortenerBatch(ctx, []model.Shortener{s}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Хранилище до учета времени удаления: без индекса удаленных ссылок и времени в записи
+	db, err := bolt.Open(cfg.KVPath, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		rec, _, err := kvGet(tx, "aaaaaa")
+		if err != nil {
+			return err
+		}
+		rec.DeletedAt = nil
+		if err := kvPut(tx, "aaaaaa", rec); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(kvBucketDeleted)
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := time.Now()
+	store, err = NewStoreKV(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	// Время удаления назначено при открытии: ссылка не удаляется окончательно сразу
+	if n, err := store.PurgeDeleted(ctx, loaded); err != nil || n != 0 {
+		t.Errorf("PurgeDeleted = %d, %v, want 0", n, err)
+	}
+	deleted, err := store.GetDeletedBatch(ctx, "u1")
+	if err != nil || len(deleted) != 1 || deleted[0].Data.DeletedAt.Before(loaded) {
+		t.Errorf("GetDeletedBatch = %+v, %v, want aaaaaa deleted at load", deleted, err)
+	}
+	if n, err := store.PurgeDeleted(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Errorf("PurgeDeleted(later) = %d, %v, want 1", n, err)
+	}
+}
+
+func TestStoreFile_DeleteJobsReplay(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	job := model.DeleteJob{ID: "job01", User: "u1", Status: model.DeleteJobPending,
+		Codes: []model.DeleteJobCode{{Code: "aaaaaa"}}, NextAttempt: now, CreatedAt: now, UpdatedAt: now}
+	if err := store.SaveDeleteJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	job.ID = "job02"
+	if err := store.SaveDeleteJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	job.Status, job.Codes[0].Status = model.DeleteJobDone, model.DeleteStatusDeleted
+	if err := store.SaveDeleteJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Действует последняя запись задания
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	pending, err := store.GetPendingDeleteJobs(ctx)
+	if err != nil || len(pending) != 1 || pending[0].ID != "job01" || pending[0].User != "u1" {
+		t.Errorf("GetPendingDeleteJobs = %+v, %v, want job01", pending, err)
+	}
+	got, err := store.GetDeleteJob(ctx, "job02")
+	if err != nil || got.Status != model.DeleteJobDone || got.Codes[0].Status != model.DeleteStatusDeleted {
+		t.Errorf("GetDeleteJob(job02) = %+v, %v, want done", got, err)
+	}
+}
+
+func TestStoreFile_MaxClicksReplay(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{StoreType: config.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+
+	store, err := NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := model.Shortener{
+		Key:  model.ShortenerKey{Code: "aaaaaa"},
+		Data: model.ShortenerData{URL: "https://example.com/", User: "u1", MaxClicks: 2, ClicksLeft: 2},
+	}
+	if _, err := store.SetShortener(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UseShortener(ctx, "aaaaaa"); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Остаток переходов восстанавливается из файла
+	store, err = NewStoreFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	got, err := store.UseShortener(ctx, "aaaaaa")
+	if err != nil || got.Data.ClicksLeft != 0 || got.Data.MaxClicks != 2 {
+		t.Errorf("UseShortener after replay = %+v, %v, want 0 of 2 clicks left", got.Data, err)
+	}
+	if _, err := store.GetShortener("aaaaaa"); !errors.Is(err, ErrGetShortenerExhausted) {
+		t.Errorf("GetShortener after replay error = %v, want %v", err, ErrGetShortenerExhausted)
+	}
+}
@@ -0,0 +1,1022 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+)
+
+// Бакеты хранилища ключ-значение
+var (
+	// kvBucketShortener: код -> kvRecord
+	kvBucketShortener = []byte("shortener")
+	// kvBucketURL: URL -> код (вторичный индекс)
+	kvBucketURL = []byte("url")
+	// kvBucketUser: пользователь + kvSep + код -> пусто (вторичный индекс)
+	kvBucketUser = []byte("user")
+	// kvBucketUserCount: пользователь -> кол-во неудаленных ссылок
+	kvBucketUserCount = []byte("user_count")
+	// kvBucketMeta: служебные счетчики
+	kvBucketMeta = []byte("meta")
+	// kvBucketExpires: срок действия (unix-время в наносекундах, big endian) + код -> пусто
+	kvBucketExpires = []byte("expires")
+	// kvBucketClicks: код + kvSep + время перехода (unix-время в наносекундах, big endian) + номер -> kvClick
+	kvBucketClicks = []byte("clicks")
+	// kvBucketCreated: время создания (unix-время в наносекундах, big endian) + код -> пользователь
+	kvBucketCreated = []byte("created")
+	// kvBucketClickDays: начало суток (unix-время в секундах, big endian) + код -> кол-во переходов за сутки
+	kvBucketClickDays = []byte("click_days")
+	// kvBucketRevisions: код + kvSep + номер изменения (big endian) -> kvRevision
+	kvBucketRevisions = []byte("revisions")
+	// kvBucketDeleted: время удаления (unix-время в наносекундах, big endian) + код -> пусто.
+	// Ссылкам, удаленным до учета времени, оно назначается при открытии (kvBackfillDeleted)
+	kvBucketDeleted = []byte("deleted")
+	// kvBucketJobs: ID задания удаления -> JobJSON
+	kvBucketJobs = []byte("delete_jobs")
+	// kvBucketPendingJobs: время создания (unix-время в наносекундах, big endian) + ID -> пусто
+	// (индекс невыполненных заданий удаления)
+	kvBucketPendingJobs = []byte("pending_jobs")
+)
+
+// Ключи счетчиков в kvBucketMeta
+var (
+	// kvKeyURLs - кол-во неудаленных ссылок
+	kvKeyURLs = []byte("urls")
+)
+
+// kvSep - разделитель пользователя и кода в индексе пользователей
+const kvSep = 0
+
+// kvRecord - значение в бакете ссылок
+type kvRecord struct {
+	URL       string     `json:"url"`
+	User      string     `json:"user"`
+	DelFlag   bool       `json:"del_flag,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// PasswordHash - хэш пароля ссылки
+	PasswordHash string `json:"password_hash,omitempty"`
+	// MaxClicks, ClicksLeft - ограничение и остаток переходов по ссылке
+	MaxClicks  int `json:"max_clicks,omitempty"`
+	ClicksLeft int `json:"clicks_left,omitempty"`
+}
+
+// data - данные ссылки из записи
+func (rec kvRecord) data() model.ShortenerData {
+	data := model.ShortenerData{URL: rec.URL, User: rec.User, DelFlag: rec.DelFlag, PasswordHash: rec.PasswordHash,
+		MaxClicks: rec.MaxClicks, ClicksLeft: rec.ClicksLeft}
+	if rec.ExpiresAt != nil {
+		data.ExpiresAt = *rec.ExpiresAt
+	}
+	if rec.CreatedAt != nil {
+		data.CreatedAt = *rec.CreatedAt
+	}
+	if rec.DeletedAt != nil {
+		data.DeletedAt = *rec.DeletedAt
+	}
+	return data
+}
+
+// kvClick - значение в бакете переходов
+type kvClick struct {
+	Referrer  string `json:"referrer,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	IPHash    string `json:"ip_hash,omitempty"`
+}
+
+// kvRevision - значение в бакете истории изменений
+type kvRevision struct {
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StoreKV - Реализация с хранением во встроенной базе ключ-значение (bbolt).
+// Данные не загружаются в память целиком
+type StoreKV struct {
+	db *bolt.DB
+}
+
+// NewStoreKV - конструктор хранилища
+func NewStoreKV(cfg config.Config) (*StoreKV, error) {
+	db, err := bolt.Open(cfg.KVPath, 0666, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		// Индекс удаленных ссылок строится по записям, созданным до его появления
+		if tx.Bucket(kvBucketDeleted) == nil {
+			if err := kvIndexDeleted(tx); err != nil {
+				return err
+			}
+		}
+		for _, name := range [][]byte{kvBucketShortener, kvBucketURL, kvBucketUser, kvBucketUserCount, kvBucketMeta, kvBucketExpires, kvBucketClicks, kvBucketCreated, kvBucketClickDays, kvBucketRevisions, kvBucketDeleted, kvBucketJobs, kvBucketPendingJobs} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return kvBackfillDeleted(tx, time.Now())
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &StoreKV{db: db}, nil
+}
+
+// kvIndexDeleted создает индекс удаленных ссылок. Время удаления таких ссылок неизвестно,
+// его назначает kvBackfillDeleted
+func kvIndexDeleted(tx *bolt.Tx) error {
+	deleted, err := tx.CreateBucket(kvBucketDeleted)
+	if err != nil {
+		return err
+	}
+	b := tx.Bucket(kvBucketShortener)
+	if b == nil {
+		return nil
+	}
+	return b.ForEach(func(k, v []byte) error {
+		var rec kvRecord
+		if err := json.Unmarshal(v, &rec); err != nil {
+			return err
+		}
+		if !rec.DelFlag {
+			return nil
+		}
+		return deleted.Put(kvDeletedKey(time.Time{}, string(k)), nil)
+	})
+}
+
+// kvBackfillDeleted назначает время удаления now ссылкам с неизвестным временем удаления,
+// чтобы они хранились в корзине полный срок
+func kvBackfillDeleted(tx *bolt.Tx, now time.Time) error {
+	deleted := tx.Bucket(kvBucketDeleted)
+
+	// Ключи собираются заранее: изменение бакета под курсором сдвигает его
+	var keys [][]byte
+	unknown := kvDeletedKey(time.Time{}, "")
+	c := deleted.Cursor()
+	for k, _ := c.Seek(unknown); k != nil && bytes.HasPrefix(k, unknown); k, _ = c.Next() {
+		keys = append(keys, bytes.Clone(k))
+	}
+
+	for _, k := range keys {
+		if err := deleted.Delete(k); err != nil {
+			return err
+		}
+		code := string(k[8:])
+		rec, ok, err := kvGet(tx, code)
+		if err != nil {
+			return err
+		}
+		if !ok || !rec.DelFlag {
+			continue
+		}
+		rec.DeletedAt = &now
+		if err := kvPut(tx, code, rec); err != nil {
+			return err
+		}
+		if err := deleted.Put(kvDeletedKey(now, code), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kvUserKey - ключ индекса пользователей
+func kvUserKey(user, code string) []byte {
+	key := make([]byte, 0, len(user)+1+len(code))
+	key = append(key, user...)
+	key = append(key, kvSep)
+	return append(key, code...)
+}
+
+// kvExpiresKey - ключ индекса сроков действия
+func kvExpiresKey(t time.Time, code string) []byte {
+	key := make([]byte, 8, 8+len(code))
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return append(key, code...)
+}
+
+// kvDeletedKey - ключ индекса удаленных ссылок. Неизвестное время удаления - начало отсчета
+func kvDeletedKey(t time.Time, code string) []byte {
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return kvExpiresKey(t, code)
+}
+
+// kvAdd изменяет счетчик на delta. Нулевой счетчик удаляется
+func kvAdd(b *bolt.Bucket, key []byte, delta int64) error {
+	var n int64
+	if v := b.Get(key); v != nil {
+		n = int64(binary.BigEndian.Uint64(v))
+	}
+	n += delta
+	if n <= 0 {
+		return b.Delete(key)
+	}
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(n))
+	return b.Put(key, v)
+}
+
+// kvCount - значение счетчика
+func kvCount(b *bolt.Bucket, key []byte) int {
+	v := b.Get(key)
+	if v == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(v))
+}
+
+// kvGet читает ссылку по коду
+func kvGet(tx *bolt.Tx, code string) (kvRecord, bool, error) {
+	v := tx.Bucket(kvBucketShortener).Get([]byte(code))
+	if v == nil {
+		return kvRecord{}, false, nil
+	}
+	var rec kvRecord
+	if err := json.Unmarshal(v, &rec); err != nil {
+		return kvRecord{}, false, err
+	}
+	return rec, true, nil
+}
+
+// kvPut записывает ссылку
+func kvPut(tx *bolt.Tx, code string, rec kvRecord) error {
+	v, err := json.Marshal(&rec)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(kvBucketShortener).Put([]byte(code), v)
+}
+
+// kvSet создает ссылку внутри транзакции
+func kvSet(tx *bolt.Tx, row model.ShortenerBatchRow) (model.ShortenerBatchRow, error) {
+	s := row.Shortener
+
+	// Проверка: уже существует
+	if oldCode := tx.Bucket(kvBucketURL).Get([]byte(s.Data.URL)); oldCode != nil {
+		rec, _, err := kvGet(tx, string(oldCode))
+		if err != nil {
+			return row, err
+		}
+		row.Shortener = model.Shortener{
+			Key:  model.ShortenerKey{Code: string(oldCode)},
+			Data: rec.data(),
+		}
+		row.Status = model.ShortenerStatusExists
+		return row, nil
+	}
+	if _, ok, err := kvGet(tx, s.Key.Code); err != nil || ok {
+		row.Status = model.ShortenerStatusCodeConflict
+		return row, err
+	}
+
+	// Запись ссылки и индексов
+	if s.Data.CreatedAt.IsZero() {
+		s.Data.CreatedAt = time.Now()
+		row.Shortener = s
+	}
+	rec := kvRecord{URL: s.Data.URL, User: s.Data.User, CreatedAt: &s.Data.CreatedAt, PasswordHash: s.Data.PasswordHash,
+		MaxClicks: s.Data.MaxClicks, ClicksLeft: s.Data.ClicksLeft}
+	if err := tx.Bucket(kvBucketCreated).Put(kvExpiresKey(s.Data.CreatedAt, s.Key.Code), []byte(s.Data.User)); err != nil {
+		return row, err
+	}
+	if !s.Data.ExpiresAt.IsZero() {
+		rec.ExpiresAt = &s.Data.ExpiresAt
+		if err := tx.Bucket(kvBucketExpires).Put(kvExpiresKey(s.Data.ExpiresAt, s.Key.Code), nil); err != nil {
+			return row, err
+		}
+	}
+	if err := kvPut(tx, s.Key.Code, rec); err != nil {
+		return row, err
+	}
+	if err := tx.Bucket(kvBucketURL).Put([]byte(s.Data.URL), []byte(s.Key.Code)); err != nil {
+		return row, err
+	}
+	if err := tx.Bucket(kvBucketUser).Put(kvUserKey(s.Data.User, s.Key.Code), nil); err != nil {
+		return row, err
+	}
+	if err := kvAdd(tx.Bucket(kvBucketUserCount), []byte(s.Data.User), 1); err != nil {
+		return row, err
+	}
+	if err := kvAdd(tx.Bucket(kvBucketMeta), kvKeyURLs, 1); err != nil {
+		return row, err
+	}
+
+	row.Status = model.ShortenerStatusCreated
+	return row, nil
+}
+
+// GetShortener читает короткую ссылку
+func (store *StoreKV) GetShortener(code string) (model.Shortener, error) {
+	var rec kvRecord
+	var ok bool
+	err := store.db.View(func(tx *bolt.Tx) error {
+		var err error
+		rec, ok, err = kvGet(tx, code)
+		return err
+	})
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	data := rec.data()
+	if err := checkShortener(code, data, ok, time.Now()); err != nil {
+		return model.Shortener{}, err
+	}
+	return model.Shortener{
+		Key:  model.ShortenerKey{Code: code},
+		Data: data,
+	}, nil
+}
+
+// UseShortener учитывает переход по ссылке с ограничением кол-ва переходов.
+// Чтение и запись остатка выполняются в одной транзакции записи
+func (store *StoreKV) UseShortener(_ context.Context, code string) (model.Shortener, error) {
+	var data model.ShortenerData
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		rec, ok, err := kvGet(tx, code)
+		if err != nil {
+			return err
+		}
+		data = rec.data()
+		if err := checkShortener(code, data, ok, time.Now()); err != nil || rec.MaxClicks == 0 {
+			return err
+		}
+		rec.ClicksLeft--
+		data.ClicksLeft = rec.ClicksLeft
+		return kvPut(tx, code, rec)
+	})
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	return model.Shortener{
+		Key:  model.ShortenerKey{Code: code},
+		Data: data,
+	}, nil
+}
+
+// SetShortener создает короткую ссылку
+func (store *StoreKV) SetShortener(_ context.Context, s model.Shortener) (model.Shortener, error) {
+	var row model.ShortenerBatchRow
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		row, err = kvSet(tx, model.ShortenerBatchRow{Shortener: s})
+		return err
+	})
+	if err != nil {
+		return model.Shortener{}, err
+	}
+
+	switch row.Status {
+	case model.ShortenerStatusExists:
+		return row.Shortener, ErrSetShortenerAlreadyExists
+	case model.ShortenerStatusCodeConflict:
+		return model.Shortener{}, ErrSetShortenerCodeConflict
+	}
+	return row.Shortener, nil
+}
+
+// SetShortenerBatch создает короткую ссылку для набора данных
+func (store *StoreKV) SetShortenerBatch(_ context.Context, s []model.ShortenerBatchRow) ([]model.ShortenerBatchRow, error) {
+	resp := make([]model.ShortenerBatchRow, 0, len(s))
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		for _, reqRow := range s {
+			row, err := kvSet(tx, reqRow)
+			if err != nil {
+				return err
+			}
+			resp = append(resp, row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Ping
+func (store *StoreKV) Ping() error {
+	return nil
+}
+
+// GetShortenerBatch возвращает все ссылки, добавленные пользователем
+func (store *StoreKV) GetShortenerBatch(_ context.Context, userCode string) ([]model.Shortener, error) {
+	var resp []model.Shortener
+	now := time.Now()
+	err := store.db.View(func(tx *bolt.Tx) error {
+		prefix := kvUserKey(userCode, "")
+		c := tx.Bucket(kvBucketUser).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			code := string(k[len(prefix):])
+			rec, ok, err := kvGet(tx, code)
+			if err != nil {
+				return err
+			}
+			if !ok || rec.DelFlag || rec.data().Expired(now) {
+				continue
+			}
+			resp = append(resp, model.Shortener{
+				Key:  model.ShortenerKey{Code: code},
+				Data: rec.data(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DeleteShortenerBatch удаляет короткую ссылку
+func (store *StoreKV) DeleteShortenerBatch(_ context.Context, s []model.Shortener) ([]model.DeleteStatus, error) {
+	var resp []model.DeleteStatus
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		resp = make([]model.DeleteStatus, 0, len(s))
+		now := time.Now()
+		for _, s := range s {
+			// Удалить может только владелец ссылки
+			rec, ok, err := kvGet(tx, s.Key.Code)
+			if err != nil {
+				return err
+			}
+			status := deleteStatus(rec.data(), ok, s.Data.User)
+			resp = append(resp, status)
+			if status != model.DeleteStatusDeleted {
+				continue
+			}
+
+			rec.DelFlag = true
+			rec.DeletedAt = &now
+			if err := kvPut(tx, s.Key.Code, rec); err != nil {
+				return err
+			}
+			if err := tx.Bucket(kvBucketDeleted).Put(kvDeletedKey(now, s.Key.Code), nil); err != nil {
+				return err
+			}
+			if err := kvAdd(tx.Bucket(kvBucketUserCount), []byte(rec.User), -1); err != nil {
+				return err
+			}
+			if err := kvAdd(tx.Bucket(kvBucketMeta), kvKeyURLs, -1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetDeletedBatch возвращает удаленные ссылки пользователя
+func (store *StoreKV) GetDeletedBatch(_ context.Context, userCode string) ([]model.Shortener, error) {
+	var resp []model.Shortener
+	err := store.db.View(func(tx *bolt.Tx) error {
+		prefix := kvUserKey(userCode, "")
+		c := tx.Bucket(kvBucketUser).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			code := string(k[len(prefix):])
+			rec, ok, err := kvGet(tx, code)
+			if err != nil {
+				return err
+			}
+			if !ok || !rec.DelFlag {
+				continue
+			}
+			resp = append(resp, model.Shortener{
+				Key:  model.ShortenerKey{Code: code},
+				Data: rec.data(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RestoreShortener восстанавливает удаленную ссылку
+func (store *StoreKV) RestoreShortener(_ context.Context, s model.Shortener, deletedAfter time.Time) (model.Shortener, error) {
+	var resp model.Shortener
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		rec, ok, err := kvGet(tx, s.Key.Code)
+		if err != nil {
+			return err
+		}
+		data := rec.data()
+		if err := checkRestore(s.Key.Code, data, ok, s.Data.User, deletedAfter); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(kvBucketDeleted).Delete(kvDeletedKey(data.DeletedAt, s.Key.Code)); err != nil {
+			return err
+		}
+		rec.DelFlag, rec.DeletedAt = false, nil
+		if err := kvPut(tx, s.Key.Code, rec); err != nil {
+			return err
+		}
+		if err := kvAdd(tx.Bucket(kvBucketUserCount), []byte(rec.User), 1); err != nil {
+			return err
+		}
+		if err := kvAdd(tx.Bucket(kvBucketMeta), kvKeyURLs, 1); err != nil {
+			return err
+		}
+		resp = model.Shortener{Key: s.Key, Data: rec.data()}
+		return nil
+	})
+	return resp, err
+}
+
+// PurgeDeleted окончательно удаляет ссылки, удаленные раньше before.
+// Индекс удаленных ссылок упорядочен по времени удаления
+func (store *StoreKV) PurgeDeleted(_ context.Context, before time.Time) (int, error) {
+	var n int
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		// Ключи собираются заранее: удаление под курсором сдвигает его
+		var keys [][]byte
+		limit := kvExpiresKey(before, "")
+		c := tx.Bucket(kvBucketDeleted).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], limit) < 0; k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+
+		for _, k := range keys {
+			code := string(k[8:])
+			rec, ok, err := kvGet(tx, code)
+			if err != nil {
+				return err
+			}
+			if !ok || !rec.DelFlag {
+				if err := tx.Bucket(kvBucketDeleted).Delete(k); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := kvPurge(tx, code, rec); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// GetStats возвращает статистические данные
+func (store *StoreKV) GetStats(ctx context.Context) (model.Stats, error) {
+	var stats model.Stats
+	err := store.db.View(func(tx *bolt.Tx) error {
+		stats.URLs = kvCount(tx.Bucket(kvBucketMeta), kvKeyURLs)
+		stats.Users = tx.Bucket(kvBucketUserCount).Stats().KeyN
+		return nil
+	})
+	return stats, err
+}
+
+// DeleteExpired окончательно удаляет истекшие ссылки.
+// Индекс сроков действия упорядочен по времени, просматриваются только истекшие ссылки
+func (store *StoreKV) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	var n int
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		// Ключи собираются заранее: удаление под курсором сдвигает его
+		var keys [][]byte
+		limit := kvExpiresKey(now, "")
+		c := tx.Bucket(kvBucketExpires).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], limit) <= 0; k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+
+		for _, k := range keys {
+			if err := tx.Bucket(kvBucketExpires).Delete(k); err != nil {
+				return err
+			}
+			code := string(k[8:])
+			rec, ok, err := kvGet(tx, code)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if err := kvPurge(tx, code, rec); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// kvPurge удаляет ссылку, ее индексы, историю изменений и переходы
+func kvPurge(tx *bolt.Tx, code string, rec kvRecord) error {
+	if err := tx.Bucket(kvBucketShortener).Delete([]byte(code)); err != nil {
+		return err
+	}
+	urls := tx.Bucket(kvBucketURL)
+	if string(urls.Get([]byte(rec.URL))) == code {
+		if err := urls.Delete([]byte(rec.URL)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Bucket(kvBucketUser).Delete(kvUserKey(rec.User, code)); err != nil {
+		return err
+	}
+	if rec.CreatedAt != nil {
+		if err := tx.Bucket(kvBucketCreated).Delete(kvExpiresKey(*rec.CreatedAt, code)); err != nil {
+			return err
+		}
+	}
+	if err := kvDeletePrefix(tx.Bucket(kvBucketRevisions), kvCodePrefix(code)); err != nil {
+		return err
+	}
+	if err := kvPurgeClicks(tx, code); err != nil {
+		return err
+	}
+	if rec.ExpiresAt != nil {
+		if err := tx.Bucket(kvBucketExpires).Delete(kvExpiresKey(*rec.ExpiresAt, code)); err != nil {
+			return err
+		}
+	}
+	if rec.DelFlag {
+		return tx.Bucket(kvBucketDeleted).Delete(kvDeletedKey(rec.data().DeletedAt, code))
+	}
+	if err := kvAdd(tx.Bucket(kvBucketUserCount), []byte(rec.User), -1); err != nil {
+		return err
+	}
+	return kvAdd(tx.Bucket(kvBucketMeta), kvKeyURLs, -1)
+}
+
+// LeaseIDBlock выделяет блок номеров. Граница блока хранится в счетчике бакета kvBucketMeta
+func (store *StoreKV) LeaseIDBlock(_ context.Context, size uint64) (uint64, error) {
+	var start uint64
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(kvBucketMeta)
+		start = meta.Sequence() + 1
+		return meta.SetSequence(meta.Sequence() + size)
+	})
+	return start, err
+}
+
+// kvPurgeClicks удаляет переходы по ссылке и счетчики ее переходов за сутки
+func kvPurgeClicks(tx *bolt.Tx, code string) error {
+	prefix := kvCodePrefix(code)
+	days := make(map[int64]struct{})
+	c := tx.Bucket(kvBucketClicks).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		t := time.Unix(0, int64(binary.BigEndian.Uint64(k[len(prefix):])))
+		days[model.BucketStart(t, model.StatsDay).Unix()] = struct{}{}
+	}
+	for day := range days {
+		if err := tx.Bucket(kvBucketClickDays).Delete(kvDayKey(time.Unix(day, 0), code)); err != nil {
+			return err
+		}
+	}
+	return kvDeletePrefix(tx.Bucket(kvBucketClicks), prefix)
+}
+
+// kvCodePrefix - префикс ключей ссылки в бакетах переходов и истории изменений
+func kvCodePrefix(code string) []byte {
+	return append([]byte(code), kvSep)
+}
+
+// kvDayKey - ключ счетчика переходов по ссылке за сутки
+func kvDayKey(day time.Time, code string) []byte {
+	key := make([]byte, 8, 8+len(code))
+	binary.BigEndian.PutUint64(key, uint64(day.Unix()))
+	return append(key, code...)
+}
+
+// SaveClicks сохраняет переходы. Номер в ключе различает переходы в один момент.
+// Кол-во переходов за сутки ведется отдельно для статистики за период
+func (store *StoreKV) SaveClicks(_ context.Context, clicks []model.Click) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(kvBucketClicks)
+		for _, click := range clicks {
+			day := model.BucketStart(click.Time, model.StatsDay)
+			if err := kvAdd(tx.Bucket(kvBucketClickDays), kvDayKey(day, click.Code), 1); err != nil {
+				return err
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			key := kvCodePrefix(click.Code)
+			key = binary.BigEndian.AppendUint64(key, uint64(click.Time.UnixNano()))
+			key = binary.BigEndian.AppendUint64(key, seq)
+			v, err := json.Marshal(kvClick{Referrer: click.Referrer, UserAgent: click.UserAgent, IPHash: click.IPHash})
+			if err != nil {
+				return err
+			}
+			if err := b.Put(key, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetClickStats возвращает статистику переходов. Ключи ссылки упорядочены по времени
+func (store *StoreKV) GetClickStats(_ context.Context, code string, from time.Time, bucket time.Duration) (model.ClickStats, error) {
+	var stats model.ClickStats
+	err := store.db.View(func(tx *bolt.Tx) error {
+		prefix := kvCodePrefix(code)
+		var times []time.Time
+		c := tx.Bucket(kvBucketClicks).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			stats.Total++
+			t := time.Unix(0, int64(binary.BigEndian.Uint64(k[len(prefix):])))
+			if !t.Before(from) {
+				times = append(times, t)
+			}
+		}
+		stats.Buckets = clickBuckets(times, bucket)
+		return nil
+	})
+	return stats, err
+}
+
+// kvDeletePrefix удаляет ключи с префиксом
+func kvDeletePrefix(b *bolt.Bucket, prefix []byte) error {
+	// Ключи собираются заранее: удаление под курсором сдвигает его
+	var keys [][]byte
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, bytes.Clone(k))
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kvRevisionKey - ключ истории изменений
+func kvRevisionKey(code string, revision int) []byte {
+	return binary.BigEndian.AppendUint32(kvCodePrefix(code), uint32(revision))
+}
+
+// kvHistory читает историю изменений ссылки. Ключи ссылки упорядочены по номеру изменения
+func kvHistory(tx *bolt.Tx, code string) ([]model.Revision, error) {
+	var revs []model.Revision
+	prefix := kvCodePrefix(code)
+	c := tx.Bucket(kvBucketRevisions).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var rev kvRevision
+		if err := json.Unmarshal(v, &rev); err != nil {
+			return nil, err
+		}
+		revs = append(revs, model.Revision{
+			Revision:  int(binary.BigEndian.Uint32(k[len(prefix):])),
+			URL:       rev.URL,
+			CreatedAt: rev.CreatedAt,
+		})
+	}
+	return revs, nil
+}
+
+// kvPutRevision записывает изменение в историю
+func kvPutRevision(tx *bolt.Tx, code string, rev model.Revision) error {
+	v, err := json.Marshal(kvRevision{URL: rev.URL, CreatedAt: rev.CreatedAt})
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(kvBucketRevisions).Put(kvRevisionKey(code, rev.Revision), v)
+}
+
+// UpdateShortener меняет адрес назначения ссылки и индекс URL в одной транзакции
+func (store *StoreKV) UpdateShortener(_ context.Context, s model.Shortener) (model.Shortener, error) {
+	var resp model.Shortener
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		rec, ok, err := kvGet(tx, s.Key.Code)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !ok || rec.User != s.Data.User:
+			return newErrGetShortenerNotFound(s.Key.Code)
+		case rec.DelFlag:
+			return ErrGetShortenerGone
+		case rec.data().Expired(now):
+			return ErrGetShortenerExpired
+		case rec.URL == s.Data.URL:
+			resp = model.Shortener{Key: s.Key, Data: rec.data()}
+			return nil
+		}
+		urls := tx.Bucket(kvBucketURL)
+		if oldCode := urls.Get([]byte(s.Data.URL)); oldCode != nil {
+			oldRec, _, err := kvGet(tx, string(oldCode))
+			if err != nil {
+				return err
+			}
+			resp = model.Shortener{Key: model.ShortenerKey{Code: string(oldCode)}, Data: oldRec.data()}
+			return ErrSetShortenerAlreadyExists
+		}
+
+		// История: при первом изменении - и исходный адрес
+		revs, err := kvHistory(tx, s.Key.Code)
+		if err != nil {
+			return err
+		}
+		next := len(revs) + 1
+		if len(revs) == 0 {
+			if err := kvPutRevision(tx, s.Key.Code, model.Revision{Revision: 1, URL: rec.URL, CreatedAt: rec.data().CreatedAt}); err != nil {
+				return err
+			}
+			next = 2
+		}
+		if err := kvPutRevision(tx, s.Key.Code, model.Revision{Revision: next, URL: s.Data.URL, CreatedAt: now}); err != nil {
+			return err
+		}
+
+		if string(urls.Get([]byte(rec.URL))) == s.Key.Code {
+			if err := urls.Delete([]byte(rec.URL)); err != nil {
+				return err
+			}
+		}
+		if err := urls.Put([]byte(s.Data.URL), []byte(s.Key.Code)); err != nil {
+			return err
+		}
+		rec.URL = s.Data.URL
+		resp = model.Shortener{Key: s.Key, Data: rec.data()}
+		return kvPut(tx, s.Key.Code, rec)
+	})
+	return resp, err
+}
+
+// GetRevisions возвращает историю адресов ссылки
+func (store *StoreKV) GetRevisions(_ context.Context, code string) ([]model.Revision, error) {
+	var revs []model.Revision
+	err := store.db.View(func(tx *bolt.Tx) error {
+		rec, ok, err := kvGet(tx, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return newErrGetShortenerNotFound(code)
+		}
+		if revs, err = kvHistory(tx, code); err != nil || len(revs) > 0 {
+			return err
+		}
+		revs = []model.Revision{{Revision: 1, URL: rec.URL, CreatedAt: rec.data().CreatedAt}}
+		return nil
+	})
+	return revs, err
+}
+
+// GetStatsReport возвращает статистику за период.
+// Просматриваются только ключи периода в индексах, упорядоченных по времени
+func (store *StoreKV) GetStatsReport(_ context.Context, from, to time.Time, top int) (model.StatsReport, error) {
+	var report model.StatsReport
+	err := store.db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(kvBucketMeta)
+		report.URLs = kvCount(meta, kvKeyURLs)
+		report.Users = tx.Bucket(kvBucketUserCount).Stats().KeyN
+
+		// Удаленные за период ссылки
+		limit := kvExpiresKey(to, "")
+		c := tx.Bucket(kvBucketDeleted).Cursor()
+		for k, _ := c.Seek(kvExpiresKey(from, "")); k != nil && bytes.Compare(k[:8], limit) < 0; k, _ = c.Next() {
+			report.DeletedURLs++
+		}
+
+		// Созданные ссылки по суткам
+		users := make(map[string]struct{})
+		limit = kvExpiresKey(to, "")
+		c = tx.Bucket(kvBucketCreated).Cursor()
+		for k, v := c.Seek(kvExpiresKey(from, "")); k != nil && bytes.Compare(k[:8], limit) < 0; k, v = c.Next() {
+			day := model.BucketStart(time.Unix(0, int64(binary.BigEndian.Uint64(k))), model.StatsDay)
+			if n := len(report.Created); n > 0 && report.Created[n-1].Start.Equal(day) {
+				report.Created[n-1].Count++
+			} else {
+				report.Created = append(report.Created, model.TimeBucket{Start: day, Count: 1})
+			}
+			users[string(v)] = struct{}{}
+		}
+		report.ActiveUsers = len(users)
+
+		// Переходы по счетчикам суток
+		counts := make(map[string]int)
+		limit = kvDayKey(to, "")
+		c = tx.Bucket(kvBucketClickDays).Cursor()
+		for k, v := c.Seek(kvDayKey(from, "")); k != nil && bytes.Compare(k[:8], limit) < 0; k, v = c.Next() {
+			counts[string(k[8:])] += int(binary.BigEndian.Uint64(v))
+		}
+		report.TopLinks = topLinks(counts, top)
+		return nil
+	})
+	return report, err
+}
+
+// kvGetJob читает задание удаления
+func kvGetJob(tx *bolt.Tx, id string) (model.DeleteJob, bool, error) {
+	v := tx.Bucket(kvBucketJobs).Get([]byte(id))
+	if v == nil {
+		return model.DeleteJob{}, false, nil
+	}
+	var jobJSON JobJSON
+	if err := json.Unmarshal(v, &jobJSON); err != nil {
+		return model.DeleteJob{}, false, err
+	}
+	return model.DeleteJob{
+		ID:          jobJSON.ID,
+		User:        jobJSON.User,
+		Status:      jobJSON.Status,
+		Codes:       jobJSON.Codes,
+		Attempts:    jobJSON.Attempts,
+		Error:       jobJSON.Error,
+		NextAttempt: jobJSON.NextAttempt,
+		CreatedAt:   jobJSON.CreatedAt,
+		UpdatedAt:   jobJSON.UpdatedAt,
+	}, true, nil
+}
+
+// SaveDeleteJob создает или заменяет задание удаления и поддерживает индекс невыполненных заданий
+func (store *StoreKV) SaveDeleteJob(_ context.Context, job model.DeleteJob) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(kvBucketPendingJobs)
+		old, ok, err := kvGetJob(tx, job.ID)
+		if err != nil {
+			return err
+		}
+		if ok && old.Status == model.DeleteJobPending {
+			if err := pending.Delete(kvExpiresKey(old.CreatedAt, old.ID)); err != nil {
+				return err
+			}
+		}
+		if job.Status == model.DeleteJobPending {
+			if err := pending.Put(kvExpiresKey(job.CreatedAt, job.ID), nil); err != nil {
+				return err
+			}
+		}
+
+		v, err := json.Marshal(JobJSON{
+			ID:          job.ID,
+			User:        job.User,
+			Status:      job.Status,
+			Codes:       job.Codes,
+			Attempts:    job.Attempts,
+			Error:       job.Error,
+			NextAttempt: job.NextAttempt,
+			CreatedAt:   job.CreatedAt,
+			UpdatedAt:   job.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(kvBucketJobs).Put([]byte(job.ID), v)
+	})
+}
+
+// GetDeleteJob читает задание удаления
+func (store *StoreKV) GetDeleteJob(_ context.Context, id string) (model.DeleteJob, error) {
+	var job model.DeleteJob
+	err := store.db.View(func(tx *bolt.Tx) error {
+		var ok bool
+		var err error
+		job, ok, err = kvGetJob(tx, id)
+		if err == nil && !ok {
+			return ErrDeleteJobNotFound
+		}
+		return err
+	})
+	return job, err
+}
+
+// GetPendingDeleteJobs возвращает невыполненные задания удаления по индексу в порядке создания
+func (store *StoreKV) GetPendingDeleteJobs(_ context.Context) ([]model.DeleteJob, error) {
+	var jobs []model.DeleteJob
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(kvBucketPendingJobs).ForEach(func(k, _ []byte) error {
+			job, ok, err := kvGetJob(tx, string(k[8:]))
+			if err != nil || !ok {
+				return err
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// Close закрывает соединение
+func (store *StoreKV) Close() {
+	store.db.Close()
+}
@@ -0,0 +1,746 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/migrate"
+)
+
+// sqliteDSN преобразует sqlite://path в DSN драйвера.
+// WAL позволяет читать параллельно с записью, busy_timeout - ждать освобождения файла вместо ошибки,
+// _txlock=immediate - брать блокировку записи в начале транзакции, а не при первой записи,
+// foreign_keys - каскадное удаление истории изменений вместе со ссылкой
+func sqliteDSN(dsn string) string {
+	return "file:" + strings.TrimPrefix(dsn, config.SQLiteScheme) +
+		"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_txlock=immediate"
+}
+
+// OpenDB открывает базу данных по DSN и возвращает диалект ее миграций.
+// DSN вида sqlite://path - SQLite, иначе PostgreSQL
+func OpenDB(dsn string) (*sql.DB, migrate.Dialect, error) {
+	if strings.HasPrefix(dsn, config.SQLiteScheme) {
+		db, err := sql.Open("sqlite", sqliteDSN(dsn))
+		return db, migrate.SQLite, err
+	}
+	db, err := sql.Open("pgx", dsn)
+	return db, migrate.Postgres, err
+}
+
+// StoreSQLite - Реализация с хранением во встроенной базе SQLite
+type StoreSQLite struct {
+	database *sql.DB
+}
+
+// NewStoreSQLite - конструктор хранилища
+func NewStoreSQLite(cfg config.Config) (*StoreSQLite, error) {
+	db, err := sql.Open("sqlite", sqliteDSN(cfg.DBDsn))
+	if err != nil {
+		return nil, err
+	}
+
+	// Применяем миграции схемы
+	migrator, err := migrate.New(db, migrate.SQLite)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err = migrator.Up(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &StoreSQLite{
+		database: db,
+	}, nil
+}
+
+// GetShortener читает короткую ссылку
+func (store *StoreSQLite) GetShortener(code string) (model.Shortener, error) {
+	row := store.database.QueryRow(
+		"SELECT "+getShortenerColumns+" FROM shortener"+
+			" WHERE code = ?",
+		code)
+	data, ok, err := sqliteScanShortener(row)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	if err := checkShortener(code, data, ok, time.Now()); err != nil {
+		return model.Shortener{}, err
+	}
+
+	return model.Shortener{
+		Key:  model.ShortenerKey{Code: code},
+		Data: data,
+	}, nil
+}
+
+// sqliteScanShortener читает данные ссылки из строки с полями getShortenerColumns. ok = false - строки нет
+func sqliteScanShortener(row *sql.Row) (model.ShortenerData, bool, error) {
+	var data model.ShortenerData
+	var user sql.NullString
+	var expiresAt sql.NullInt64
+	err := row.Scan(&data.URL, &user, &data.DelFlag, &expiresAt, &data.PasswordHash, &data.MaxClicks, &data.ClicksLeft)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ShortenerData{}, false, nil
+	}
+	if err != nil {
+		return model.ShortenerData{}, false, err
+	}
+	data.User = user.String
+	data.ExpiresAt = sqliteTime(expiresAt)
+	return data, true, nil
+}
+
+// UseShortener учитывает переход по ссылке с ограничением кол-ва переходов одним оператором UPDATE.
+// Ссылка не изменена (нет ограничения, исчерпана, удалена) - результат чтения ссылки
+func (store *StoreSQLite) UseShortener(ctx context.Context, code string) (model.Shortener, error) {
+	row := store.database.QueryRowContext(ctx,
+		"UPDATE shortener SET clicks_left = clicks_left - 1"+
+			" WHERE code = ? AND max_clicks > 0 AND clicks_left > 0 AND NOT del_flag"+
+			" AND (expires_at IS NULL OR expires_at > ?)"+
+			" RETURNING "+getShortenerColumns,
+		code, time.Now().UnixNano())
+	data, ok, err := sqliteScanShortener(row)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	if !ok {
+		return store.GetShortener(code)
+	}
+	return model.Shortener{
+		Key:  model.ShortenerKey{Code: code},
+		Data: data,
+	}, nil
+}
+
+// sqliteUnix - срок действия в unix-времени (наносекунды), NULL для бессрочной ссылки
+func sqliteUnix(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+// sqliteTime - срок действия из unix-времени
+func sqliteTime(n sql.NullInt64) time.Time {
+	if !n.Valid {
+		return time.Time{}
+	}
+	return time.Unix(0, n.Int64)
+}
+
+// sqliteTimestampLayout - формат CURRENT_TIMESTAMP (UTC), в котором хранится время создания ссылки
+const sqliteTimestampLayout = "2006-01-02 15:04:05"
+
+// sqliteTimestamp - время в формате CURRENT_TIMESTAMP, NULL для нулевого времени
+func sqliteTimestamp(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(sqliteTimestampLayout), Valid: true}
+}
+
+// sqliteSet создает ссылку внутри транзакции
+func sqliteSet(ctx context.Context, tx *sql.Tx, row model.ShortenerBatchRow) (model.ShortenerBatchRow, error) {
+	s := row.Shortener
+
+	// Проверка: уже существует
+	var code string
+	err := tx.QueryRowContext(ctx, "SELECT code FROM shortener WHERE url = ?", s.Data.URL).Scan(&code)
+	switch {
+	case err == nil:
+		row.Shortener = model.Shortener{
+			Key:  model.ShortenerKey{Code: code},
+			Data: model.ShortenerData{URL: s.Data.URL},
+		}
+		row.Status = model.ShortenerStatusExists
+		return row, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return row, err
+	}
+
+	// Код занят другой ссылкой - вставка пропускается
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO shortener (code, url, uuid, expires_at, created_at, password_hash, max_clicks, clicks_left)"+
+			" VALUES (?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?)"+
+			" ON CONFLICT (code) DO NOTHING",
+		s.Key.Code, s.Data.URL, s.Data.User, sqliteUnix(s.Data.ExpiresAt), sqliteTimestamp(s.Data.CreatedAt), s.Data.PasswordHash,
+		s.Data.MaxClicks, s.Data.ClicksLeft)
+	if err != nil {
+		return row, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return row, err
+	}
+	if inserted == 0 {
+		row.Status = model.ShortenerStatusCodeConflict
+		return row, nil
+	}
+
+	row.Status = model.ShortenerStatusCreated
+	return row, nil
+}
+
+// SetShortener создает короткую ссылку
+func (store *StoreSQLite) SetShortener(ctx context.Context, s model.Shortener) (model.Shortener, error) {
+	resp, err := store.SetShortenerBatch(ctx, []model.ShortenerBatchRow{{Shortener: s}})
+	if err != nil {
+		return model.Shortener{}, err
+	}
+
+	switch resp[0].Status {
+	case model.ShortenerStatusExists:
+		return resp[0].Shortener, ErrSetShortenerAlreadyExists
+	case model.ShortenerStatusCodeConflict:
+		return model.Shortener{}, ErrSetShortenerCodeConflict
+	}
+	return s, nil
+}
+
+// SetShortenerBatch создает короткую ссылку для набора данных
+func (store *StoreSQLite) SetShortenerBatch(ctx context.Context, s []model.ShortenerBatchRow) ([]model.ShortenerBatchRow, error) {
+	tx, err := store.database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	resp := make([]model.ShortenerBatchRow, 0, len(s))
+	for _, reqRow := range s {
+		row, err := sqliteSet(ctx, tx, reqRow)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, row)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Ping
+func (store *StoreSQLite) Ping() error {
+	return store.database.Ping()
+}
+
+// GetShortenerBatch возвращает все ссылки, добавленные пользователем
+func (store *StoreSQLite) GetShortenerBatch(ctx context.Context, userCode string) ([]model.Shortener, error) {
+	var resp []model.Shortener
+
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT code, url, expires_at FROM shortener"+
+			" WHERE uuid = ?"+
+			" AND del_flag = FALSE"+
+			" AND (expires_at IS NULL OR expires_at > ?)",
+		userCode, time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var respRow model.Shortener
+		var expiresAt sql.NullInt64
+		err := rows.Scan(&respRow.Key.Code, &respRow.Data.URL, &expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		respRow.Data.ExpiresAt = sqliteTime(expiresAt)
+		resp = append(resp, respRow)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// DeleteShortenerBatch удаляет короткую ссылку
+func (store *StoreSQLite) DeleteShortenerBatch(ctx context.Context, s []model.Shortener) ([]model.DeleteStatus, error) {
+	tx, err := store.database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Удалить может только владелец ссылки
+	stmt, err := tx.PrepareContext(ctx,
+		"UPDATE shortener SET del_flag = TRUE, deleted_at = ?"+
+			" WHERE code = ? AND uuid = ? AND del_flag = FALSE")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UnixNano()
+	resp := make([]model.DeleteStatus, 0, len(s))
+	for _, s := range s {
+		res, err := stmt.ExecContext(ctx, now, s.Key.Code, s.Data.User)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			resp = append(resp, model.DeleteStatusDeleted)
+			continue
+		}
+
+		// Ссылка не изменилась: уже удалена или не найдена
+		var found bool
+		err = tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM shortener WHERE code = ? AND uuid = ?)",
+			s.Key.Code, s.Data.User).Scan(&found)
+		if err != nil {
+			return nil, err
+		}
+		status := model.DeleteStatusNotFound
+		if found {
+			status = model.DeleteStatusAlreadyDeleted
+		}
+		resp = append(resp, status)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetDeletedBatch возвращает удаленные ссылки пользователя
+func (store *StoreSQLite) GetDeletedBatch(ctx context.Context, userCode string) ([]model.Shortener, error) {
+	var resp []model.Shortener
+
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT code, url, expires_at, created_at, deleted_at FROM shortener"+
+			" WHERE uuid = ? AND del_flag",
+		userCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var code string
+		var expiresAt, deletedAt sql.NullInt64
+		var createdAt sql.NullTime
+		data := model.ShortenerData{User: userCode, DelFlag: true}
+		if err := rows.Scan(&code, &data.URL, &expiresAt, &createdAt, &deletedAt); err != nil {
+			return nil, err
+		}
+		data.ExpiresAt, data.CreatedAt, data.DeletedAt = sqliteTime(expiresAt), createdAt.Time, sqliteTime(deletedAt)
+		resp = append(resp, model.Shortener{Key: model.ShortenerKey{Code: code}, Data: data})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RestoreShortener восстанавливает удаленную ссылку
+func (store *StoreSQLite) RestoreShortener(ctx context.Context, s model.Shortener, deletedAfter time.Time) (model.Shortener, error) {
+	tx, err := store.database.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	defer tx.Rollback()
+
+	var data model.ShortenerData
+	var user sql.NullString
+	var expiresAt, deletedAt sql.NullInt64
+	var createdAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT url, uuid, del_flag, expires_at, created_at, deleted_at FROM shortener WHERE code = ?",
+		s.Key.Code).Scan(&data.URL, &user, &data.DelFlag, &expiresAt, &createdAt, &deletedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return model.Shortener{}, err
+	}
+	data.User, data.ExpiresAt, data.CreatedAt, data.DeletedAt = user.String, sqliteTime(expiresAt), createdAt.Time, sqliteTime(deletedAt)
+	if err := checkRestore(s.Key.Code, data, err == nil, s.Data.User, deletedAfter); err != nil {
+		return model.Shortener{}, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE shortener SET del_flag = FALSE, deleted_at = NULL WHERE code = ?", s.Key.Code)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Shortener{}, err
+	}
+
+	data.DelFlag, data.DeletedAt = false, time.Time{}
+	return model.Shortener{Key: s.Key, Data: data}, nil
+}
+
+// PurgeDeleted окончательно удаляет ссылки, удаленные раньше before
+func (store *StoreSQLite) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	return store.purge(ctx, "del_flag AND deleted_at < ?", before.UnixNano())
+}
+
+// purge окончательно удаляет ссылки по условию cond вместе с переходами в одной транзакции.
+// История удаляется каскадно
+func (store *StoreSQLite) purge(ctx context.Context, cond string, arg any) (int, error) {
+	tx, err := store.database.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM clicks WHERE code IN (SELECT code FROM shortener WHERE "+cond+")", arg)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM shortener WHERE "+cond, arg)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// GetStats возвращает статистические данные
+func (store *StoreSQLite) GetStats(ctx context.Context) (model.Stats, error) {
+	var stats model.Stats
+
+	row := store.database.QueryRowContext(ctx,
+		"SELECT count(code), count(DISTINCT uuid)"+
+			" FROM shortener"+
+			" WHERE del_flag = FALSE")
+	if err := row.Scan(&stats.URLs, &stats.Users); err != nil {
+		return model.Stats{}, err
+	}
+
+	return stats, nil
+}
+
+// DeleteExpired окончательно удаляет истекшие ссылки
+func (store *StoreSQLite) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	return store.purge(ctx, "expires_at <= ?", now.UnixNano())
+}
+
+// SaveClicks сохраняет переходы в одной транзакции
+func (store *StoreSQLite) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	tx, err := store.database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		"INSERT INTO clicks (code, clicked_at, referrer, user_agent, ip_hash) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, click := range clicks {
+		_, err := stmt.ExecContext(ctx, click.Code, click.Time.UnixNano(), click.Referrer, click.UserAgent, click.IPHash)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetClickStats возвращает статистику переходов. Интервалы группируются в базе
+func (store *StoreSQLite) GetClickStats(ctx context.Context, code string, from time.Time, bucket time.Duration) (model.ClickStats, error) {
+	var stats model.ClickStats
+	row := store.database.QueryRowContext(ctx, "SELECT count(*) FROM clicks WHERE code = ?", code)
+	if err := row.Scan(&stats.Total); err != nil {
+		return model.ClickStats{}, err
+	}
+
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT clicked_at / ? AS b, count(*) FROM clicks"+
+			" WHERE code = ? AND clicked_at >= ?"+
+			" GROUP BY b ORDER BY b",
+		int64(bucket), code, from.UnixNano())
+	if err != nil {
+		return model.ClickStats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n int64
+		var clickBucket model.TimeBucket
+		if err := rows.Scan(&n, &clickBucket.Count); err != nil {
+			return model.ClickStats{}, err
+		}
+		clickBucket.Start = time.Unix(0, n*int64(bucket)).UTC()
+		stats.Buckets = append(stats.Buckets, clickBucket)
+	}
+	if err := rows.Err(); err != nil {
+		return model.ClickStats{}, err
+	}
+	return stats, nil
+}
+
+// UpdateShortener меняет адрес назначения ссылки. Транзакция сразу берет блокировку записи
+func (store *StoreSQLite) UpdateShortener(ctx context.Context, s model.Shortener) (model.Shortener, error) {
+	tx, err := store.database.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var data model.ShortenerData
+	var user sql.NullString
+	var expiresAt sql.NullInt64
+	var createdAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT url, uuid, del_flag, expires_at, created_at FROM shortener WHERE code = ?",
+		s.Key.Code).Scan(&data.URL, &user, &data.DelFlag, &expiresAt, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Shortener{}, newErrGetShortenerNotFound(s.Key.Code)
+	}
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	data.User, data.ExpiresAt, data.CreatedAt = user.String, sqliteTime(expiresAt), createdAt.Time
+	switch {
+	case data.User != s.Data.User:
+		return model.Shortener{}, newErrGetShortenerNotFound(s.Key.Code)
+	case data.DelFlag:
+		return model.Shortener{}, ErrGetShortenerGone
+	case data.Expired(now):
+		return model.Shortener{}, ErrGetShortenerExpired
+	case data.URL == s.Data.URL:
+		return model.Shortener{Key: s.Key, Data: data}, nil
+	}
+
+	var oldCode string
+	err = tx.QueryRowContext(ctx, "SELECT code FROM shortener WHERE url = ?", s.Data.URL).Scan(&oldCode)
+	if err == nil {
+		return model.Shortener{
+			Key:  model.ShortenerKey{Code: oldCode},
+			Data: model.ShortenerData{URL: s.Data.URL},
+		}, ErrSetShortenerAlreadyExists
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return model.Shortener{}, err
+	}
+
+	// История: исходный адрес записывается при первом изменении
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO shortener_revisions (code, revision, url, created_at)"+
+			" VALUES (?, 1, ?, ?) ON CONFLICT DO NOTHING",
+		s.Key.Code, data.URL, sqliteUnix(data.CreatedAt))
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO shortener_revisions (code, revision, url, created_at)"+
+			" SELECT ?, max(revision) + 1, ?, ? FROM shortener_revisions WHERE code = ?",
+		s.Key.Code, s.Data.URL, now.UnixNano(), s.Key.Code)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE shortener SET url = ? WHERE code = ?", s.Data.URL, s.Key.Code); err != nil {
+		return model.Shortener{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Shortener{}, err
+	}
+
+	data.URL = s.Data.URL
+	return model.Shortener{Key: s.Key, Data: data}, nil
+}
+
+// GetRevisions возвращает историю адресов ссылки
+func (store *StoreSQLite) GetRevisions(ctx context.Context, code string) ([]model.Revision, error) {
+	var url string
+	var createdAt sql.NullTime
+	err := store.database.QueryRowContext(ctx, "SELECT url, created_at FROM shortener WHERE code = ?", code).Scan(&url, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newErrGetShortenerNotFound(code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT revision, url, created_at FROM shortener_revisions WHERE code = ? ORDER BY revision",
+		code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var revs []model.Revision
+	for rows.Next() {
+		var rev model.Revision
+		var revCreatedAt sql.NullInt64
+		if err := rows.Scan(&rev.Revision, &rev.URL, &revCreatedAt); err != nil {
+			return nil, err
+		}
+		rev.CreatedAt = sqliteTime(revCreatedAt)
+		revs = append(revs, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		revs = []model.Revision{{Revision: 1, URL: url, CreatedAt: createdAt.Time}}
+	}
+	return revs, nil
+}
+
+// GetStatsReport возвращает статистику за период. Агрегаты считаются в базе по индексам времени
+func (store *StoreSQLite) GetStatsReport(ctx context.Context, from, to time.Time, top int) (model.StatsReport, error) {
+	var report model.StatsReport
+	row := store.database.QueryRowContext(ctx,
+		"SELECT count(*) FILTER (WHERE NOT del_flag),"+
+			" count(DISTINCT uuid) FILTER (WHERE NOT del_flag),"+
+			" count(*) FILTER (WHERE del_flag AND deleted_at >= ? AND deleted_at < ?)"+
+			" FROM shortener", from.UnixNano(), to.UnixNano())
+	if err := row.Scan(&report.URLs, &report.Users, &report.DeletedURLs); err != nil {
+		return model.StatsReport{}, err
+	}
+	fromTS, toTS := sqliteTimestamp(from), sqliteTimestamp(to)
+	row = store.database.QueryRowContext(ctx,
+		"SELECT count(DISTINCT uuid) FROM shortener WHERE created_at >= ? AND created_at < ?",
+		fromTS, toTS)
+	if err := row.Scan(&report.ActiveUsers); err != nil {
+		return model.StatsReport{}, err
+	}
+
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT CAST(strftime('%s', created_at) AS INTEGER) / ? AS d, count(*) FROM shortener"+
+			" WHERE created_at >= ? AND created_at < ?"+
+			" GROUP BY d ORDER BY d",
+		int64(model.StatsDay.Seconds()), fromTS, toTS)
+	if err != nil {
+		return model.StatsReport{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n int64
+		var day model.TimeBucket
+		if err := rows.Scan(&n, &day.Count); err != nil {
+			return model.StatsReport{}, err
+		}
+		day.Start = time.Unix(0, n*int64(model.StatsDay)).UTC()
+		report.Created = append(report.Created, day)
+	}
+	if err := rows.Err(); err != nil {
+		return model.StatsReport{}, err
+	}
+
+	rows, err = store.database.QueryContext(ctx,
+		"SELECT code, count(*) AS n FROM clicks"+
+			" WHERE clicked_at >= ? AND clicked_at < ?"+
+			" GROUP BY code ORDER BY n DESC, code LIMIT ?",
+		from.UnixNano(), to.UnixNano(), top)
+	if err != nil {
+		return model.StatsReport{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var link model.LinkClicks
+		if err := rows.Scan(&link.Code, &link.Clicks); err != nil {
+			return model.StatsReport{}, err
+		}
+		report.TopLinks = append(report.TopLinks, link)
+	}
+	if err := rows.Err(); err != nil {
+		return model.StatsReport{}, err
+	}
+	return report, nil
+}
+
+// LeaseIDBlock выделяет блок номеров. Транзакция записи SQLite сериализует выделение между процессами
+func (store *StoreSQLite) LeaseIDBlock(ctx context.Context, size uint64) (uint64, error) {
+	var leased int64
+	row := store.database.QueryRowContext(ctx,
+		"UPDATE id_allocator SET leased = leased + ?"+
+			" WHERE id = 1"+
+			" RETURNING leased",
+		int64(size))
+	if err := row.Scan(&leased); err != nil {
+		return 0, err
+	}
+	return uint64(leased) - size + 1, nil
+}
+
+// SaveDeleteJob создает или заменяет задание удаления
+func (store *StoreSQLite) SaveDeleteJob(ctx context.Context, job model.DeleteJob) error {
+	codes, err := json.Marshal(job.Codes)
+	if err != nil {
+		return err
+	}
+	_, err = store.database.ExecContext(ctx,
+		"INSERT INTO delete_jobs (id, uuid, status, codes, attempts, error, next_attempt, created_at, updated_at)"+
+			" VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"+
+			" ON CONFLICT (id) DO UPDATE SET status = excluded.status, codes = excluded.codes,"+
+			"   attempts = excluded.attempts, error = excluded.error,"+
+			"   next_attempt = excluded.next_attempt, updated_at = excluded.updated_at",
+		job.ID, job.User, string(job.Status), string(codes), job.Attempts, job.Error,
+		job.NextAttempt.UnixNano(), job.CreatedAt.UnixNano(), job.UpdatedAt.UnixNano())
+	return err
+}
+
+// sqliteScanDeleteJob читает задание удаления из строки результата (поля deleteJobColumns)
+func sqliteScanDeleteJob(row interface{ Scan(dest ...any) error }) (model.DeleteJob, error) {
+	var job model.DeleteJob
+	var status, codes string
+	var nextAttempt, createdAt, updatedAt int64
+	err := row.Scan(&job.ID, &job.User, &status, &codes, &job.Attempts, &job.Error,
+		&nextAttempt, &createdAt, &updatedAt)
+	if err != nil {
+		return model.DeleteJob{}, err
+	}
+	job.Status = model.DeleteJobStatus(status)
+	job.NextAttempt, job.CreatedAt, job.UpdatedAt = time.Unix(0, nextAttempt), time.Unix(0, createdAt), time.Unix(0, updatedAt)
+	if err := json.Unmarshal([]byte(codes), &job.Codes); err != nil {
+		return model.DeleteJob{}, err
+	}
+	return job, nil
+}
+
+// GetDeleteJob читает задание удаления
+func (store *StoreSQLite) GetDeleteJob(ctx context.Context, id string) (model.DeleteJob, error) {
+	row := store.database.QueryRowContext(ctx,
+		"SELECT "+deleteJobColumns+" FROM delete_jobs WHERE id = ?", id)
+	job, err := sqliteScanDeleteJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DeleteJob{}, ErrDeleteJobNotFound
+	}
+	return job, err
+}
+
+// GetPendingDeleteJobs возвращает невыполненные задания удаления
+func (store *StoreSQLite) GetPendingDeleteJobs(ctx context.Context) ([]model.DeleteJob, error) {
+	rows, err := store.database.QueryContext(ctx,
+		"SELECT "+deleteJobColumns+" FROM delete_jobs WHERE status = ? ORDER BY created_at, id",
+		string(model.DeleteJobPending))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []model.DeleteJob
+	for rows.Next() {
+		job, err := sqliteScanDeleteJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Close закрывает соединение
+func (store *StoreSQLite) Close() {
+	store.database.Close()
+}
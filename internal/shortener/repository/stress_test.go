@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+)
+
+// stressRepository одновременно вызывает все методы хранилища и проверяет итоговое состояние.
+// Имеет смысл с go test -race
+func stressRepository(t *testing.T, store Repository) {
+	const workers = 16
+	const perWorker = 200
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			user := fmt.Sprintf("user%d", w)
+			for i := 0; i < perWorker; i++ {
+				s := model.Shortener{
+					Key:  model.ShortenerKey{Code: fmt.Sprintf("w%di%d", w, i)},
+					Data: model.ShortenerData{URL: fmt.Sprintf("https://example.com/%d/%d", w, i), User: user},
+				}
+				if _, err := store.SetShortener(ctx, s); err != nil {
+					t.Errorf("set %s: %s", s.Key.Code, err)
+					return
+				}
+
+				// Один и тот же URL от всех исполнителей - создается ровно один раз
+				store.SetShortener(ctx, model.Shortener{
+					Key:  model.ShortenerKey{Code: fmt.Sprintf("shared-w%di%d", w, i)},
+					Data: model.ShortenerData{URL: fmt.Sprintf("https://example.com/shared/%d", i), User: "shared"},
+				})
+
+				if _, err := store.GetShortener(s.Key.Code); err != nil {
+					t.Errorf("get %s: %s", s.Key.Code, err)
+					return
+				}
+				if i%2 == 1 {
+					if _, err := store.DeleteShortenerBatch(ctx, []model.Shortener{s}); err != nil {
+						t.Errorf("delete %s: %s", s.Key.Code, err)
+						return
+					}
+				}
+				if i%10 == 0 {
+					if _, err := store.GetShortenerBatch(ctx, user); err != nil {
+						t.Error(err)
+						return
+					}
+					if _, err := store.GetStats(ctx); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Половина собственных ссылок удалена, общие URL созданы по одному разу
+	for w := 0; w < workers; w++ {
+		links, err := store.GetShortenerBatch(ctx, fmt.Sprintf("user%d", w))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(links) != perWorker/2 {
+			t.Errorf("user%d has %d links, want %d", w, len(links), perWorker/2)
+		}
+	}
+	shared, err := store.GetShortenerBatch(ctx, "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shared) != perWorker {
+		t.Errorf("shared urls stored %d times, want %d", len(shared), perWorker)
+	}
+	stats, err := store.GetStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (model.Stats{URLs: workers*perWorker/2 + perWorker, Users: workers + 1}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestStoreVar_Concurrent(t *testing.T) {
+	store, err := NewStoreVar(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stressRepository(t, store)
+}
+
+func TestStoreFile_Concurrent(t *testing.T) {
+	store, err := NewStoreFile(config.Config{Filename: filepath.Join(t.TempDir(), "store.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	stressRepository(t, store)
+}
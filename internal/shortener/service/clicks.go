@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+)
+
+// Ошибки статистики переходов
+var (
+	ErrNotOwner          = errors.New("link is not owned by user")
+	ErrInvalidStatsRange = errors.New("invalid stats range")
+)
+
+// Параметры записи переходов
+const (
+	// clickQueueSize - размер очереди переходов. Переходы сверх очереди не учитываются
+	clickQueueSize = 1024
+	// clickBatchSize - кол-во переходов, при котором пакет записывается не дожидаясь таймера
+	clickBatchSize = 100
+	// clickFlushInterval - периодичность записи накопленных переходов
+	clickFlushInterval = time.Second
+	// maxClickBuckets - предельное кол-во интервалов в статистике
+	maxClickBuckets = 1000
+)
+
+// RecordClick ставит переход по ссылке в очередь записи. Не блокирует переход:
+// при переполненной очереди переход не учитывается
+func (service *Shortener) RecordClick(code, referrer, userAgent, ip string) {
+	click := model.Click{
+		Code:      code,
+		Time:      time.Now(),
+		Referrer:  referrer,
+		UserAgent: userAgent,
+		IPHash:    service.hashIP(ip),
+	}
+	select {
+	case service.clicks <- click:
+	default:
+		service.droppedClicks.Add(1)
+	}
+}
+
+// clickSaltSize - длина случайной соли хэша адреса клиента в байтах
+const clickSaltSize = 32
+
+// newClickSalt - случайная соль хэша адреса клиента.
+// Без соли хэш адреса IPv4 восстанавливается перебором
+func newClickSalt() (string, error) {
+	salt := make([]byte, clickSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(salt), nil
+}
+
+// hashIP - хэш адреса клиента с солью
+func (service *Shortener) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(service.clickSalt + ip))
+	return hex.EncodeToString(sum[:16])
+}
+
+// flushClicks записывает переходы в хранилище пакетами
+func (service *Shortener) flushClicks() {
+	defer service.wg.Done()
+	ctx := context.Background()
+	ticker := time.NewTicker(clickFlushInterval)
+	defer ticker.Stop()
+
+	var batch []model.Click
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		service.store.SaveClicks(ctx, batch)
+		batch = nil
+	}
+
+	for {
+		select {
+		// Завершение работы: запись оставшихся в очереди переходов
+		case <-service.done:
+			for {
+				select {
+				case click := <-service.clicks:
+					batch = append(batch, click)
+				default:
+					flush()
+					return
+				}
+			}
+		case click := <-service.clicks:
+			batch = append(batch, click)
+			if len(batch) >= clickBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// GetClickStats возвращает статистику переходов по ссылке пользователя с момента from
+// по интервалам длины bucket, включая интервалы без переходов
+func (service *Shortener) GetClickStats(ctx context.Context, userCode, code string, from time.Time, bucket time.Duration) (model.ClickStats, error) {
+	now := time.Now()
+	if bucket < time.Minute {
+		return model.ClickStats{}, fmt.Errorf("%w: bucket must be at least 1m", ErrInvalidStatsRange)
+	}
+	if from.After(now) {
+		return model.ClickStats{}, fmt.Errorf("%w: from is in the future", ErrInvalidStatsRange)
+	}
+	if now.Sub(from)/bucket >= maxClickBuckets {
+		return model.ClickStats{}, fmt.Errorf("%w: more than %d buckets", ErrInvalidStatsRange, maxClickBuckets)
+	}
+
+	// Статистика доступна только владельцу ссылки
+	if err := service.checkOwner(userCode, code); err != nil {
+		return model.ClickStats{}, err
+	}
+
+	from = model.BucketStart(from, bucket)
+	stats, err := service.store.GetClickStats(ctx, code, from, bucket)
+	if err != nil {
+		return model.ClickStats{}, err
+	}
+	stats.Buckets = denseBuckets(stats.Buckets, from, now, bucket)
+	return stats, nil
+}
+
+// denseBuckets дополняет непустые интервалы пустыми от from до now
+func denseBuckets(buckets []model.TimeBucket, from, now time.Time, bucket time.Duration) []model.TimeBucket {
+	resp := make([]model.TimeBucket, 0, now.Sub(from)/bucket+1)
+	i := 0
+	for start := from; !start.After(now); start = start.Add(bucket) {
+		clickBucket := model.TimeBucket{Start: start}
+		if i < len(buckets) && buckets[i].Start.Equal(start) {
+			clickBucket.Count = buckets[i].Count
+			i++
+		}
+		resp = append(resp, clickBucket)
+	}
+	return resp
+}
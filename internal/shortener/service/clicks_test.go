@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/service/config"
+)
+
+func TestService_Clicks(t *testing.T) {
+	ctx := context.Background()
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	shortenerService, err := NewShortener(config.Config{ClickSalt: "salt"}, store)
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+
+	shortenerService.RecordClick(code, "https://example.com/", "test", "203.0.113.1")
+	shortenerService.RecordClick(code, "", "", "203.0.113.1")
+
+	// Переходы записываются асинхронно
+	from := time.Now().Add(-time.Hour)
+	require.Eventually(t, func() bool {
+		stats, err := shortenerService.GetClickStats(ctx, "user1", code, from, time.Hour)
+		return err == nil && stats.Total == 2
+	}, 3*time.Second, 50*time.Millisecond)
+
+	stats, err := shortenerService.GetClickStats(ctx, "user1", code, from, 10*time.Minute)
+	require.NoError(t, err)
+	// Интервалы без переходов включены в ответ
+	require.GreaterOrEqual(t, len(stats.Buckets), 6)
+	var total int
+	for _, bucket := range stats.Buckets {
+		total += bucket.Count
+	}
+	require.Equal(t, 2, total)
+
+	// Адрес клиента хранится только в виде хэша
+	require.Equal(t, shortenerService.hashIP("203.0.113.1"), shortenerService.hashIP("203.0.113.1"))
+	require.NotContains(t, shortenerService.hashIP("203.0.113.1"), "203.0.113.1")
+
+	// Статистика доступна только владельцу
+	_, err = shortenerService.GetClickStats(ctx, "user2", code, from, time.Hour)
+	require.ErrorIs(t, err, ErrNotOwner)
+	_, err = shortenerService.GetClickStats(ctx, "user1", "unknown", from, time.Hour)
+	require.ErrorIs(t, err, repository.ErrGetShortenerNotFound)
+
+	// Некорректный период
+	_, err = shortenerService.GetClickStats(ctx, "user1", code, from, time.Second)
+	require.ErrorIs(t, err, ErrInvalidStatsRange)
+	_, err = shortenerService.GetClickStats(ctx, "user1", code, time.Now().Add(-365*24*time.Hour), time.Minute)
+	require.ErrorIs(t, err, ErrInvalidStatsRange)
+}
+
+func TestService_ClickSaltDefault(t *testing.T) {
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	shortenerService, err := NewShortener(config.Config{}, store)
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	// Без заданной соли хэш адреса не совпадает с хэшем без соли
+	require.Len(t, shortenerService.clickSalt, 2*clickSaltSize)
+	sum := sha256.Sum256([]byte("203.0.113.1"))
+	require.NotEqual(t, hex.EncodeToString(sum[:16]), shortenerService.hashIP("203.0.113.1"))
+}
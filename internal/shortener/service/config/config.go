@@ -0,0 +1,57 @@
+package config
+
+import (
+	"time"
+
+	policyConfig "github.com/iurnickita/vigilant-train/internal/shortener/policy/config"
+)
+
+// Способы генерации кода ссылки
+const (
+	// GeneratorRandom - случайный код (crypto/rand)
+	GeneratorRandom string = "random"
+	// GeneratorSequence - порядковый номер в системе счисления алфавита
+	GeneratorSequence string = "sequence"
+	// GeneratorHash - код из хэша URL: повторное сокращение URL дает тот же код
+	GeneratorHash string = "hash"
+	// GeneratorHashids - порядковый номер, перемешанный с солью (в стиле Hashids)
+	GeneratorHashids string = "hashids"
+)
+
+// Значения по умолчанию
+const (
+	DefaultCodeLength   = 6
+	DefaultCodeAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	DefaultCodeAttempts = 5
+	// DefaultCodeBlockSize - кол-во номеров, выделяемых хранилищем за один запрос
+	DefaultCodeBlockSize = 100
+	// DefaultTrashGrace - срок, в течение которого удаленную ссылку можно восстановить
+	DefaultTrashGrace = 7 * 24 * time.Hour
+	// DefaultTrashRetention - срок хранения удаленной ссылки до окончательного удаления
+	DefaultTrashRetention = 30 * 24 * time.Hour
+)
+
+// Конфигурация service
+type Config struct {
+	// CodeGenerator - способ генерации кода (пусто - GeneratorRandom)
+	CodeGenerator string
+	// CodeLength - длина кода (для sequence - минимальная)
+	CodeLength int
+	// CodeAlphabet - символы кода
+	CodeAlphabet string
+	// CodeSalt - соль для hash и hashids
+	CodeSalt string
+	// CodeAttempts - кол-во попыток подобрать свободный код
+	CodeAttempts int
+	// CodeBlockSize - кол-во номеров для sequence и hashids, выделяемых хранилищем за один запрос
+	CodeBlockSize uint64
+	// ClickSalt - соль хэша адреса клиента в статистике переходов.
+	// Пусто - случайная соль при запуске: хэши одного адреса не совпадают между перезапусками и экземплярами
+	ClickSalt string
+	// TrashGrace - срок восстановления удаленной ссылки
+	TrashGrace time.Duration
+	// TrashRetention - срок хранения удаленной ссылки до окончательного удаления (не меньше TrashGrace)
+	TrashRetention time.Duration
+	// Policy - политика адресов назначения
+	Policy policyConfig.Config
+}
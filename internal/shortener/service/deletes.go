@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/common/rand"
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+)
+
+// Параметры выполнения заданий удаления
+const (
+	// deleteInterval - периодичность выполнения накопленных заданий
+	deleteInterval = 3 * time.Second
+	// deleteRetryBase - пауза перед первым повтором, далее удваивается до deleteRetryMax
+	deleteRetryBase = time.Second
+	deleteRetryMax  = time.Minute
+	// deleteMaxAttempts - кол-во неудачных попыток, после которого задание завершается с ошибкой
+	deleteMaxAttempts = 8
+	// deleteJobIDLength - длина ID задания
+	deleteJobIDLength = 16
+)
+
+// DeleteShortenerBatch ставит в очередь удаление ссылок пользователя и возвращает задание удаления.
+// Задание сохраняется в хранилище до ответа, повторяющиеся коды удаляются один раз.
+// Пока задание с тем же набором кодов ожидает выполнения, новое не создается - возвращается прежнее
+func (service *Shortener) DeleteShortenerBatch(ctx context.Context, userCode string, codes []string) (model.DeleteJob, error) {
+	if userCode == "" {
+		return model.DeleteJob{}, ErrNotOwner
+	}
+
+	jobCodes := make([]model.DeleteJobCode, 0, len(codes))
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		if !seen[code] {
+			seen[code] = true
+			jobCodes = append(jobCodes, model.DeleteJobCode{Code: code})
+		}
+	}
+
+	service.deleteMux.Lock()
+	defer service.deleteMux.Unlock()
+
+	key := deleteJobKey(userCode, jobCodes)
+	if id, ok := service.deleteKeys[key]; ok {
+		return service.deleteJobs[id], nil
+	}
+
+	now := time.Now()
+	job := model.DeleteJob{
+		ID:          rand.String(deleteJobIDLength),
+		User:        userCode,
+		Status:      model.DeleteJobPending,
+		Codes:       jobCodes,
+		NextAttempt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := service.store.SaveDeleteJob(ctx, job); err != nil {
+		return model.DeleteJob{}, err
+	}
+	service.deleteJobs[job.ID] = job
+	service.deleteKeys[key] = job.ID
+	return job, nil
+}
+
+// GetDeleteJob возвращает задание удаления пользователя.
+// Чужое задание не отличается от несуществующего
+func (service *Shortener) GetDeleteJob(ctx context.Context, userCode, id string) (model.DeleteJob, error) {
+	job, err := service.store.GetDeleteJob(ctx, id)
+	if err != nil {
+		return model.DeleteJob{}, err
+	}
+	if userCode == "" || job.User != userCode {
+		return model.DeleteJob{}, repository.ErrDeleteJobNotFound
+	}
+	return job, nil
+}
+
+// deleteJobKey - ключ исключения повторов: пользователь и набор кодов без учета порядка
+func deleteJobKey(userCode string, codes []model.DeleteJobCode) string {
+	keys := make([]string, 0, len(codes)+1)
+	for _, code := range codes {
+		keys = append(keys, code.Code)
+	}
+	slices.Sort(keys)
+	return strings.Join(append([]string{userCode}, keys...), "\x00")
+}
+
+// deleteBackoff - пауза перед следующей попыткой после attempts неудачных
+func deleteBackoff(attempts int) time.Duration {
+	backoff := deleteRetryBase
+	for i := 1; i < attempts && backoff < deleteRetryMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, deleteRetryMax)
+}
+
+// loadDeletes восстанавливает невыполненные задания удаления из хранилища
+func (service *Shortener) loadDeletes(ctx context.Context) error {
+	jobs, err := service.store.GetPendingDeleteJobs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		service.deleteJobs[job.ID] = job
+		service.deleteKeys[deleteJobKey(job.User, job.Codes)] = job.ID
+	}
+	return nil
+}
+
+// flushDeletes периодически выполняет задания удаления, время попытки которых наступило.
+// При завершении работы выполняется последняя попытка для всех невыполненных заданий,
+// неудавшиеся останутся в хранилище до следующего запуска
+func (service *Shortener) flushDeletes(interval time.Duration) {
+	defer service.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-service.done:
+			service.executeDeletes(time.Now(), true)
+			return
+		case now := <-ticker.C:
+			service.executeDeletes(now, false)
+		}
+	}
+}
+
+// executeDeletes выполняет задания, время попытки которых наступило к моменту now
+// (all - все невыполненные задания), и сохраняет результат каждого задания.
+// Каждое задание удаляется отдельным обращением к хранилищу: ошибка одного не откладывает остальные
+func (service *Shortener) executeDeletes(now time.Time, all bool) {
+	service.deleteMux.Lock()
+	var jobs []model.DeleteJob
+	for _, job := range service.deleteJobs {
+		if all || !job.NextAttempt.After(now) {
+			jobs = append(jobs, job)
+		}
+	}
+	service.deleteMux.Unlock()
+	slices.SortFunc(jobs, func(a, b model.DeleteJob) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	for _, job := range jobs {
+		service.executeDelete(job)
+	}
+}
+
+// executeDelete выполняет задание удаления и сохраняет его результат
+func (service *Shortener) executeDelete(job model.DeleteJob) {
+	ctx := context.Background()
+
+	batch := make([]model.Shortener, 0, len(job.Codes))
+	for _, code := range job.Codes {
+		batch = append(batch, model.Shortener{Key: model.ShortenerKey{Code: code.Code}, Data: model.ShortenerData{User: job.User}})
+	}
+	statuses, err := service.store.DeleteShortenerBatch(ctx, batch)
+
+	now := time.Now()
+	prev := job
+	job.UpdatedAt = now
+	if err != nil {
+		job.Attempts++
+		job.Error = err.Error()
+		job.NextAttempt = now.Add(deleteBackoff(job.Attempts))
+		if job.Attempts >= deleteMaxAttempts {
+			job.Status = model.DeleteJobFailed
+		}
+	} else {
+		job.Codes = slices.Clone(job.Codes)
+		for i := range job.Codes {
+			job.Codes[i].Status = statuses[i]
+		}
+		job.Status = model.DeleteJobDone
+	}
+
+	if saveErr := service.store.SaveDeleteJob(ctx, job); saveErr != nil {
+		// Результат не сохранен: задание повторяется позже
+		prev.NextAttempt = now.Add(deleteBackoff(prev.Attempts + 1))
+		job = prev
+	}
+	service.deleteMux.Lock()
+	if job.Status == model.DeleteJobPending {
+		service.deleteJobs[job.ID] = job
+	} else {
+		delete(service.deleteJobs, job.ID)
+		delete(service.deleteKeys, deleteJobKey(job.User, job.Codes))
+	}
+	service.deleteMux.Unlock()
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/service/config"
+)
+
+// failingDeleteStore - хранилище, в котором удаление ссылок не удается
+type failingDeleteStore struct {
+	repository.Repository
+	// failCode - код, удаление которого не удается; пусто - любое удаление
+	failCode string
+}
+
+func (store failingDeleteStore) DeleteShortenerBatch(ctx context.Context, s []model.Shortener) ([]model.DeleteStatus, error) {
+	for _, shortener := range s {
+		if store.failCode == "" || shortener.Key.Code == store.failCode {
+			return nil, errors.New("store is unavailable")
+		}
+	}
+	return store.Repository.DeleteShortenerBatch(ctx, s)
+}
+
+func TestService_DeleteJobs(t *testing.T) {
+	ctx := context.Background()
+	storeCfg := repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeFile, Filename: filepath.Join(t.TempDir(), "store.json")}
+	store, err := repository.NewStore(storeCfg)
+	require.NoError(t, err)
+	shortenerService, err := NewShortener(config.Config{}, store)
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+
+	// Повторяющиеся коды удаляются один раз, тот же набор кодов - то же задание
+	job, err := shortenerService.DeleteShortenerBatch(ctx, "user1", []string{code, "unknown", code})
+	require.NoError(t, err)
+	require.Equal(t, model.DeleteJobPending, job.Status)
+	require.Len(t, job.Codes, 2)
+	again, err := shortenerService.DeleteShortenerBatch(ctx, "user1", []string{"unknown", code})
+	require.NoError(t, err)
+	require.Equal(t, job.ID, again.ID)
+
+	// Задание видно только владельцу
+	_, err = shortenerService.GetDeleteJob(ctx, "user2", job.ID)
+	require.ErrorIs(t, err, repository.ErrDeleteJobNotFound)
+	_, err = shortenerService.DeleteShortenerBatch(ctx, "", []string{code})
+	require.ErrorIs(t, err, ErrNotOwner)
+
+	// Задания выполняются при завершении работы, результат сохраняется в хранилище
+	shortenerService.Shutdown()
+	store, err = repository.NewStore(storeCfg)
+	require.NoError(t, err)
+	shortenerService, err = NewShortener(config.Config{}, store)
+	require.NoError(t, err)
+	defer shortenerService.Shutdown()
+
+	job, err = shortenerService.GetDeleteJob(ctx, "user1", job.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.DeleteJobDone, job.Status)
+	require.Equal(t, []model.DeleteJobCode{
+		{Code: code, Status: model.DeleteStatusDeleted},
+		{Code: "unknown", Status: model.DeleteStatusNotFound},
+	}, job.Codes)
+	_, err = shortenerService.GetShortener(code)
+	require.ErrorIs(t, err, repository.ErrGetShortenerGone)
+
+	// После выполнения тот же набор кодов - новое задание
+	again, err = shortenerService.DeleteShortenerBatch(ctx, "user1", []string{code, "unknown"})
+	require.NoError(t, err)
+	require.NotEqual(t, job.ID, again.ID)
+}
+
+func TestService_DeleteJobsRetry(t *testing.T) {
+	ctx := context.Background()
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	shortenerService, err := NewShortener(config.Config{}, failingDeleteStore{Repository: store})
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	job, err := shortenerService.DeleteShortenerBatch(ctx, "user1", []string{"code01"})
+	require.NoError(t, err)
+
+	// Неудачная попытка откладывает задание
+	shortenerService.Shutdown()
+	job, err = store.GetDeleteJob(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.DeleteJobPending, job.Status)
+	require.Equal(t, 1, job.Attempts)
+	require.Equal(t, "store is unavailable", job.Error)
+	require.True(t, job.NextAttempt.After(job.CreatedAt))
+
+	// Невыполненное задание восстанавливается при запуске
+	shortenerService, err = NewShortener(config.Config{}, store)
+	require.NoError(t, err)
+	shortenerService.Shutdown()
+	job, err = store.GetDeleteJob(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.DeleteJobDone, job.Status)
+	require.Equal(t, model.DeleteStatusNotFound, job.Codes[0].Status)
+
+	require.Equal(t, deleteRetryBase, deleteBackoff(1))
+	require.Equal(t, 4*deleteRetryBase, deleteBackoff(3))
+	require.Equal(t, deleteRetryMax, deleteBackoff(deleteMaxAttempts))
+}
+
+func TestService_DeleteJobsIsolated(t *testing.T) {
+	ctx := context.Background()
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	shortenerService, err := NewShortener(config.Config{}, failingDeleteStore{Repository: store, failCode: "broken"})
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+	failed, err := shortenerService.DeleteShortenerBatch(ctx, "user1", []string{"broken"})
+	require.NoError(t, err)
+	done, err := shortenerService.DeleteShortenerBatch(ctx, "user1", []string{resp.Key.Code})
+	require.NoError(t, err)
+
+	// Ошибка одного задания не откладывает другое
+	shortenerService.Shutdown()
+	failed, err = store.GetDeleteJob(ctx, failed.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.DeleteJobPending, failed.Status)
+	require.Equal(t, 1, failed.Attempts)
+	done, err = store.GetDeleteJob(ctx, done.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.DeleteJobDone, done.Status)
+	require.Zero(t, done.Attempts)
+	require.Equal(t, model.DeleteStatusDeleted, done.Codes[0].Status)
+}
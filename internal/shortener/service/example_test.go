@@ -12,6 +12,9 @@ func ExampleService() {
 	if err != nil {
 		//return err
 	}
-	shortenerService := NewShortener(store)
+	shortenerService, err := NewShortener(cfg.Service, store)
+	if err != nil {
+		//return err
+	}
 	shortenerService.Ping()
 }
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	mathrand "math/rand/v2"
+	"strconv"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/service/config"
+)
+
+// CodeGenerator - способ генерации кода ссылки. Реализации безопасны для конкурентного использования
+type CodeGenerator interface {
+	// Generate возвращает код для URL. attempt - номер попытки, начиная с 0:
+	// если код занят, вызов повторяется с attempt+1
+	Generate(ctx context.Context, url string, attempt int) (string, error)
+}
+
+// ErrInvalidGenerator - некорректная конфигурация генератора кодов
+var ErrInvalidGenerator = errors.New("invalid code generator")
+
+// NewCodeGenerator - конструктор генератора кодов по конфигурации.
+// ids - источник номеров для генераторов sequence и hashids
+func NewCodeGenerator(cfg config.Config, ids IDSource) (CodeGenerator, error) {
+	length := cfg.CodeLength
+	if length == 0 {
+		length = config.DefaultCodeLength
+	}
+	alphabet := cfg.CodeAlphabet
+	if alphabet == "" {
+		alphabet = config.DefaultCodeAlphabet
+	}
+	if length < 1 || length > aliasMaxLen {
+		return nil, fmt.Errorf("%w: length must be between 1 and %d", ErrInvalidGenerator, aliasMaxLen)
+	}
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+
+	switch cfg.CodeGenerator {
+	case "", config.GeneratorRandom:
+		return &randomGenerator{alphabet: alphabet, length: length}, nil
+	case config.GeneratorSequence:
+		return &sequenceGenerator{alphabet: alphabet, length: length, ids: ids}, nil
+	case config.GeneratorHash:
+		return &hashGenerator{alphabet: alphabet, length: length, salt: cfg.CodeSalt}, nil
+	case config.GeneratorHashids:
+		return newHashidsGenerator(alphabet, length, cfg.CodeSalt, ids), nil
+	}
+	return nil, fmt.Errorf("%w: unknown generator %q", ErrInvalidGenerator, cfg.CodeGenerator)
+}
+
+// validateAlphabet проверяет алфавит: не менее двух различных символов, допустимых в пользовательском коде
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return fmt.Errorf("%w: alphabet must contain at least 2 characters", ErrInvalidGenerator)
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, c := range alphabet {
+		if !aliasChar(c) {
+			return fmt.Errorf("%w: unexpected alphabet character %q", ErrInvalidGenerator, c)
+		}
+		if seen[c] {
+			return fmt.Errorf("%w: duplicate alphabet character %q", ErrInvalidGenerator, c)
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+// encode записывает n в системе счисления алфавита, дополняя слева до length символов
+func encode(alphabet string, n uint64, length int) string {
+	base := uint64(len(alphabet))
+	var buf []byte
+	for ; n > 0; n /= base {
+		buf = append(buf, alphabet[n%base])
+	}
+	for len(buf) < length {
+		buf = append(buf, alphabet[0])
+	}
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return string(buf)
+}
+
+// randomGenerator - случайный код из криптографического источника
+type randomGenerator struct {
+	alphabet string
+	length   int
+}
+
+// Generate возвращает случайный код
+func (g *randomGenerator) Generate(context.Context, string, int) (string, error) {
+	// Байты за пределами кратного длине алфавита диапазона отбрасываются: распределение равномерное
+	limit := 256 - 256%len(g.alphabet)
+	code := make([]byte, 0, g.length)
+	buf := make([]byte, g.length*2)
+	for len(code) < g.length {
+		rand.Read(buf)
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			code = append(code, g.alphabet[int(b)%len(g.alphabet)])
+			if len(code) == g.length {
+				break
+			}
+		}
+	}
+	return string(code), nil
+}
+
+// sequenceGenerator - порядковый номер в системе счисления алфавита
+type sequenceGenerator struct {
+	alphabet string
+	length   int
+	ids      IDSource
+}
+
+// Generate возвращает код следующего номера
+func (g *sequenceGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	id, err := g.ids.NextID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encode(g.alphabet, id, g.length), nil
+}
+
+// hashGenerator - код из хэша URL с солью. При коллизии в хэш добавляется номер попытки
+type hashGenerator struct {
+	alphabet string
+	length   int
+	salt     string
+}
+
+// Generate возвращает код из хэша URL
+func (g *hashGenerator) Generate(_ context.Context, url string, attempt int) (string, error) {
+	input := g.salt + url
+	if attempt > 0 {
+		input += "#" + strconv.Itoa(attempt)
+	}
+	sum := sha256.Sum256([]byte(input))
+
+	n := new(big.Int).SetBytes(sum[:])
+	base := big.NewInt(int64(len(g.alphabet)))
+	digit := new(big.Int)
+	code := make([]byte, g.length)
+	for i := range code {
+		n.DivMod(n, base, digit)
+		code[i] = g.alphabet[digit.Int64()]
+	}
+	return string(code), nil
+}
+
+// hashidsGenerator - порядковый номер, переставленный в пространстве кодов заданной длины
+// и записанный перемешанным солью алфавитом. Коды не повторяются, пока не исчерпано пространство,
+// но соседние номера не угадываются по коду
+type hashidsGenerator struct {
+	alphabet string
+	length   int
+	// space - кол-во кодов (len(alphabet)^digits, не более 2^64-1)
+	space uint64
+	// digits - кол-во значащих символов кода
+	digits int
+	// mult - множитель перестановки, взаимно простой с space
+	mult uint64
+	ids  IDSource
+}
+
+// newHashidsGenerator - конструктор
+func newHashidsGenerator(alphabet string, length int, salt string, ids IDSource) *hashidsGenerator {
+	// Алфавит перемешивается детерминированно по соли
+	seed := sha256.Sum256([]byte(salt))
+	shuffled := []byte(alphabet)
+	mathrand.New(mathrand.NewChaCha8(seed)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	g := &hashidsGenerator{alphabet: string(shuffled), length: length, space: 1, ids: ids}
+	base := uint64(len(alphabet))
+	for g.digits < length {
+		hi, lo := bits.Mul64(g.space, base)
+		if hi != 0 {
+			break
+		}
+		g.space = lo
+		g.digits++
+	}
+
+	// Множитель из соли, взаимно простой с space: перестановка биективна
+	g.mult = binary.BigEndian.Uint64(seed[:8])%g.space | 1
+	for gcd(g.mult, g.space) != 1 {
+		g.mult = (g.mult + 2) % g.space
+	}
+	return g
+}
+
+// gcd - наибольший общий делитель
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// Generate возвращает код следующего номера
+func (g *hashidsGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	id, err := g.ids.NextID(ctx)
+	if err != nil {
+		return "", err
+	}
+	hi, lo := bits.Mul64(id%g.space, g.mult)
+	return encode(g.alphabet, bits.Rem64(hi, lo, g.space), g.length), nil
+}
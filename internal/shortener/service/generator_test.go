@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/service/config"
+)
+
+// newTestGenerator - генератор с номерами из хранилища в памяти
+func newTestGenerator(t *testing.T, cfg config.Config) CodeGenerator {
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	generator, err := NewCodeGenerator(cfg, newBlockAllocator(store, 10))
+	require.NoError(t, err)
+	return generator
+}
+
+// generate - код без ошибки
+func generate(t *testing.T, generator CodeGenerator, url string, attempt int) string {
+	code, err := generator.Generate(context.Background(), url, attempt)
+	require.NoError(t, err)
+	return code
+}
+
+func TestCodeGenerator(t *testing.T) {
+	for _, name := range []string{config.GeneratorRandom, config.GeneratorSequence, config.GeneratorHash, config.GeneratorHashids} {
+		t.Run(name, func(t *testing.T) {
+			generator := newTestGenerator(t, config.Config{CodeGenerator: name, CodeLength: 8, CodeAlphabet: "abc123", CodeSalt: "salt"})
+
+			seen := make(map[string]bool)
+			for i := range 100 {
+				code := generate(t, generator, "https://ya.ru/", i)
+				require.Len(t, code, 8)
+				require.Empty(t, strings.Trim(code, "abc123"), code)
+				seen[code] = true
+			}
+			// Генераторы (кроме случайного) не повторяют коды
+			if name != config.GeneratorRandom {
+				require.Len(t, seen, 100)
+			}
+		})
+	}
+
+	// hash: код детерминирован для URL и соли
+	hash1 := newTestGenerator(t, config.Config{CodeGenerator: config.GeneratorHash, CodeSalt: "salt"})
+	hash2 := newTestGenerator(t, config.Config{CodeGenerator: config.GeneratorHash, CodeSalt: "salt"})
+	require.Equal(t, generate(t, hash1, "https://ya.ru/", 0), generate(t, hash2, "https://ya.ru/", 0))
+	require.NotEqual(t, generate(t, hash1, "https://ya.ru/", 0), generate(t, hash1, "https://ya.ru/", 1))
+
+	// sequence: порядковые номера
+	sequence := newTestGenerator(t, config.Config{CodeGenerator: config.GeneratorSequence, CodeLength: 3, CodeAlphabet: "0123456789"})
+	require.Equal(t, "001", generate(t, sequence, "", 0))
+	require.Equal(t, "002", generate(t, sequence, "", 0))
+
+	// hashids: перестановка без повторов на всем пространстве кодов
+	hashids := newTestGenerator(t, config.Config{CodeGenerator: config.GeneratorHashids, CodeLength: 3, CodeAlphabet: "0123456789", CodeSalt: "salt"})
+	seen := make(map[string]bool)
+	for range 1000 {
+		seen[generate(t, hashids, "", 0)] = true
+	}
+	require.Len(t, seen, 1000)
+
+	// некорректная конфигурация
+	for _, cfg := range []config.Config{
+		{CodeGenerator: "unknown"},
+		{CodeAlphabet: "a"},
+		{CodeAlphabet: "aab"},
+		{CodeAlphabet: "ab/"},
+		{CodeLength: 100},
+	} {
+		_, err := NewCodeGenerator(cfg, nil)
+		require.ErrorIs(t, err, ErrInvalidGenerator)
+	}
+}
+
+func TestService_CodeCollision(t *testing.T) {
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	// Код, который sequence выдаст первым, занят
+	_, err = store.SetShortener(context.Background(), model.Shortener{Key: model.ShortenerKey{Code: "01"}, Data: model.ShortenerData{URL: "https://example.com/"}})
+	require.NoError(t, err)
+
+	shortenerService, err := NewShortener(config.Config{CodeGenerator: config.GeneratorSequence, CodeLength: 2, CodeAlphabet: "0123456789"}, store)
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/"}})
+	require.NoError(t, err)
+	require.Equal(t, "02", resp.Key.Code)
+
+	batch, err := shortenerService.SetShortenerBatch([]model.ShortenerBatchRow{
+		{ID: "1", Shortener: model.Shortener{Data: model.ShortenerData{URL: "https://example.org/"}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "03", batch[0].Shortener.Key.Code)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+)
+
+// IDSource - источник уникальных номеров для генераторов sequence и hashids
+type IDSource interface {
+	// NextID возвращает следующий номер
+	NextID(ctx context.Context) (uint64, error)
+}
+
+// blockAllocator выдает номера из блока, выделенного хранилищем.
+// Хранилище запрашивается только при исчерпании блока, блоки разных экземпляров сервиса не пересекаются.
+// Номера невыданного остатка блока после перезапуска теряются
+type blockAllocator struct {
+	store repository.Repository
+	size  uint64
+
+	mux  sync.Mutex
+	next uint64
+	end  uint64
+}
+
+// newBlockAllocator - конструктор
+func newBlockAllocator(store repository.Repository, size uint64) *blockAllocator {
+	return &blockAllocator{store: store, size: size}
+}
+
+// NextID возвращает следующий номер, при необходимости выделяя новый блок
+func (a *blockAllocator) NextID(ctx context.Context) (uint64, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if a.next == a.end {
+		start, err := a.store.LeaseIDBlock(ctx, a.size)
+		if err != nil {
+			return 0, err
+		}
+		a.next, a.end = start, start+a.size
+	}
+	id := a.next
+	a.next++
+	return id, nil
+}
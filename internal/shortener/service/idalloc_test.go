@@ -0,0 +1,67 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/service/config"
+)
+
+// Несколько экземпляров сервиса с отдельными подключениями к одной базе SQLite
+// создают ссылки одновременно: коды sequence не пересекаются и не требуют повторов
+func TestBlockAllocator_Instances(t *testing.T) {
+	const instances = 4
+	const links = 50
+
+	storeCfg := repositoryConfig.Config{
+		StoreType: repositoryConfig.StoreTypeSQLite,
+		DBDsn:     repositoryConfig.SQLiteScheme + filepath.Join(t.TempDir(), "shortener.db"),
+	}
+	serviceCfg := config.Config{CodeGenerator: config.GeneratorSequence, CodeBlockSize: 7, CodeAttempts: 1}
+
+	services := make([]*Shortener, instances)
+	for i := range services {
+		store, err := repository.NewStore(storeCfg)
+		require.NoError(t, err)
+		services[i], err = NewShortener(serviceCfg, store)
+		require.NoError(t, err)
+		t.Cleanup(services[i].Shutdown)
+	}
+
+	var mux sync.Mutex
+	codes := make(map[string]string)
+	var wg sync.WaitGroup
+	for i, service := range services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range links {
+				url := fmt.Sprintf("https://example.com/%d/%d", i, j)
+				resp, err := service.SetShortener(model.Shortener{Data: model.ShortenerData{URL: url}})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mux.Lock()
+				codes[resp.Key.Code] = url
+				mux.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, codes, instances*links)
+
+	// Каждый экземпляр видит ссылки остальных
+	for code, url := range codes {
+		resp, err := services[0].GetShortener(code)
+		require.NoError(t, err)
+		require.Equal(t, url, resp.Data.URL)
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+)
+
+// Ошибки паролей ссылок
+var (
+	ErrPasswordRequired = errors.New("password required")
+	ErrWrongPassword    = errors.New("wrong password")
+	ErrTooManyAttempts  = errors.New("too many password attempts")
+	ErrInvalidPassword  = errors.New("invalid password")
+)
+
+// Параметры проверки паролей ссылок
+const (
+	// passwordMaxLen - предельная длина пароля в байтах (ограничение bcrypt)
+	passwordMaxLen = 72
+	// passwordAttempts - кол-во неудачных попыток ввода пароля ссылки с одного адреса за passwordWindow
+	passwordAttempts = 5
+	// passwordCodeAttempts - кол-во неудачных попыток ввода пароля ссылки со всех адресов за passwordWindow:
+	// ограничивает перебор с многих адресов
+	passwordCodeAttempts = 50
+	passwordWindow       = 15 * time.Minute
+)
+
+// HashPassword вычисляет хэш пароля ссылки. Пустой пароль - ссылка без пароля
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	if len(password) > passwordMaxLen {
+		return "", fmt.Errorf("%w: length must not exceed %d bytes", ErrInvalidPassword, passwordMaxLen)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// UnlockShortener читает защищенную паролем короткую ссылку.
+// Неудачные попытки ограничиваются по ссылке и адресу клиента client, а также по ссылке независимо от клиента.
+// Результат учитывается в статистике переходов
+func (service *Shortener) UnlockShortener(code, password, client string) (model.Shortener, error) {
+	s, err := service.getShortener(code)
+	if err == nil && s.Data.Protected() {
+		now := time.Now()
+		key := code + "\x00" + client
+		// Попытка учитывается до проверки пароля: параллельные запросы не превышают предел
+		if !service.unlocks.allow(now, key) {
+			return model.Shortener{}, ErrTooManyAttempts
+		}
+		if !service.codeUnlocks.allow(now, code) {
+			service.unlocks.release(key)
+			return model.Shortener{}, ErrTooManyAttempts
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(s.Data.PasswordHash), []byte(password)); err != nil {
+			return model.Shortener{}, ErrWrongPassword
+		}
+		// Из счетчика ссылки исключается только эта попытка: верный пароль одного клиента
+		// не продлевает перебор остальным
+		service.unlocks.reset(key)
+		service.codeUnlocks.release(code)
+	}
+	if err == nil {
+		s, err = service.useShortener(s)
+	}
+	service.redirects.add(time.Now(), s, err)
+	return s, err
+}
+
+// GetUnlockedShortener читает защищенную паролем короткую ссылку без проверки пароля:
+// доступ уже подтвержден токеном, выданным после UnlockShortener.
+// Результат учитывается в статистике переходов
+func (service *Shortener) GetUnlockedShortener(code string) (model.Shortener, error) {
+	s, err := service.getShortener(code)
+	if err == nil {
+		s, err = service.useShortener(s)
+	}
+	service.redirects.add(time.Now(), s, err)
+	return s, err
+}
+
+// unlockLimiter - попытки ввода пароля по ключу в фиксированном окне passwordWindow,
+// не более attempts за окно. Верные попытки исключаются из счета
+type unlockLimiter struct {
+	mux      sync.Mutex
+	attempts int
+	failures map[string]unlockFailures
+}
+
+// unlockFailures - попытки в окне, начатом в start
+type unlockFailures struct {
+	start time.Time
+	count int
+}
+
+// newUnlockLimiter - конструктор
+func newUnlockLimiter(attempts int) *unlockLimiter {
+	return &unlockLimiter{attempts: attempts, failures: make(map[string]unlockFailures)}
+}
+
+// allow учитывает попытку по ключу в момент now. false - предел попыток исчерпан, попытка не учтена
+func (l *unlockLimiter) allow(now time.Time, key string) bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	f, ok := l.failures[key]
+	if !ok || now.Sub(f.start) >= passwordWindow {
+		// Новое окно: удаление устаревших окон
+		for k, v := range l.failures {
+			if now.Sub(v.start) >= passwordWindow {
+				delete(l.failures, k)
+			}
+		}
+		f = unlockFailures{start: now}
+	}
+	if f.count >= l.attempts {
+		return false
+	}
+	f.count++
+	l.failures[key] = f
+	return true
+}
+
+// release исключает из счета попытку по ключу, учтенную allow
+func (l *unlockLimiter) release(key string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if f, ok := l.failures[key]; ok && f.count > 0 {
+		f.count--
+		l.failures[key] = f
+	}
+}
+
+// reset сбрасывает попытки по ключу после верного пароля
+func (l *unlockLimiter) reset(key string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	delete(l.failures, key)
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/service/config"
+)
+
+func TestService_Password(t *testing.T) {
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	shortenerService, err := NewShortener(config.Config{}, store)
+	require.NoError(t, err)
+	defer shortenerService.Shutdown()
+
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+	require.NotEqual(t, "secret", hash)
+	_, err = HashPassword(strings.Repeat("x", passwordMaxLen+1))
+	require.ErrorIs(t, err, ErrInvalidPassword)
+	hash0, err := HashPassword("")
+	require.NoError(t, err)
+	require.Empty(t, hash0)
+
+	// Ссылка с хэшем минимальной сложности: проверки пароля в тесте многочисленны
+	fastHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1", PasswordHash: string(fastHash)}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+
+	// Без пароля адрес не выдается
+	s, err := shortenerService.GetShortener(code)
+	require.ErrorIs(t, err, ErrPasswordRequired)
+	require.Empty(t, s.Data.URL)
+
+	_, err = shortenerService.UnlockShortener(code, "wrong", "client1")
+	require.ErrorIs(t, err, ErrWrongPassword)
+	s, err = shortenerService.UnlockShortener(code, "secret", "client1")
+	require.NoError(t, err)
+	require.Equal(t, "https://ya.ru/", s.Data.URL)
+
+	// Неудачные попытки ограничиваются по клиенту
+	for range passwordAttempts {
+		_, err = shortenerService.UnlockShortener(code, "wrong", "client2")
+		require.ErrorIs(t, err, ErrWrongPassword)
+	}
+	_, err = shortenerService.UnlockShortener(code, "secret", "client2")
+	require.ErrorIs(t, err, ErrTooManyAttempts)
+	_, err = shortenerService.UnlockShortener(code, "secret", "client1")
+	require.NoError(t, err)
+
+	// Перебор с многих адресов ограничивается по ссылке
+	for i := 1 + passwordAttempts; i < passwordCodeAttempts; i++ {
+		_, err = shortenerService.UnlockShortener(code, "wrong", fmt.Sprintf("client%d", 10+i))
+		require.ErrorIs(t, err, ErrWrongPassword)
+	}
+	_, err = shortenerService.UnlockShortener(code, "secret", "client1")
+	require.ErrorIs(t, err, ErrTooManyAttempts)
+
+	// Переходы без пароля учитываются как успешные
+	report, err := shortenerService.GetStatsReport(context.Background(), time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 0)
+	require.NoError(t, err)
+	require.Zero(t, report.Redirects.Failed)
+}
+
+func TestService_PasswordConcurrent(t *testing.T) {
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	shortenerService, err := NewShortener(config.Config{}, store)
+	require.NoError(t, err)
+	defer shortenerService.Shutdown()
+
+	fastHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1", PasswordHash: string(fastHash)}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+
+	// unlock отправляет n параллельных попыток и возвращает кол-во дошедших до проверки пароля
+	unlock := func(n int, client func(i int) string) int {
+		var (
+			wg      sync.WaitGroup
+			checked atomic.Int32
+			limited atomic.Int32
+		)
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := shortenerService.UnlockShortener(code, "wrong", client(i))
+				switch {
+				case errors.Is(err, ErrWrongPassword):
+					checked.Add(1)
+				case errors.Is(err, ErrTooManyAttempts):
+					limited.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, n, int(checked.Load()+limited.Load()))
+		return int(checked.Load())
+	}
+
+	// Один клиент
+	require.Equal(t, passwordAttempts, unlock(4*passwordAttempts, func(int) string { return "client1" }))
+	// Многие клиенты
+	require.Equal(t, passwordCodeAttempts-passwordAttempts, unlock(2*passwordCodeAttempts, func(i int) string {
+		return fmt.Sprintf("client%d", 10+i)
+	}))
+}
+
+func TestUnlockLimiter(t *testing.T) {
+	limiter := newUnlockLimiter(passwordAttempts)
+	now := time.Now()
+
+	for range passwordAttempts {
+		require.True(t, limiter.allow(now, "key"))
+	}
+	require.False(t, limiter.allow(now, "key"))
+	require.True(t, limiter.allow(now, "other"))
+
+	// Исключенная из счета попытка освобождает место
+	limiter.release("key")
+	require.True(t, limiter.allow(now, "key"))
+	require.False(t, limiter.allow(now, "key"))
+
+	// Новое окно
+	require.True(t, limiter.allow(now.Add(passwordWindow), "key"))
+	require.Equal(t, 1, limiter.failures["key"].count)
+
+	limiter.reset("key")
+	require.NotContains(t, limiter.failures, "key")
+}
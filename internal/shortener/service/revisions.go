@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+)
+
+// ErrRevisionNotFound - в истории ссылки нет изменения с таким номером
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// checkOwner - ссылка существует и принадлежит пользователю
+func (service *Shortener) checkOwner(userCode, code string) error {
+	s, err := service.store.GetShortener(code)
+	if err != nil {
+		return err
+	}
+	if userCode == "" || s.Data.User != userCode {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+// UpdateShortener меняет адрес назначения ссылки пользователя.
+// Адрес нормализуется и проверяется политикой так же, как при создании ссылки
+func (service *Shortener) UpdateShortener(ctx context.Context, userCode, code, url string) (model.Shortener, error) {
+	url, err := NormalizeURL(url)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	if err := service.policy.Check(ctx, url); err != nil {
+		return model.Shortener{}, err
+	}
+	if err := service.checkOwner(userCode, code); err != nil {
+		return model.Shortener{}, err
+	}
+
+	return service.store.UpdateShortener(ctx, model.Shortener{
+		Key:  model.ShortenerKey{Code: code},
+		Data: model.ShortenerData{URL: url, User: userCode},
+	})
+}
+
+// GetRevisions возвращает историю адресов ссылки пользователя
+func (service *Shortener) GetRevisions(ctx context.Context, userCode, code string) ([]model.Revision, error) {
+	if err := service.checkOwner(userCode, code); err != nil {
+		return nil, err
+	}
+	return service.store.GetRevisions(ctx, code)
+}
+
+// RollbackShortener возвращает ссылке адрес из истории изменений.
+// Возврат записывается в историю новым изменением
+func (service *Shortener) RollbackShortener(ctx context.Context, userCode, code string, revision int) (model.Shortener, error) {
+	revs, err := service.GetRevisions(ctx, userCode, code)
+	if err != nil {
+		return model.Shortener{}, err
+	}
+	for _, rev := range revs {
+		if rev.Revision == revision {
+			return service.UpdateShortener(ctx, userCode, code, rev.URL)
+		}
+	}
+	return model.Shortener{}, ErrRevisionNotFound
+}
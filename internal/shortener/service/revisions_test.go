@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/service/config"
+)
+
+func TestService_Update(t *testing.T) {
+	ctx := context.Background()
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	defer store.Close()
+	shortenerService, err := NewShortener(config.Config{}, store)
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+	other, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://example.com/", User: "user1"}})
+	require.NoError(t, err)
+
+	// Адрес нормализуется так же, как при сокращении
+	resp, err = shortenerService.UpdateShortener(ctx, "user1", code, "HTTPS://Go.dev")
+	require.NoError(t, err)
+	require.Equal(t, "https://go.dev/", resp.Data.URL)
+	got, err := shortenerService.GetShortener(code)
+	require.NoError(t, err)
+	require.Equal(t, "https://go.dev/", got.Data.URL)
+
+	revs, err := shortenerService.GetRevisions(ctx, "user1", code)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	require.Equal(t, "https://ya.ru/", revs[0].URL)
+	require.Equal(t, "https://go.dev/", revs[1].URL)
+
+	// Откат создает новое изменение с адресом из истории
+	resp, err = shortenerService.RollbackShortener(ctx, "user1", code, 1)
+	require.NoError(t, err)
+	require.Equal(t, "https://ya.ru/", resp.Data.URL)
+	revs, err = shortenerService.GetRevisions(ctx, "user1", code)
+	require.NoError(t, err)
+	require.Len(t, revs, 3)
+	_, err = shortenerService.RollbackShortener(ctx, "user1", code, 10)
+	require.ErrorIs(t, err, ErrRevisionNotFound)
+
+	// Адрес, уже сокращенный другой ссылкой
+	resp, err = shortenerService.UpdateShortener(ctx, "user1", code, "https://example.com/")
+	require.ErrorIs(t, err, repository.ErrSetShortenerAlreadyExists)
+	require.Equal(t, other.Key.Code, resp.Key.Code)
+
+	// Изменять ссылку может только владелец
+	_, err = shortenerService.UpdateShortener(ctx, "user2", code, "https://go.dev/")
+	require.ErrorIs(t, err, ErrNotOwner)
+	_, err = shortenerService.GetRevisions(ctx, "user2", code)
+	require.ErrorIs(t, err, ErrNotOwner)
+	_, err = shortenerService.UpdateShortener(ctx, "user1", code, "not a url")
+	require.ErrorIs(t, err, ErrInvalidURL)
+}
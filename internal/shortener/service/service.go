@@ -5,69 +5,274 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/iurnickita/vigilant-train/internal/common/rand"
 	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/policy"
 	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	"github.com/iurnickita/vigilant-train/internal/shortener/service/config"
 )
 
 // Service - интерфейс сервиса
 type Service interface {
 	// GetShortener читает короткую ссылку
 	GetShortener(code string) (model.Shortener, error)
+	// UnlockShortener читает защищенную паролем короткую ссылку
+	UnlockShortener(code, password, client string) (model.Shortener, error)
+	// GetUnlockedShortener читает защищенную паролем короткую ссылку, доступ к которой подтвержден
+	GetUnlockedShortener(code string) (model.Shortener, error)
 	// SetShortener создает короткую ссылку
 	SetShortener(s model.Shortener) (model.Shortener, error)
-	// SetShortenerBatch создает короткую ссылку для набора данных
-	SetShortenerBatch(s []model.Shortener) ([]model.Shortener, error)
+	// SetShortenerBatch создает короткую ссылку для набора данных.
+	// Результат по каждой позиции возвращается в порядке запроса с тем же ID
+	SetShortenerBatch(s []model.ShortenerBatchRow) ([]model.ShortenerBatchRow, error)
 	// Ping
 	Ping() error
 	// GetShortenerBatch возвращает все ссылки, добавленные пользователем
 	GetShortnerBatchUser(userCode string) ([]model.Shortener, error)
-	// DeleteShortenerBatch удаляет короткую ссылку
-	DeleteShortenerBatch(s []model.Shortener) error
+	// DeleteShortenerBatch ставит в очередь удаление ссылок пользователя и возвращает задание удаления
+	DeleteShortenerBatch(ctx context.Context, userCode string, codes []string) (model.DeleteJob, error)
+	// GetDeleteJob возвращает задание удаления пользователя
+	GetDeleteJob(ctx context.Context, userCode, id string) (model.DeleteJob, error)
+	// UpdateShortener меняет адрес назначения ссылки пользователя
+	UpdateShortener(ctx context.Context, userCode, code, url string) (model.Shortener, error)
+	// GetRevisions возвращает историю адресов ссылки пользователя
+	GetRevisions(ctx context.Context, userCode, code string) ([]model.Revision, error)
+	// RollbackShortener возвращает ссылке адрес из истории изменений
+	RollbackShortener(ctx context.Context, userCode, code string, revision int) (model.Shortener, error)
+	// GetTrash возвращает удаленные ссылки пользователя, которые еще можно восстановить
+	GetTrash(ctx context.Context, userCode string) ([]model.Shortener, error)
+	// RestoreShortener восстанавливает удаленную ссылку пользователя
+	RestoreShortener(ctx context.Context, userCode, code string) (model.Shortener, error)
 	// GetStats возвращает статистические данные
 	GetStats(ctx context.Context) (model.Stats, error)
+	// GetStatsReport возвращает статистику за период с не более чем top ссылками по кол-ву переходов
+	GetStatsReport(ctx context.Context, from, to time.Time, top int) (model.StatsReport, error)
+	// RecordClick учитывает переход по ссылке
+	RecordClick(code, referrer, userAgent, ip string)
+	// GetClickStats возвращает статистику переходов по ссылке пользователя
+	GetClickStats(ctx context.Context, userCode, code string, from time.Time, bucket time.Duration) (model.ClickStats, error)
 	// Shutdown завершает и ожидает все процессы
 	Shutdown()
 }
 
 // Shortener - Сервис сокращения URL
 type Shortener struct {
-	store    repository.Repository
-	toDelete chan []model.Shortener
-	shutdown chan bool
-	wg       sync.WaitGroup
+	store     repository.Repository
+	generator CodeGenerator
+	// policy - проверка адресов назначения при создании и переходе по ссылке
+	policy *policy.Engine
+	// attempts - кол-во попыток подобрать свободный код
+	attempts int
+	// deleteMux защищает невыполненные задания удаления
+	deleteMux sync.Mutex
+	// deleteJobs - невыполненные задания удаления по ID
+	deleteJobs map[string]model.DeleteJob
+	// deleteKeys - ID невыполненного задания по пользователю и набору кодов (см. deleteJobKey)
+	deleteKeys map[string]string
+	// clicks - очередь переходов к записи
+	clicks        chan model.Click
+	droppedClicks atomic.Uint64
+	// clickSalt - соль хэша адреса клиента
+	clickSalt string
+	// redirects - результаты переходов для статистики
+	redirects *redirectLog
+	// unlocks - неудачные попытки ввода пароля ссылки по ссылке и клиенту
+	unlocks *unlockLimiter
+	// codeUnlocks - неудачные попытки ввода пароля ссылки по ссылке
+	codeUnlocks *unlockLimiter
+	// trashGrace - срок восстановления удаленной ссылки
+	trashGrace time.Duration
+	// trashRetention - срок хранения удаленной ссылки до окончательного удаления
+	trashRetention time.Duration
+	// done закрывается при завершении работы фоновых процессов
+	done chan struct{}
+	wg   sync.WaitGroup
+	// shutdown - Shutdown выполняется один раз
+	shutdown sync.Once
 }
 
+// reapInterval - периодичность окончательного удаления истекших ссылок
+const reapInterval = time.Minute
+
 // NewShortener конструктор
-func NewShortener(store repository.Repository) *Shortener {
-	toDelete := make(chan []model.Shortener, 100)
-	shutdown := make(chan bool)
+func NewShortener(cfg config.Config, store repository.Repository) (*Shortener, error) {
+	blockSize := cfg.CodeBlockSize
+	if blockSize == 0 {
+		blockSize = config.DefaultCodeBlockSize
+	}
+	generator, err := NewCodeGenerator(cfg, newBlockAllocator(store, blockSize))
+	if err != nil {
+		return nil, err
+	}
+	attempts := cfg.CodeAttempts
+	if attempts <= 0 {
+		attempts = config.DefaultCodeAttempts
+	}
+	trashGrace := cfg.TrashGrace
+	if trashGrace <= 0 {
+		trashGrace = config.DefaultTrashGrace
+	}
+	trashRetention := cfg.TrashRetention
+	if trashRetention <= 0 {
+		trashRetention = config.DefaultTrashRetention
+	}
+	// Ссылка не удаляется окончательно, пока ее можно восстановить
+	trashRetention = max(trashRetention, trashGrace)
+	clickSalt := cfg.ClickSalt
+	if clickSalt == "" {
+		if clickSalt, err = newClickSalt(); err != nil {
+			return nil, err
+		}
+	}
+	policyEngine, err := policy.New(cfg.Policy)
+	if err != nil {
+		return nil, err
+	}
 
 	shortener := Shortener{
-		store:    store,
-		toDelete: toDelete,
-		shutdown: shutdown,
+		store:      store,
+		generator:  generator,
+		policy:     policyEngine,
+		attempts:   attempts,
+		deleteJobs: make(map[string]model.DeleteJob),
+		deleteKeys: make(map[string]string),
+		clicks:     make(chan model.Click, clickQueueSize),
+		clickSalt:  clickSalt,
+		redirects:  newRedirectLog(),
+		unlocks:    newUnlockLimiter(passwordAttempts),
+		done:       make(chan struct{}),
+
+		codeUnlocks:    newUnlockLimiter(passwordCodeAttempts),
+		trashGrace:     trashGrace,
+		trashRetention: trashRetention,
 	}
 
-	shortener.wg.Add(1)
-	go shortener.flushDeletes()
+	// Задания удаления, не выполненные до остановки
+	if err := shortener.loadDeletes(context.Background()); err != nil {
+		policyEngine.Close()
+		return nil, err
+	}
 
-	return &shortener
+	shortener.wg.Add(4)
+	go shortener.flushDeletes(deleteInterval)
+	go shortener.reapExpired(reapInterval)
+	go shortener.purgeDeleted(purgeInterval)
+	go shortener.flushClicks()
+
+	return &shortener, nil
 }
 
 // Ошибки пакета
 var (
 	ErrGetShortenerInvalidRequest = errors.New("invalid get Shortener request")
 	ErrRepoFailed                 = errors.New("repo failed")
-	ErrChanToDeleteIsFull         = errors.New("queue to delete is full")
+	ErrInvalidURL                 = errors.New("invalid url")
+	ErrInvalidExpiry              = errors.New("invalid expiry")
+	ErrInvalidMaxClicks           = errors.New("invalid max clicks")
+	ErrInvalidAlias               = errors.New("invalid alias")
+	ErrAliasTaken                 = errors.New("alias is already taken")
+)
+
+// Ограничения длины пользовательского кода ссылки
+const (
+	aliasMinLen = 3
+	aliasMaxLen = 32
 )
 
-// GetShortener читает короткую ссылку
+// reservedAliases - коды, совпадающие с путями сервиса (сравнение без учета регистра)
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"ping":    {},
+	"debug":   {},
+	"admin":   {},
+	"static":  {},
+	"health":  {},
+	"metrics": {},
+}
+
+// ValidateAlias проверяет пользовательский код ссылки:
+// латинские буквы, цифры, '-' и '_', длина от aliasMinLen до aliasMaxLen, не зарезервирован
+func ValidateAlias(alias string) error {
+	if len(alias) < aliasMinLen || len(alias) > aliasMaxLen {
+		return fmt.Errorf("%w: length must be between %d and %d", ErrInvalidAlias, aliasMinLen, aliasMaxLen)
+	}
+	for _, c := range alias {
+		if !aliasChar(c) {
+			return fmt.Errorf("%w: unexpected character %q", ErrInvalidAlias, c)
+		}
+	}
+	if _, ok := reservedAliases[strings.ToLower(alias)]; ok {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+	}
+	return nil
+}
+
+// ExpiresAt вычисляет срок действия ссылки по относительному ttl или абсолютному моменту at.
+// Нулевые ttl и at - бессрочная ссылка
+func ExpiresAt(now time.Time, ttl time.Duration, at time.Time) (time.Time, error) {
+	switch {
+	case ttl != 0 && !at.IsZero():
+		return time.Time{}, fmt.Errorf("%w: ttl and expires_at are mutually exclusive", ErrInvalidExpiry)
+	case ttl < 0:
+		return time.Time{}, fmt.Errorf("%w: ttl must be positive", ErrInvalidExpiry)
+	case ttl > 0:
+		return now.Add(ttl), nil
+	}
+	return at, nil
+}
+
+// validateExpiry проверяет, что срок действия не истек на момент создания
+func validateExpiry(data model.ShortenerData) error {
+	if data.Expired(time.Now()) {
+		return fmt.Errorf("%w: expires_at is in the past", ErrInvalidExpiry)
+	}
+	return nil
+}
+
+// validateMaxClicks проверяет ограничение кол-ва переходов и задает начальный остаток переходов
+func validateMaxClicks(data *model.ShortenerData) error {
+	if data.MaxClicks < 0 {
+		return fmt.Errorf("%w: max_clicks must not be negative", ErrInvalidMaxClicks)
+	}
+	data.ClicksLeft = data.MaxClicks
+	return nil
+}
+
+// aliasChar - символ, допустимый в коде ссылки
+func aliasChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+// GetShortener читает короткую ссылку. Результат учитывается в статистике переходов.
+// Для защищенной паролем ссылки возвращается ErrPasswordRequired (см. UnlockShortener).
+// Переход по ссылке с ограничением кол-ва переходов расходует остаток
 func (service *Shortener) GetShortener(code string) (model.Shortener, error) {
+	s, err := service.getShortener(code)
+	if err == nil && s.Data.Protected() {
+		s, err = model.Shortener{}, ErrPasswordRequired
+	}
+	if err == nil {
+		s, err = service.useShortener(s)
+	}
+	service.redirects.add(time.Now(), s, err)
+	return s, err
+}
 
+// useShortener расходует переход по ссылке с ограничением кол-ва переходов.
+// Исчерпанная ссылка - repository.ErrGetShortenerExhausted
+func (service *Shortener) useShortener(s model.Shortener) (model.Shortener, error) {
+	if s.Data.MaxClicks == 0 {
+		return s, nil
+	}
+	return service.store.UseShortener(context.Background(), s.Key.Code)
+}
+
+// getShortener читает короткую ссылку и проверяет адрес назначения
+func (service *Shortener) getShortener(code string) (model.Shortener, error) {
 	repositoryResp, err := service.store.GetShortener(code)
 	if err != nil {
 		if !errors.Is(err, repository.ErrGetShortenerNotFound) {
@@ -75,34 +280,147 @@ func (service *Shortener) GetShortener(code string) (model.Shortener, error) {
 		}
 	}
 
+	// Адрес мог быть запрещен после создания ссылки
+	if repositoryResp.Data.URL != "" {
+		if err := service.policy.Check(context.Background(), repositoryResp.Data.URL); err != nil {
+			return model.Shortener{}, err
+		}
+	}
+
 	return repositoryResp, nil
 }
 
-// SetShortener создает короткую ссылку
+// SetShortener создает короткую ссылку.
+// Заданный в запросе код (s.Key.Code) используется как пользовательский, иначе код генерируется
 func (service *Shortener) SetShortener(s model.Shortener) (model.Shortener, error) {
 	ctx := context.Background()
 
-	s.Key.Code = rand.String(6)
-
-	storeResp, err := service.store.SetShortener(ctx, s)
+	url, err := NormalizeURL(s.Data.URL)
 	if err != nil {
+		return model.Shortener{}, err
+	}
+	s.Data.URL = url
+	if err := service.policy.Check(ctx, url); err != nil {
+		return model.Shortener{}, err
+	}
+	if err := validateExpiry(s.Data); err != nil {
+		return model.Shortener{}, err
+	}
+	if err := validateMaxClicks(&s.Data); err != nil {
+		return model.Shortener{}, err
+	}
+
+	// Пользовательский код: повтор невозможен
+	if s.Key.Code != "" {
+		if err := ValidateAlias(s.Key.Code); err != nil {
+			return model.Shortener{}, err
+		}
+		storeResp, err := service.store.SetShortener(ctx, s)
+		if errors.Is(err, repository.ErrSetShortenerCodeConflict) {
+			return model.Shortener{}, ErrAliasTaken
+		}
 		return storeResp, err
 	}
 
-	return storeResp, nil
+	for attempt := 0; ; attempt++ {
+		code, err := service.generator.Generate(ctx, s.Data.URL, attempt)
+		if err != nil {
+			return model.Shortener{}, err
+		}
+		s.Key.Code = code
+
+		storeResp, err := service.store.SetShortener(ctx, s)
+		// Код занят другой ссылкой: повтор с новым кодом
+		if errors.Is(err, repository.ErrSetShortenerCodeConflict) && attempt+1 < service.attempts {
+			continue
+		}
+		if err != nil {
+			return storeResp, err
+		}
+
+		return storeResp, nil
+	}
 }
 
-// SetShortenerBatch создает короткую ссылку для набора данных
-func (service *Shortener) SetShortenerBatch(s []model.Shortener) ([]model.Shortener, error) {
+// SetShortenerBatch создает короткую ссылку для набора данных.
+// Позиция с занятым пользовательским кодом возвращается со статусом ShortenerStatusCodeConflict и ошибкой ErrAliasTaken
+func (service *Shortener) SetShortenerBatch(s []model.ShortenerBatchRow) ([]model.ShortenerBatchRow, error) {
 	ctx := context.Background()
 
+	resp := make([]model.ShortenerBatchRow, len(s))
+
+	// Позиции, для которых еще нужен код. Некорректные позиции в хранилище не передаются
+	pending := make([]int, 0, len(s))
+	// Позиции с пользовательским кодом
+	aliased := make(map[int]bool)
 	for i := range s {
-		s[i].Key.Code = rand.String(6)
+		// Позиция могла быть отклонена вызывающей стороной
+		err := s[i].Err
+		if err == nil {
+			s[i].Shortener.Data.URL, err = NormalizeURL(s[i].Shortener.Data.URL)
+		}
+		if err == nil {
+			err = service.policy.Check(ctx, s[i].Shortener.Data.URL)
+		}
+		if err == nil {
+			err = validateExpiry(s[i].Shortener.Data)
+		}
+		if err == nil {
+			err = validateMaxClicks(&s[i].Shortener.Data)
+		}
+		if err == nil && s[i].Shortener.Key.Code != "" {
+			err = ValidateAlias(s[i].Shortener.Key.Code)
+			aliased[i] = true
+		}
+		if err != nil {
+			resp[i] = s[i]
+			resp[i].Status = model.ShortenerStatusInvalid
+			resp[i].Err = err
+			continue
+		}
+		pending = append(pending, i)
 	}
 
-	storeResp, err := service.store.SetShortenerBatch(ctx, s)
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt >= service.attempts {
+			return nil, repository.ErrSetShortenerCodeConflict
+		}
+
+		batch := make([]model.ShortenerBatchRow, len(pending))
+		for i, idx := range pending {
+			if !aliased[idx] {
+				code, err := service.generator.Generate(ctx, s[idx].Shortener.Data.URL, attempt)
+				if err != nil {
+					return nil, err
+				}
+				s[idx].Shortener.Key.Code = code
+			}
+			batch[i] = s[idx]
+		}
+
+		storeResp, err := service.store.SetShortenerBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		// Код занят другой ссылкой: повтор с новым кодом только для этих позиций
+		var next []int
+		for i, row := range storeResp {
+			if row.Status == model.ShortenerStatusCodeConflict {
+				if aliased[pending[i]] {
+					row.Err = ErrAliasTaken
+					resp[pending[i]] = row
+					continue
+				}
+				next = append(next, pending[i])
+				continue
+			}
+			resp[pending[i]] = row
+		}
+		pending = next
+	}
 
-	return storeResp, err
+	return resp, nil
 }
 
 // Ping
@@ -121,42 +439,19 @@ func (service *Shortener) GetShortnerBatchUser(userCode string) ([]model.Shorten
 	return service.store.GetShortenerBatch(ctx, userCode)
 }
 
-// DeleteShortenerBatch удаляет короткую ссылку
-func (service *Shortener) DeleteShortenerBatch(s []model.Shortener) error {
-	service.toDelete <- s
-	return nil
-}
-
-func (service *Shortener) flushDeletes() {
+// reapExpired периодически окончательно удаляет истекшие ссылки
+func (service *Shortener) reapExpired(interval time.Duration) {
 	defer service.wg.Done()
 	ctx := context.Background()
-	ticker := time.NewTicker(3 * time.Second)
-
-	var toDelete []model.Shortener
-	var shutdown bool
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-L:
 	for {
 		select {
-		// Сигнал завершения
-		case <-service.shutdown:
-			shutdown = true
-		// Накопление очереди к удалению
-		case s := <-service.toDelete:
-			toDelete = append(toDelete, s...)
-		// Удаление набора из очереди
-		case <-ticker.C:
-			if len(toDelete) == 0 {
-				// Завершение работы
-				if shutdown {
-					break L
-				}
-
-				continue
-			}
-			service.store.DeleteShortenerBatch(ctx, toDelete)
-
-			toDelete = nil
+		case <-service.done:
+			return
+		case now := <-ticker.C:
+			service.store.DeleteExpired(ctx, now)
 		}
 	}
 }
@@ -166,12 +461,15 @@ func (service *Shortener) GetStats(ctx context.Context) (model.Stats, error) {
 	return service.store.GetStats(ctx)
 }
 
-// Shutdown завершает и ожидает все процессы
+// Shutdown завершает и ожидает все процессы. Повторный вызов ничего не делает
 func (service *Shortener) Shutdown() {
-	// Передача сигнала завершения
-	service.shutdown <- true
-	// Ожидание завершения
-	service.wg.Wait()
-	// Закрытие хранилища
-	service.store.Close()
+	service.shutdown.Do(func() {
+		// Передача сигнала завершения
+		close(service.done)
+		// Ожидание завершения
+		service.wg.Wait()
+		service.policy.Close()
+		// Закрытие хранилища
+		service.store.Close()
+	})
 }
@@ -1,10 +1,20 @@
 package service
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/iurnickita/vigilant-train/internal/shortener/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/policy"
+	policyConfig "github.com/iurnickita/vigilant-train/internal/shortener/policy/config"
 	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	serviceConfig "github.com/iurnickita/vigilant-train/internal/shortener/service/config"
 )
 
 func TestService_NewShortener(t *testing.T) {
@@ -14,8 +24,129 @@ func TestService_NewShortener(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	shortenerService := NewShortener(store)
+	shortenerService, err := NewShortener(cfg.Service, store)
+	if err != nil {
+		t.Error(err)
+	}
 	if shortenerService == nil {
 		t.Errorf("NewShortener error")
+	} else {
+		t.Cleanup(shortenerService.Shutdown)
+	}
+}
+
+func TestService_Alias(t *testing.T) {
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	shortenerService, err := NewShortener(serviceConfig.Config{}, store)
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	for _, alias := range []string{"ab", "with space", "кириллица", "API", "ping", strings.Repeat("a", 33)} {
+		_, err := shortenerService.SetShortener(model.Shortener{
+			Key:  model.ShortenerKey{Code: alias},
+			Data: model.ShortenerData{URL: "https://ya.ru/"},
+		})
+		require.ErrorIs(t, err, ErrInvalidAlias, alias)
+	}
+
+	resp, err := shortenerService.SetShortener(model.Shortener{
+		Key:  model.ShortenerKey{Code: "my-link_1"},
+		Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "my-link_1", resp.Key.Code)
+
+	// Код занят другой ссылкой
+	_, err = shortenerService.SetShortener(model.Shortener{
+		Key:  model.ShortenerKey{Code: "my-link_1"},
+		Data: model.ShortenerData{URL: "https://practicum.yandex.ru/", User: "user2"},
+	})
+	require.ErrorIs(t, err, ErrAliasTaken)
+
+	// Пакет: занятый код не подменяется сгенерированным
+	batch, err := shortenerService.SetShortenerBatch([]model.ShortenerBatchRow{
+		{ID: "1", Shortener: model.Shortener{Key: model.ShortenerKey{Code: "my-link_1"}, Data: model.ShortenerData{URL: "https://example.com/"}}},
+		{ID: "2", Shortener: model.Shortener{Key: model.ShortenerKey{Code: "other"}, Data: model.ShortenerData{URL: "https://example.org/"}}},
+		{ID: "3", Shortener: model.Shortener{Data: model.ShortenerData{URL: "https://example.net/"}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.ShortenerStatusCodeConflict, batch[0].Status)
+	require.ErrorIs(t, batch[0].Err, ErrAliasTaken)
+	require.Equal(t, model.ShortenerStatusCreated, batch[1].Status)
+	require.Equal(t, "other", batch[1].Shortener.Key.Code)
+	require.Equal(t, model.ShortenerStatusCreated, batch[2].Status)
+	require.Len(t, batch[2].Shortener.Key.Code, 6)
+}
+
+func TestService_Policy(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`{"block": ["evil.com"]}`), 0666))
+
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	shortenerService, err := NewShortener(serviceConfig.Config{Policy: policyConfig.Config{RulesFile: rulesFile}}, store)
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	_, err = shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://evil.com/"}})
+	require.ErrorIs(t, err, policy.ErrBlocked)
+	_, err = shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "http://127.0.0.1/"}})
+	require.ErrorIs(t, err, policy.ErrForbidden)
+
+	batch, err := shortenerService.SetShortenerBatch([]model.ShortenerBatchRow{
+		{ID: "1", Shortener: model.Shortener{Data: model.ShortenerData{URL: "https://evil.com/"}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.ShortenerStatusInvalid, batch[0].Status)
+	require.ErrorIs(t, batch[0].Err, policy.ErrBlocked)
+
+	// Адрес запрещен после создания ссылки
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://bad.example.com/"}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`{"block": ["bad.example.com"]}`), 0666))
+	require.NoError(t, shortenerService.policy.Reload())
+	_, err = shortenerService.GetShortener(resp.Key.Code)
+	require.ErrorIs(t, err, policy.ErrBlocked)
+}
+
+func TestService_MaxClicks(t *testing.T) {
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	shortenerService, err := NewShortener(serviceConfig.Config{}, store)
+	require.NoError(t, err)
+	defer shortenerService.Shutdown()
+
+	_, err = shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", MaxClicks: -1}})
+	require.ErrorIs(t, err, ErrInvalidMaxClicks)
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", MaxClicks: 2}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+
+	// Остаток переходов расходуется при каждом переходе
+	for left := 1; left >= 0; left-- {
+		s, err := shortenerService.GetShortener(code)
+		require.NoError(t, err)
+		require.Equal(t, "https://ya.ru/", s.Data.URL)
+		require.Equal(t, left, s.Data.ClicksLeft)
 	}
+	_, err = shortenerService.GetShortener(code)
+	require.ErrorIs(t, err, repository.ErrGetShortenerExhausted)
+	require.ErrorIs(t, err, repository.ErrGetShortenerGone)
+
+	// Одноразовая ссылка с паролем: переход расходуется только после ввода пароля
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+	resp, err = shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://practicum.yandex.ru/", MaxClicks: 1, PasswordHash: hash}})
+	require.NoError(t, err)
+	code = resp.Key.Code
+	_, err = shortenerService.GetShortener(code)
+	require.ErrorIs(t, err, ErrPasswordRequired)
+	_, err = shortenerService.UnlockShortener(code, "wrong", "client")
+	require.ErrorIs(t, err, ErrWrongPassword)
+	_, err = shortenerService.UnlockShortener(code, "secret", "client")
+	require.NoError(t, err)
+	_, err = shortenerService.UnlockShortener(code, "secret", "client")
+	require.ErrorIs(t, err, repository.ErrGetShortenerGone)
 }
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/policy"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+)
+
+// Параметры статистики за период
+const (
+	// DefaultStatsPeriod - длина периода статистики по умолчанию
+	DefaultStatsPeriod = 7 * model.StatsDay
+	// maxStatsPeriod - предельная длина периода статистики
+	maxStatsPeriod = 366 * model.StatsDay
+	// DefaultStatsTop - кол-во ссылок с наибольшим кол-вом переходов по умолчанию
+	DefaultStatsTop = 10
+	// maxStatsTop - предельное кол-во ссылок с наибольшим кол-вом переходов
+	maxStatsTop = 100
+	// redirectBucket - интервал учета результатов переходов
+	redirectBucket = time.Hour
+)
+
+// redirectLog - результаты переходов по часам с момента since. Хранится в памяти экземпляра сервиса
+// не дольше maxStatsPeriod
+type redirectLog struct {
+	mux   sync.Mutex
+	since time.Time
+	hours map[int64]*model.RedirectStats
+}
+
+// newRedirectLog - конструктор
+func newRedirectLog() *redirectLog {
+	return &redirectLog{since: time.Now(), hours: make(map[int64]*model.RedirectStats)}
+}
+
+// add учитывает результат перехода в момент now
+func (l *redirectLog) add(now time.Time, s model.Shortener, err error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	key := model.BucketStart(now, redirectBucket).Unix()
+	stats, ok := l.hours[key]
+	if !ok {
+		stats = &model.RedirectStats{}
+		l.hours[key] = stats
+		// Новый час: удаление часов старше предельного периода
+		oldest := now.Add(-maxStatsPeriod).Unix()
+		for hour := range l.hours {
+			if hour < oldest {
+				delete(l.hours, hour)
+			}
+		}
+	}
+
+	stats.Total++
+	switch {
+	case err == nil && s.Data.URL == "", errors.Is(err, repository.ErrGetShortenerNotFound):
+		stats.NotFound++
+	case err == nil, errors.Is(err, ErrPasswordRequired):
+	case errors.Is(err, repository.ErrGetShortenerGone):
+		stats.Gone++
+	case errors.Is(err, policy.ErrBlocked), errors.Is(err, policy.ErrForbidden):
+		stats.Blocked++
+	default:
+		stats.Failed++
+	}
+}
+
+// stats - результаты переходов за период [from, to)
+func (l *redirectLog) stats(from, to time.Time) model.RedirectStats {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	resp := model.RedirectStats{Since: l.since}
+	for hour, stats := range l.hours {
+		if t := time.Unix(hour, 0); t.Before(from) || !t.Before(to) {
+			continue
+		}
+		resp.Total += stats.Total
+		resp.NotFound += stats.NotFound
+		resp.Gone += stats.Gone
+		resp.Blocked += stats.Blocked
+		resp.Failed += stats.Failed
+	}
+	if resp.Total > 0 {
+		errs := resp.NotFound + resp.Gone + resp.Blocked + resp.Failed
+		resp.ErrorRate = float64(errs) / float64(resp.Total)
+	}
+	return resp
+}
+
+// GetStatsReport возвращает статистику за период [from, to). Границы периода расширяются до суток UTC,
+// созданные ссылки возвращаются по всем суткам периода, включая сутки без ссылок.
+// Результаты переходов учитываются по данным этого экземпляра сервиса
+func (service *Shortener) GetStatsReport(ctx context.Context, from, to time.Time, top int) (model.StatsReport, error) {
+	if !from.Before(to) {
+		return model.StatsReport{}, fmt.Errorf("%w: from must be before to", ErrInvalidStatsRange)
+	}
+	if to.Sub(from) > maxStatsPeriod {
+		return model.StatsReport{}, fmt.Errorf("%w: period longer than %d days", ErrInvalidStatsRange, maxStatsPeriod/model.StatsDay)
+	}
+	if top <= 0 {
+		top = DefaultStatsTop
+	}
+	top = min(top, maxStatsTop)
+
+	from = model.BucketStart(from, model.StatsDay)
+	to = model.BucketStart(to.Add(model.StatsDay-1), model.StatsDay)
+	report, err := service.store.GetStatsReport(ctx, from, to, top)
+	if err != nil {
+		return model.StatsReport{}, err
+	}
+	report.From, report.To = from, to
+	report.Created = denseBuckets(report.Created, from, to.Add(-model.StatsDay), model.StatsDay)
+	report.Redirects = service.redirects.stats(from, to)
+	if report.TopLinks == nil {
+		report.TopLinks = []model.LinkClicks{}
+	}
+	return report, nil
+}
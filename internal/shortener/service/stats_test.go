@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/service/config"
+)
+
+func TestService_StatsReport(t *testing.T) {
+	ctx := context.Background()
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	defer store.Close()
+	shortenerService, err := NewShortener(config.Config{}, store)
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+	deleted, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/deleted", User: "user2"}})
+	require.NoError(t, err)
+	_, err = store.DeleteShortenerBatch(ctx, []model.Shortener{{Key: deleted.Key, Data: model.ShortenerData{User: "user2"}}})
+	require.NoError(t, err)
+
+	// Результаты переходов: успешный, несуществующий код, удаленная ссылка
+	_, err = shortenerService.GetShortener(code)
+	require.NoError(t, err)
+	_, err = shortenerService.GetShortener("unknown")
+	require.NoError(t, err)
+	_, err = shortenerService.GetShortener(deleted.Key.Code)
+	require.ErrorIs(t, err, repository.ErrGetShortenerGone)
+	require.NoError(t, store.SaveClicks(ctx, []model.Click{{Code: code, Time: time.Now()}}))
+
+	now := time.Now()
+	report, err := shortenerService.GetStatsReport(ctx, now.Add(-DefaultStatsPeriod), now, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.URLs)
+	require.Equal(t, 1, report.DeletedURLs)
+	require.Equal(t, 2, report.ActiveUsers)
+
+	// Границы периода выровнены по суткам, сутки без ссылок включены в ответ
+	require.Equal(t, model.BucketStart(now.Add(-DefaultStatsPeriod), model.StatsDay), report.From)
+	require.Len(t, report.Created, int(report.To.Sub(report.From)/model.StatsDay))
+	require.Equal(t, 2, report.Created[len(report.Created)-1].Count)
+	require.Equal(t, []model.LinkClicks{{Code: code, Clicks: 1}}, report.TopLinks)
+
+	require.Equal(t, 3, report.Redirects.Total)
+	require.Equal(t, 1, report.Redirects.NotFound)
+	require.Equal(t, 1, report.Redirects.Gone)
+	require.InDelta(t, 2.0/3, report.Redirects.ErrorRate, 1e-9)
+	require.False(t, report.Redirects.Since.IsZero())
+
+	// Некорректный период
+	_, err = shortenerService.GetStatsReport(ctx, now, now.Add(-time.Hour), 0)
+	require.ErrorIs(t, err, ErrInvalidStatsRange)
+	_, err = shortenerService.GetStatsReport(ctx, now.Add(-2*maxStatsPeriod), now, 0)
+	require.ErrorIs(t, err, ErrInvalidStatsRange)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+)
+
+// purgeInterval - периодичность окончательного удаления ссылок из корзины
+const purgeInterval = time.Hour
+
+// GetTrash возвращает удаленные ссылки пользователя, срок восстановления которых не истек
+func (service *Shortener) GetTrash(ctx context.Context, userCode string) ([]model.Shortener, error) {
+	if userCode == "" {
+		return nil, errors.New("userCode is empty")
+	}
+
+	deleted, err := service.store.GetDeletedBatch(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+	deletedAfter := time.Now().Add(-service.trashGrace)
+	resp := make([]model.Shortener, 0, len(deleted))
+	for _, s := range deleted {
+		if !s.Data.DeletedAt.Before(deletedAfter) {
+			resp = append(resp, s)
+		}
+	}
+	return resp, nil
+}
+
+// RestoreShortener восстанавливает удаленную ссылку пользователя, если срок восстановления не истек.
+// Чужая ссылка не восстанавливается и не отличается от несуществующей
+func (service *Shortener) RestoreShortener(ctx context.Context, userCode, code string) (model.Shortener, error) {
+	if userCode == "" {
+		return model.Shortener{}, ErrNotOwner
+	}
+	return service.store.RestoreShortener(ctx, model.Shortener{
+		Key:  model.ShortenerKey{Code: code},
+		Data: model.ShortenerData{User: userCode},
+	}, time.Now().Add(-service.trashGrace))
+}
+
+// purgeDeleted периодически окончательно удаляет ссылки, срок хранения которых в корзине истек
+func (service *Shortener) purgeDeleted(interval time.Duration) {
+	defer service.wg.Done()
+	ctx := context.Background()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-service.done:
+			return
+		case now := <-ticker.C:
+			service.store.PurgeDeleted(ctx, now.Add(-service.trashRetention))
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iurnickita/vigilant-train/internal/shortener/model"
+	"github.com/iurnickita/vigilant-train/internal/shortener/repository"
+	repositoryConfig "github.com/iurnickita/vigilant-train/internal/shortener/repository/config"
+	"github.com/iurnickita/vigilant-train/internal/shortener/service/config"
+)
+
+func TestService_Trash(t *testing.T) {
+	ctx := context.Background()
+	store, err := repository.NewStore(repositoryConfig.Config{StoreType: repositoryConfig.StoreTypeVar})
+	require.NoError(t, err)
+	defer store.Close()
+	shortenerService, err := NewShortener(config.Config{TrashGrace: time.Hour}, store)
+	require.NoError(t, err)
+	t.Cleanup(shortenerService.Shutdown)
+
+	resp, err := shortenerService.SetShortener(model.Shortener{Data: model.ShortenerData{URL: "https://ya.ru/", User: "user1"}})
+	require.NoError(t, err)
+	code := resp.Key.Code
+	_, err = store.DeleteShortenerBatch(ctx, []model.Shortener{{Key: resp.Key, Data: model.ShortenerData{User: "user1"}}})
+	require.NoError(t, err)
+
+	trash, err := shortenerService.GetTrash(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	require.Equal(t, code, trash[0].Key.Code)
+	trash, err = shortenerService.GetTrash(ctx, "user2")
+	require.NoError(t, err)
+	require.Empty(t, trash)
+
+	// Восстановить может только владелец
+	_, err = shortenerService.RestoreShortener(ctx, "user2", code)
+	require.ErrorIs(t, err, repository.ErrGetShortenerNotFound)
+	resp, err = shortenerService.RestoreShortener(ctx, "user1", code)
+	require.NoError(t, err)
+	require.Equal(t, "https://ya.ru/", resp.Data.URL)
+	_, err = shortenerService.GetShortener(code)
+	require.NoError(t, err)
+	_, err = shortenerService.RestoreShortener(ctx, "user1", code)
+	require.ErrorIs(t, err, repository.ErrShortenerNotDeleted)
+
+	// Ссылка, удаленная раньше срока восстановления, не показывается и не восстанавливается
+	shortenerService.trashGrace = -time.Minute
+	_, err = store.DeleteShortenerBatch(ctx, []model.Shortener{{Key: resp.Key, Data: model.ShortenerData{User: "user1"}}})
+	require.NoError(t, err)
+	trash, err = shortenerService.GetTrash(ctx, "user1")
+	require.NoError(t, err)
+	require.Empty(t, trash)
+	_, err = shortenerService.RestoreShortener(ctx, "user1", code)
+	require.ErrorIs(t, err, repository.ErrRestoreGracePassed)
+}
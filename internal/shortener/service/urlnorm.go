@@ -0,0 +1,75 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// defaultPorts - порты схем по умолчанию, удаляемые при нормализации
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// NormalizeURL проверяет исходный URL и приводит его к каноническому виду,
+// по которому определяются повторы: абсолютный http/https URL, схема и хост в нижнем регистре,
+// международный домен в punycode, без порта по умолчанию, пустой путь - "/",
+// параметры запроса упорядочены по имени (порядок значений одного параметра сохраняется)
+func NormalizeURL(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", fmt.Errorf("%w: url is empty", ErrInvalidURL)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidURL, err.Error())
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if _, ok := defaultPorts[u.Scheme]; !ok {
+		return "", fmt.Errorf("%w: scheme must be http or https", ErrInvalidURL)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", fmt.Errorf("%w: host is empty", ErrInvalidURL)
+	}
+
+	// Хост и порт
+	host, port := u.Hostname(), u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		// IPv6
+		host = strings.ToLower(host)
+	} else {
+		host, err = idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+		if err != nil || host == "" {
+			return "", fmt.Errorf("%w: invalid host %q", ErrInvalidURL, u.Hostname())
+		}
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	if u.RawQuery != "" {
+		query, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrInvalidURL, err.Error())
+		}
+		u.RawQuery = query.Encode()
+	}
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: "https://ya.ru/", want: "https://ya.ru/"},
+		{raw: "  HTTPS://YA.RU  ", want: "https://ya.ru/"},
+		{raw: "http://example.com:80/a", want: "http://example.com/a"},
+		{raw: "https://example.com:443/a", want: "https://example.com/a"},
+		{raw: "https://example.com:8443/a", want: "https://example.com:8443/a"},
+		{raw: "https://пример.рф/путь", want: "https://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C"},
+		{raw: "https://example.com./", want: "https://example.com/"},
+		{raw: "https://example.com/?b=2&a=1&b=1", want: "https://example.com/?a=1&b=2&b=1"},
+		{raw: "https://example.com/?", want: "https://example.com/"},
+		{raw: "https://example.com/#Frag", want: "https://example.com/#Frag"},
+		{raw: "http://[::1]:80/", want: "http://[::1]/"},
+		{raw: "http://[::1]:8080/", want: "http://[::1]:8080/"},
+	}
+	for _, test := range tests {
+		got, err := NormalizeURL(test.raw)
+		require.NoError(t, err, test.raw)
+		require.Equal(t, test.want, got, test.raw)
+	}
+
+	for _, raw := range []string{
+		"",
+		"ya.ru",
+		"/path",
+		"javascript:alert(1)",
+		"ftp://example.com/",
+		"mailto:user@example.com",
+		"https://",
+		"https://exa mple.com/",
+		"url=https%3A%2F%2Fya.ru%2F",
+	} {
+		_, err := NormalizeURL(raw)
+		require.ErrorIs(t, err, ErrInvalidURL, raw)
+	}
+}
@@ -2,7 +2,11 @@
 package token
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
@@ -17,14 +21,18 @@ type Claims struct {
 
 const secretKey = "supersecretkey"
 
+// userAudience - назначение токена пользователя
+const userAudience = "user"
+
 // BuildJWTString формирует токен с кодом пользователя
 func BuildJWTString(UserCode string) (string, error) {
 	// создаём новый токен с алгоритмом подписи HS256 и утверждениями — Claims
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-		/* RegisteredClaims: jwt.RegisteredClaims{
-		    // когда создан токен
-		    ExpiresAt: jwt.NewNumericDate(time.Now().Add(TOKEN_EXP)),
-		}, */
+		RegisteredClaims: jwt.RegisteredClaims{
+			/* // когда создан токен
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TOKEN_EXP)), */
+			Audience: jwt.ClaimStrings{userAudience},
+		},
 		// собственное утверждение
 		UserCode: UserCode,
 	})
@@ -57,7 +65,71 @@ func GetUserCode(tokenString string) (string, error) {
 		fmt.Println("Token is not valid")
 		return "", err
 	}
+	// Токен другого назначения не является токеном пользователя.
+	// Токены, выданные до появления назначения, его не содержат
+	if !claims.VerifyAudience(userAudience, false) {
+		return "", ErrWrongAudience
+	}
 
 	fmt.Println("Token os valid")
 	return claims.UserCode, nil
 }
+
+// linkAccessAudience - назначение токена доступа к защищенной паролем ссылке
+const linkAccessAudience = "link"
+
+// linkSecretSize - длина случайного ключа подписи токенов доступа к ссылкам в байтах
+const linkSecretSize = 32
+
+// Ошибки токенов
+var (
+	ErrLinkAccessDenied = errors.New("link access denied")
+	ErrWrongAudience    = errors.New("token audience mismatch")
+	ErrEmptySecret      = errors.New("empty token secret")
+)
+
+// NewLinkSecret - случайный ключ подписи токенов доступа к ссылкам
+func NewLinkSecret() (string, error) {
+	b := make([]byte, linkSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// BuildLinkAccessString формирует токен доступа к защищенной паролем ссылке, действующий ttl.
+// Ключ secret не совпадает с ключом токенов пользователя
+func BuildLinkAccessString(secret, code string, ttl time.Duration) (string, error) {
+	if secret == "" {
+		return "", ErrEmptySecret
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   code,
+		Audience:  jwt.ClaimStrings{linkAccessAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+	})
+	return token.SignedString([]byte(secret))
+}
+
+// CheckLinkAccess проверяет, что токен, подписанный ключом secret, дает доступ к ссылке code и не истек
+func CheckLinkAccess(secret, tokenString, code string) error {
+	if secret == "" {
+		return ErrEmptySecret
+	}
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims,
+		func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+			}
+			return []byte(secret), nil
+		})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLinkAccessDenied, err)
+	}
+	// Срок действия обязателен: токен без него бессрочный
+	if !token.Valid || claims.ExpiresAt == nil || claims.Subject != code || !claims.VerifyAudience(linkAccessAudience, true) {
+		return ErrLinkAccessDenied
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUserCode(t *testing.T) {
+	userToken, err := BuildJWTString("user1")
+	require.NoError(t, err)
+	userCode, err := GetUserCode(userToken)
+	require.NoError(t, err)
+	require.Equal(t, "user1", userCode)
+
+	// Токен без назначения, выданный до его появления
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserCode: "user2"}).SignedString([]byte(secretKey))
+	require.NoError(t, err)
+	userCode, err = GetUserCode(legacy)
+	require.NoError(t, err)
+	require.Equal(t, "user2", userCode)
+
+	// Токен другого назначения не принимается, даже подписанный ключом пользователя
+	other, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{linkAccessAudience}},
+	}).SignedString([]byte(secretKey))
+	require.NoError(t, err)
+	_, err = GetUserCode(other)
+	require.ErrorIs(t, err, ErrWrongAudience)
+}
+
+func TestCheckLinkAccess(t *testing.T) {
+	secret, err := NewLinkSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 2*linkSecretSize)
+
+	access, err := BuildLinkAccessString(secret, "code01", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, CheckLinkAccess(secret, access, "code01"))
+	require.ErrorIs(t, CheckLinkAccess(secret, access, "code02"), ErrLinkAccessDenied)
+
+	// Ключ токенов пользователя не подходит для доступа к ссылке
+	forged, err := BuildLinkAccessString(secretKey, "code01", time.Minute)
+	require.NoError(t, err)
+	require.ErrorIs(t, CheckLinkAccess(secret, forged, "code01"), ErrLinkAccessDenied)
+	// Токен доступа к ссылке не является токеном пользователя
+	_, err = GetUserCode(access)
+	require.Error(t, err)
+
+	expired, err := BuildLinkAccessString(secret, "code01", -time.Minute)
+	require.NoError(t, err)
+	require.ErrorIs(t, CheckLinkAccess(secret, expired, "code01"), ErrLinkAccessDenied)
+
+	_, err = BuildLinkAccessString("", "code01", time.Minute)
+	require.ErrorIs(t, err, ErrEmptySecret)
+	require.ErrorIs(t, CheckLinkAccess("", access, "code01"), ErrEmptySecret)
+}